@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/imports"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/logging"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/proxy"
+)
+
+func newCfnCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cfn",
+		Short: "Inspect CloudFormation stacks directly, without a Pulumi program",
+	}
+
+	cmd.AddCommand(newCfnSnapshotCommand())
+	return cmd
+}
+
+func newCfnSnapshotCommand() *cobra.Command {
+	var stacks stringSlice
+	var importFile string
+	var provider string
+	var lookupOpts lookupOptions
+
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Write an import file listing every resource of the CloudFormation stacks",
+		Long: "Read the resources of the CloudFormation stacks and write a Pulumi bulk import file using their " +
+			"physical IDs, then print how many resources were emitted, skipped or left with placeholder IDs. " +
+			"No Pulumi program or stack is needed, which makes it a quick first inventory when sizing a migration.",
+		RunE: func(cmd *cobra.Command, _ []string) (err error) {
+			tokens, err := imports.ParseTokenSet(provider)
+			if err != nil {
+				return err
+			}
+			invocationDir, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			logger := logging.New(cmd.ErrOrStderr(), debugLogging, "component", "cdk-importer")
+			ctx, stop := notifyInterrupts(logger, nil)
+			defer stop()
+			defer func() {
+				if err != nil && ctx.Err() != nil && !errors.Is(err, proxy.ErrInterrupted) {
+					err = fmt.Errorf("%w: %w", proxy.ErrInterrupted, err)
+				}
+			}()
+
+			envs, finish, err := loadStackResources(ctx, logger, stacks, currentAWSBackend(invocationDir), lookupOpts.resolved(invocationDir), nil)
+			if err != nil {
+				return err
+			}
+			defer finish()
+			// Names are only unique within an environment, so one file can't cover several.
+			if len(envs.All()) > 1 {
+				return fmt.Errorf("cfn snapshot only supports stacks from a single AWS environment; run it once per environment")
+			}
+			file, summary, err := imports.BuildImportFile(ctx, envs.Default(), tokens)
+			if err != nil {
+				return err
+			}
+			path := resolvePath(invocationDir, importFile)
+			if err := imports.WriteFile(path, file); err != nil {
+				return err
+			}
+			logger.Info("Wrote import file", "path", path, "resources", len(file.Resources), "provider", string(tokens))
+			return imports.WriteSummary(cmd.OutOrStdout(), summary)
+		},
+	}
+
+	cmd.Flags().Var(&stacks, "stack", "CloudFormation stack name, optionally written as [profile@][account/]region:StackName (can be specified multiple times or comma-separated)")
+	_ = cmd.MarkFlagRequired("stack")
+	cmd.Flags().StringVar(&importFile, "import-file", defaultImportFileName, "Path to write the Pulumi bulk import file")
+	cmd.Flags().StringVar(&provider, "provider", string(imports.TokenSetAWS), "Provider whose resource types the import file uses: aws or aws-native")
+	addStackReadFlags(cmd, &lookupOpts)
+
+	return cmd
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/imports"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+)
+
+func TestCfnSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	request, err := json.Marshal(&cloudformation.ListStackResourcesInput{StackName: aws.String("App")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := json.Marshal(&cloudformation.ListStackResourcesOutput{StackResourceSummaries: []cfntypes.StackResourceSummary{
+		{LogicalResourceId: aws.String("Bucket"), PhysicalResourceId: aws.String("app-bucket"), ResourceType: aws.String("AWS::S3::Bucket")},
+		{LogicalResourceId: aws.String("CDKMetadata"), PhysicalResourceId: aws.String("meta"), ResourceType: aws.String("AWS::CDK::Metadata")},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cassettePath := filepath.Join(dir, "cassette.json")
+	cassette := &lookups.Cassette{Version: 1, Region: "us-east-1", Account: "123456789012", Interactions: []lookups.Interaction{{
+		Operation: "cloudformation:ListStackResources",
+		Request:   request,
+		Response:  response,
+	}}}
+	if err := cassette.WriteFile(cassettePath); err != nil {
+		t.Fatal(err)
+	}
+
+	importPath := filepath.Join(dir, "snapshot.json")
+	var out bytes.Buffer
+	root := newRootCommand()
+	root.SetOut(&out)
+	root.SetErr(io.Discard)
+	root.SetArgs([]string{"--replay", cassettePath, "cfn", "snapshot", "--stack", "App", "--provider", "aws-native", "--import-file", importPath})
+	t.Cleanup(func() { replayFile = "" })
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := imports.ReadFile(importPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Resources) != 1 || file.Resources[0].Type != "aws-native:s3:Bucket" || file.Resources[0].ID != "app-bucket" {
+		t.Fatalf("expected the bucket with its physical ID, got %+v", file.Resources)
+	}
+	if !strings.Contains(out.String(), "2 CloudFormation resources: 1 skipped, 0 with placeholder IDs; import file entries: 1") {
+		t.Fatalf("expected a summary of the snapshot, got %q", out.String())
+	}
+	if !strings.Contains(out.String(), "CDKMetadata") {
+		t.Fatalf("expected the skipped metadata resource to be listed, got %q", out.String())
+	}
+}
+
+func TestCfnSnapshotFlags(t *testing.T) {
+	cmd := newCfnSnapshotCommand()
+	for _, name := range []string{"resolver-config", "id-overrides", "profile", "role-arn"} {
+		if cmd.Flags().Lookup(name) == nil {
+			t.Fatalf("expected cfn snapshot to accept --%s", name)
+		}
+	}
+	// Nothing is looked up, so the lookup tuning flags would be silently ignored.
+	for _, name := range []string{"cdk-out", "prefetch", "prefetch-workers", "bootstrap-stack"} {
+		if cmd.Flags().Lookup(name) != nil {
+			t.Fatalf("expected cfn snapshot not to accept --%s", name)
+		}
+	}
+}
@@ -3,8 +3,93 @@ package cmd
 import (
 	"fmt"
 	"strings"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/proxy"
+	"github.com/spf13/cobra"
 )
 
+const defaultPrefetchWorkers = 8
+
+// lookupOptions holds the flags that tune how CloudFormation resources are matched and resolved.
+type lookupOptions struct {
+	cdkOut          string
+	resolverConfig  string
+	idOverrides     string
+	prefetch        bool
+	prefetchWorkers int
+	bootstrapStack  string
+	credentials     lookups.Credentials
+}
+
+// addLookupFlags registers the lookup tuning flags shared by every command that resolves IDs.
+func addLookupFlags(cmd *cobra.Command, opts *lookupOptions) {
+	cmd.Flags().StringVar(&opts.cdkOut, "cdk-out", "", "Path to the CDK cloud assembly (cdk.out) used to match resources to logical IDs by construct path")
+	cmd.Flags().BoolVar(&opts.prefetch, "prefetch", false, "List every CCAPI resource type that needs a lookup up front, in parallel, before importing")
+	cmd.Flags().IntVar(&opts.prefetchWorkers, "prefetch-workers", defaultPrefetchWorkers, "Maximum number of concurrent CCAPI listings during --prefetch")
+	cmd.Flags().StringVar(&opts.bootstrapStack, "bootstrap-stack", "", "Import the CDK asset bucket and repository resources against the staging bucket and container asset repository of this CDK bootstrap stack (--bootstrap-stack alone uses "+lookups.DefaultBootstrapStack+")")
+	cmd.Flags().Lookup("bootstrap-stack").NoOptDefVal = lookups.DefaultBootstrapStack
+	addStackReadFlags(cmd, opts)
+}
+
+// addStackReadFlags registers the flags of commands that read stacks without looking anything up:
+// the metadata and ID overrides, and the AWS credentials.
+func addStackReadFlags(cmd *cobra.Command, opts *lookupOptions) {
+	cmd.Flags().StringVar(&opts.resolverConfig, "resolver-config", "", "Path to a YAML or JSON file with ID strategy, primary identifier, separator and ID template overrides per CloudFormation type")
+	cmd.Flags().StringVar(&opts.idOverrides, "id-overrides", "", "Path to a YAML or JSON file mapping CloudFormation logical IDs (optionally StackName/LogicalID) or Pulumi URNs to import IDs, used instead of any lookup")
+	cmd.Flags().StringVar(&opts.credentials.Profile, "profile", "", "AWS shared config profile used for every AWS call (a profile in a --stack reference takes precedence)")
+	cmd.Flags().StringVar(&opts.credentials.RoleARN, "role-arn", "", "IAM role to assume for every AWS call, including the providers of capture and verify stacks")
+	cmd.Flags().StringVar(&opts.credentials.ExternalID, "external-id", "", "External ID passed when assuming --role-arn")
+	cmd.Flags().DurationVar(&opts.credentials.SessionDuration, "session-duration", 0, "Duration of the --role-arn session, e.g. 1h (default: the STS default of 15m)")
+}
+
+// providerOptions select the provider binaries run by every command that runs the program.
+type providerOptions struct {
+	versions  proxy.ProviderVersions
+	pluginDir string
+}
+
+// addProviderFlags registers the provider version overrides and plugin directory of every command
+// that runs the program.
+func addProviderFlags(cmd *cobra.Command, opts *providerOptions) {
+	cmd.Flags().StringVar(&opts.versions.AWS, "aws-version", "", "Version of the aws provider to run (default: detected from the program's lockfile or stack state)")
+	cmd.Flags().StringVar(&opts.versions.AWSNative, "aws-native-version", "", "Version of the aws-native provider to run (default: detected from the program's lockfile or stack state)")
+	cmd.Flags().StringVar(&opts.versions.DockerBuild, "docker-build-version", "", "Version of the docker-build provider to run (default: detected from the program's lockfile or stack state)")
+	cmd.Flags().StringVar(&opts.pluginDir, "plugin-dir", "", "Directory of pre-installed provider binaries, searched before the Pulumi plugin cache; providers found on disk are never downloaded")
+}
+
+// checkpointOptions locate the checkpoint of resolved import IDs and whether to resume from it.
+type checkpointOptions struct {
+	path   string
+	resume bool
+}
+
+// addCheckpointFlags registers the checkpoint flags of every command that imports resources.
+func addCheckpointFlags(cmd *cobra.Command, opts *checkpointOptions) {
+	cmd.Flags().StringVar(&opts.path, "checkpoint", "", "Path of the checkpoint recording each resolved import ID as it happens (default: "+defaultCheckpointFile+")")
+	cmd.Flags().BoolVar(&opts.resume, "resume", false, "Reuse the import IDs in the checkpoint of a previous run that failed, only resolving the remaining resources")
+}
+
+// resolved returns a copy of opts with paths made absolute relative to baseDir.
+func (opts checkpointOptions) resolved(baseDir string) checkpointOptions {
+	opts.path = resolvePath(baseDir, opts.path)
+	return opts
+}
+
+// resolved returns a copy of opts with paths made absolute relative to baseDir.
+func (opts providerOptions) resolved(baseDir string) providerOptions {
+	opts.pluginDir = resolvePath(baseDir, opts.pluginDir)
+	return opts
+}
+
+// resolved returns a copy of opts with paths made absolute relative to baseDir.
+func (opts lookupOptions) resolved(baseDir string) lookupOptions {
+	opts.cdkOut = resolvePath(baseDir, opts.cdkOut)
+	opts.resolverConfig = resolvePath(baseDir, opts.resolverConfig)
+	opts.idOverrides = resolvePath(baseDir, opts.idOverrides)
+	return opts
+}
+
 type stringSlice []string
 
 func (s *stringSlice) String() string {
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/imports"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/metadata"
+)
+
+func newImportFileCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "import-file",
+		Short: "Work with Pulumi bulk import files",
+	}
+
+	cmd.AddCommand(newImportFileValidateCommand())
+	return cmd
+}
+
+func newImportFileValidateCommand() *cobra.Command {
+	var resolverConfig, awsSchemaPath string
+
+	cmd := &cobra.Command{
+		Use:   "validate [path]",
+		Short: "Check an import file against the embedded metadata without importing anything",
+		Long: "Check every resource of a Pulumi bulk import file (import.json by default) against the embedded " +
+			"metadata: the type exists, the ID is set and isn't a placeholder, composite IDs have one part per " +
+			"primary identifier key, components have no ID, parents are in the name table or the file, providers " +
+			"are in the name table and properties are inputs of the resource. aws types and properties are checked " +
+			"against the provider schema given with --aws-schema; without it, properties of aws types are not " +
+			"checked. Each problem is printed as `path:line: message`, " +
+			"and the command fails if any error is found, so it can gate a hand-edited import file in CI.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			invocationDir, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			path := defaultImportFileName
+			if len(args) == 1 {
+				path = args[0]
+			}
+			path = resolvePath(invocationDir, path)
+
+			if resolverConfig != "" {
+				cfg, err := metadata.LoadResolverConfig(resolvePath(invocationDir, resolverConfig))
+				if err != nil {
+					return err
+				}
+				if err := metadata.ApplyResolverConfig(cfg); err != nil {
+					return err
+				}
+			}
+
+			var awsSchema *metadata.AwsSchema
+			if awsSchemaPath != "" {
+				if awsSchema, err = metadata.LoadAwsSchema(resolvePath(invocationDir, awsSchemaPath)); err != nil {
+					return err
+				}
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			problems, err := imports.ValidateFile(data, awsSchema)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			errs := 0
+			for _, p := range problems {
+				if !p.Warning {
+					errs++
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s:%s\n", path, p)
+			}
+			if errs > 0 {
+				return fmt.Errorf("%d errors in %s", errs, path)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&resolverConfig, "resolver-config", "", "Path to the resolver config used for the import, so its primary identifier and separator overrides are checked")
+	cmd.Flags().StringVar(&awsSchemaPath, "aws-schema", "", "Path to the aws provider schema written by 'pulumi package get-schema aws', to check aws types and properties against it")
+	return cmd
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportFileValidate(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "import.json")
+	data := `{
+  "resources": [
+    {"type": "aws-native:s3:Bucket", "name": "bucket", "id": "my-bucket"},
+    {"type": "aws-native:s3:Bucket", "name": "pending", "id": "<PLACEHOLDER>"}
+  ]
+}
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	root := newRootCommand()
+	root.SetOut(&out)
+	root.SetErr(io.Discard)
+	root.SetArgs([]string{"import-file", "validate", path})
+	err := root.Execute()
+	if err == nil || err.Error() != "1 errors in "+path {
+		t.Fatalf("expected one error, got %v", err)
+	}
+	want := path + `:4: error: aws-native:s3:Bucket "pending" still has the placeholder id <PLACEHOLDER>`
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestImportFileValidateValid(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "import.json")
+	data := `{"resources": [{"type": "aws-native:s3:Bucket", "name": "bucket", "id": "my-bucket"}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	root := newRootCommand()
+	root.SetOut(&out)
+	root.SetErr(io.Discard)
+	root.SetArgs([]string{"import-file", "validate", path})
+	if err := root.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected no output, got %q", out.String())
+	}
+}
+
+func TestImportFileValidateAwsSchema(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "import.json")
+	data := `{"resources": [{"type": "aws:ec2/vpc:Vpc", "name": "vpc", "id": "vpc-123", "properties": ["cidr"]}]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	schemaPath := filepath.Join(dir, "aws-schema.json")
+	schema := `{"resources": {"aws:ec2/vpc:Vpc": {"inputProperties": {"cidrBlock": {}}}}}`
+	if err := os.WriteFile(schemaPath, []byte(schema), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	root := newRootCommand()
+	root.SetOut(&out)
+	root.SetErr(io.Discard)
+	root.SetArgs([]string{"import-file", "validate", path, "--aws-schema", schemaPath})
+	err := root.Execute()
+	if err == nil || err.Error() != "1 errors in "+path {
+		t.Fatalf("expected one error, got %v", err)
+	}
+	want := path + `:1: error: property "cidr" is not an input of aws:ec2/vpc:Vpc`
+	if got := strings.TrimSpace(out.String()); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
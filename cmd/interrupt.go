@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/proxy"
+)
+
+// exitCodeInterrupted is the exit code of a run stopped by SIGINT or SIGTERM, following the shell
+// convention of 128 + SIGINT.
+const exitCodeInterrupted = 130
+
+// notifyInterrupts returns a context canceled by the first SIGINT or SIGTERM. A second signal
+// triggers kill and exits right away. Call stop once the run is finished.
+func notifyInterrupts(logger *slog.Logger, kill *proxy.KillSwitch) (ctx context.Context, stop func()) {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	ctx, done := watchInterrupts(signals, logger, kill, os.Exit)
+	return ctx, func() {
+		signal.Stop(signals)
+		done()
+	}
+}
+
+func watchInterrupts(signals <-chan os.Signal, logger *slog.Logger, kill *proxy.KillSwitch, exit func(int)) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			logger.Warn("Interrupted; finishing the operations in flight and writing partial results. Interrupt again to quit immediately",
+				"signal", sig.String())
+			cancel()
+		case <-done:
+			return
+		}
+		select {
+		case <-signals:
+			logger.Warn("Interrupted again; killing providers")
+			kill.Trigger()
+			exit(exitCodeInterrupted)
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		cancel()
+	}
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/proxy"
+)
+
+func TestWatchInterrupts(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	signals := make(chan os.Signal, 2)
+	killed := make(chan struct{})
+	kill := &proxy.KillSwitch{}
+	exited := make(chan int, 1)
+	ctx, stop := watchInterrupts(signals, logger, kill, func(code int) { exited <- code })
+	defer stop()
+
+	signals <- os.Interrupt
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the first signal to cancel the run")
+	}
+	select {
+	case code := <-exited:
+		t.Fatalf("expected the first signal not to exit, got exit code %d", code)
+	default:
+	}
+
+	kill.Register(func() { close(killed) })
+	signals <- os.Interrupt
+	select {
+	case code := <-exited:
+		if code != exitCodeInterrupted {
+			t.Fatalf("expected exit code %d, got %d", exitCodeInterrupted, code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the second signal to exit")
+	}
+	select {
+	case <-killed:
+	default:
+		t.Fatal("expected the second signal to kill the providers")
+	}
+}
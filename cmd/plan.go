@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/imports"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/logging"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/plan"
+)
+
+func newPlanCommand() *cobra.Command {
+	var stacks stringSlice
+	var programDir string
+	var importFile string
+	var lookupOpts lookupOptions
+
+	cmd := &cobra.Command{
+		Use:   "plan",
+		Short: "Resolve import IDs offline and print the mapping without modifying any stack",
+		Long: "Resolve import IDs for the resources registered by a Pulumi program (via `pulumi preview`) " +
+			"or listed in an existing import file, and print URN, CloudFormation logical ID, primary ID and the " +
+			"resolution strategy used. No providers are intercepted and no stack state is written.",
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			invocationDir, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			ctx := context.Background()
+			w := cmd.OutOrStdout()
+			logger := logging.New(cmd.ErrOrStderr(), debugLogging, "component", "cdk-importer")
+
+			var resources []plan.Resource
+			if importFile != "" {
+				path := resolvePath(invocationDir, importFile)
+				file, err := imports.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("reading import file %q: %w", path, err)
+				}
+				resources = plan.ResourcesFromImportFile(file)
+			} else {
+				workDir := invocationDir
+				if programDir != "" {
+					workDir = resolvePath(invocationDir, programDir)
+				}
+				logger.Info("Collecting registered resources via pulumi preview", "programDir", workDir)
+				resources, err = plan.ResourcesFromPreview(ctx, workDir, map[string]string{
+					"PULUMI_SKIP_UPDATE_CHECK":                 "true",
+					"PULUMI_AUTOMATION_API_SKIP_VERSION_CHECK": "true",
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			envs, finish, err := loadStackResources(ctx, logger, stacks, currentAWSBackend(invocationDir), lookupOpts.resolved(invocationDir), nil)
+			if err != nil {
+				return err
+			}
+			defer finish()
+			// The resources don't say which provider instance they belong to, so there is no way to
+			// pick an environment per resource.
+			if len(envs.All()) > 1 {
+				return fmt.Errorf("plan only supports stacks from a single AWS environment; run it once per environment")
+			}
+			entries, err := plan.Resolve(ctx, envs.Default(), resources)
+			if err != nil {
+				return err
+			}
+			if err := plan.WriteTable(w, entries); err != nil {
+				return err
+			}
+			if failed := plan.Failures(entries); failed > 0 {
+				return fmt.Errorf("%d of %d resources could not be resolved", failed, len(entries))
+			}
+			logger.Info("All resources resolved", "resources", len(entries))
+			return nil
+		},
+	}
+
+	cmd.Flags().Var(&stacks, "stack", "CloudFormation stack name, optionally written as [profile@][account/]region:StackName (can be specified multiple times or comma-separated)")
+	_ = cmd.MarkFlagRequired("stack")
+	cmd.Flags().StringVar(&programDir, "program-dir", "", "Path to the Pulumi program to preview (default: current directory)")
+	cmd.Flags().StringVar(&importFile, "import-file", "", "Resolve the resources listed in an existing import file instead of running pulumi preview")
+	addLookupFlags(cmd, &lookupOpts)
+	cmd.MarkFlagsMutuallyExclusive("program-dir", "import-file")
+
+	return cmd
+}
@@ -15,7 +15,7 @@ func newProgramCommand() *cobra.Command {
 		Short: "Operate on an existing Pulumi program generated from a CDK app",
 	}
 
-	cmd.AddCommand(newProgramImportCommand(), newProgramIterateCommand())
+	cmd.AddCommand(newProgramImportCommand(), newProgramIterateCommand(), newProgramVerifyCommand())
 	return cmd
 }
 
@@ -23,6 +23,11 @@ func newProgramImportCommand() *cobra.Command {
 	var stacks stringSlice
 	var programDir string
 	var importFile string
+	var importReferences bool
+	var reportFile string
+	var lookupOpts lookupOptions
+	var providerOpts providerOptions
+	var checkpointOpts checkpointOptions
 
 	cmd := &cobra.Command{
 		Use:   "import",
@@ -40,6 +45,9 @@ func newProgramImportCommand() *cobra.Command {
 				mode:            proxy.RunPulumi,
 				stacks:          stacks,
 				importFile:      resolvePath(invocationDir, importFile),
+				importRefs:      importReferences,
+				reportFile:      resolvePath(invocationDir, reportFile),
+				lookups:         lookupOpts.resolved(invocationDir),
 				skipCreate:      true,
 				workDir:         workDir,
 				invocationDir:   invocationDir,
@@ -47,17 +55,25 @@ func newProgramImportCommand() *cobra.Command {
 				localStackFile:  "",
 				debugLogging:    debugLogging,
 				verbose:         verbose,
+				backend:         currentAWSBackend(invocationDir),
+				providers:       providerOpts.resolved(invocationDir),
+				checkpoint:      checkpointOpts.resolved(invocationDir),
 			}
 			return run(cfg)
 		},
 	}
 
-	cmd.Flags().Var(&stacks, "stack", "CloudFormation stack name (can be specified multiple times or comma-separated)")
+	cmd.Flags().Var(&stacks, "stack", "CloudFormation stack name, optionally written as [profile@][account/]region:StackName (can be specified multiple times or comma-separated)")
 	_ = cmd.MarkFlagRequired("stack")
 	cmd.Flags().StringVar(&programDir, "program-dir", "", "Path to an existing Pulumi program generated from a CDK app")
 	_ = cmd.MarkFlagRequired("program-dir")
 	cmd.Flags().StringVar(&importFile, "import-file", "", "Path to write a Pulumi bulk import file after importing into the selected stack (default: import.json when provided without a value)")
 	cmd.Flags().Lookup("import-file").NoOptDefVal = defaultImportFileName
+	cmd.Flags().BoolVar(&importReferences, "import-file-references", false, "Emit the parent and provider of each resource in the import file, for programs with explicit providers or component hierarchies (default: import with the default provider and no parent)")
+	addLookupFlags(cmd, &lookupOpts)
+	addProviderFlags(cmd, &providerOpts)
+	addCheckpointFlags(cmd, &checkpointOpts)
+	cmd.Flags().StringVar(&reportFile, "report", "", "Path to write a JSON report describing how each resource was resolved and whether it was imported")
 
 	return cmd
 }
@@ -66,6 +82,11 @@ func newProgramIterateCommand() *cobra.Command {
 	var stacks stringSlice
 	var programDir string
 	var importFile string
+	var importReferences bool
+	var reportFile string
+	var lookupOpts lookupOptions
+	var providerOpts providerOptions
+	var checkpointOpts checkpointOptions
 
 	cmd := &cobra.Command{
 		Use:   "iterate",
@@ -87,6 +108,9 @@ func newProgramIterateCommand() *cobra.Command {
 				mode:            proxy.CaptureImports,
 				stacks:          stacks,
 				importFile:      resolvePath(invocationDir, resolvedImport),
+				importRefs:      importReferences,
+				reportFile:      resolvePath(invocationDir, reportFile),
+				lookups:         lookupOpts.resolved(invocationDir),
 				skipCreate:      true,
 				workDir:         workDir,
 				invocationDir:   invocationDir,
@@ -94,17 +118,75 @@ func newProgramIterateCommand() *cobra.Command {
 				localStackFile:  resolvePath(invocationDir, defaultLocalStackFile),
 				debugLogging:    debugLogging,
 				verbose:         verbose,
+				backend:         currentAWSBackend(invocationDir),
+				providers:       providerOpts.resolved(invocationDir),
+				checkpoint:      checkpointOpts.resolved(invocationDir),
 			}
 			return run(cfg)
 		},
 	}
 
-	cmd.Flags().Var(&stacks, "stack", "CloudFormation stack name (can be specified multiple times or comma-separated)")
+	cmd.Flags().Var(&stacks, "stack", "CloudFormation stack name, optionally written as [profile@][account/]region:StackName (can be specified multiple times or comma-separated)")
 	_ = cmd.MarkFlagRequired("stack")
 	cmd.Flags().StringVar(&programDir, "program-dir", "", "Path to an existing Pulumi program generated from a CDK app")
 	_ = cmd.MarkFlagRequired("program-dir")
 	cmd.Flags().StringVar(&importFile, "import-file", "", "Path to write a Pulumi bulk import file (default: import.json when omitted or provided without a value)")
 	cmd.Flags().Lookup("import-file").NoOptDefVal = defaultImportFileName
+	cmd.Flags().BoolVar(&importReferences, "import-file-references", false, "Emit the parent and provider of each resource in the import file, for programs with explicit providers or component hierarchies (default: import with the default provider and no parent)")
+	addLookupFlags(cmd, &lookupOpts)
+	addProviderFlags(cmd, &providerOpts)
+	addCheckpointFlags(cmd, &checkpointOpts)
+	cmd.Flags().StringVar(&reportFile, "report", "", "Path to write a JSON report describing how each resource was resolved and whether it was imported")
+
+	return cmd
+}
+
+func newProgramVerifyCommand() *cobra.Command {
+	var stacks stringSlice
+	var programDir string
+	var reportFile string
+	var lookupOpts lookupOptions
+	var providerOpts providerOptions
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check that every resource would import cleanly without writing any state",
+		Long: "Resolve import IDs and read every resource through the providers, recording whether it exists " +
+			"and how its live properties differ from the program inputs. Nothing is created: each create returns the " +
+			"state that was read and the run uses a throwaway local backend, so neither the selected stack nor any " +
+			"other backend is modified. Programs with resources from providers other than aws, aws-native and " +
+			"docker-build are refused, since those would really be created.",
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if programDir == "" {
+				return fmt.Errorf("--program-dir is required")
+			}
+			invocationDir, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			cfg := runConfig{
+				mode:          proxy.Verify,
+				stacks:        stacks,
+				reportFile:    resolvePath(invocationDir, reportFile),
+				lookups:       lookupOpts.resolved(invocationDir),
+				workDir:       resolvePath(invocationDir, programDir),
+				invocationDir: invocationDir,
+				debugLogging:  debugLogging,
+				verbose:       verbose,
+				backend:       currentAWSBackend(invocationDir),
+				providers:     providerOpts.resolved(invocationDir),
+			}
+			return run(cfg)
+		},
+	}
+
+	cmd.Flags().Var(&stacks, "stack", "CloudFormation stack name, optionally written as [profile@][account/]region:StackName (can be specified multiple times or comma-separated)")
+	_ = cmd.MarkFlagRequired("stack")
+	cmd.Flags().StringVar(&programDir, "program-dir", "", "Path to an existing Pulumi program generated from a CDK app")
+	_ = cmd.MarkFlagRequired("program-dir")
+	addLookupFlags(cmd, &lookupOpts)
+	addProviderFlags(cmd, &providerOpts)
+	cmd.Flags().StringVar(&reportFile, "report", "", "Path to write a JSON report describing whether each resource exists and how it differs from the program")
 
 	return cmd
 }
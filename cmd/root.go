@@ -1,20 +1,27 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/proxy"
 	"github.com/spf13/cobra"
 )
 
 var verbose int
 var debugLogging bool
+var recordFile string
+var replayFile string
 
 // Execute runs the CLI.
 func Execute() {
 	rootCmd := newRootCommand()
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, formatCLIError(err))
+		if errors.Is(err, proxy.ErrInterrupted) {
+			os.Exit(exitCodeInterrupted)
+		}
 		os.Exit(1)
 	}
 }
@@ -30,7 +37,17 @@ func newRootCommand() *cobra.Command {
 
 	cmd.PersistentFlags().IntVarP(&verbose, "verbose", "v", 0, "Enable verbose logging (0-9)")
 	cmd.PersistentFlags().BoolVar(&debugLogging, "debug", false, "Enable debug-level logging for the importer")
-	cmd.AddCommand(newRuntimeCommand(), newProgramCommand())
+	cmd.PersistentFlags().StringVar(&recordFile, "record", "", "Record every AWS API response to this cassette file for offline replay")
+	cmd.PersistentFlags().StringVar(&replayFile, "replay", "", "Answer AWS API calls from a cassette recorded with --record instead of calling AWS")
+	cmd.AddCommand(newRuntimeCommand(), newProgramCommand(), newPlanCommand(), newCfnCommand(), newImportFileCommand())
 
 	return cmd
 }
+
+// currentAWSBackend builds the AWS backend from the persistent --record/--replay flags.
+func currentAWSBackend(invocationDir string) awsBackend {
+	return awsBackend{
+		recordFile: resolvePath(invocationDir, recordFile),
+		replayFile: resolvePath(invocationDir, replayFile),
+	}
+}
@@ -2,14 +2,17 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/logging"
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/metadata"
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/proxy"
 )
 
@@ -17,12 +20,17 @@ const (
 	defaultImportFileName = "import.json"
 	// Iteration defaults to keeping the local backend in a predictable location for reuse.
 	defaultLocalStackFile = ".pulumi/import-state.json"
+	// Resolved import IDs are checkpointed next to the local backend so a failed run can resume.
+	defaultCheckpointFile = ".pulumi/import-checkpoint.jsonl"
 )
 
 type runConfig struct {
 	mode            proxy.RunMode
 	stacks          []string
 	importFile      string
+	importRefs      bool
+	reportFile      string
+	lookups         lookupOptions
 	skipCreate      bool
 	keepImportState bool
 	localStackFile  string
@@ -30,10 +38,20 @@ type runConfig struct {
 	invocationDir   string
 	debugLogging    bool
 	verbose         int
+	backend         awsBackend
+	providers       providerOptions
+	checkpoint      checkpointOptions
 	stdout          io.Writer
 }
 
-func run(cfg runConfig) error {
+// awsBackend selects where AWS API responses come from: live AWS (optionally recorded to a
+// cassette) or a previously recorded cassette.
+type awsBackend struct {
+	recordFile string
+	replayFile string
+}
+
+func run(cfg runConfig) (err error) {
 	if err := validateConfig(cfg); err != nil {
 		return err
 	}
@@ -44,29 +62,52 @@ func run(cfg runConfig) error {
 	}
 	// The writer for the logger could be passed in `cfg` for better testability/flexibility.
 	logger := logging.New(w, cfg.debugLogging, "component", "cdk-importer")
-	ctx := context.Background()
+	killSwitch := &proxy.KillSwitch{}
+	ctx, stop := notifyInterrupts(logger, killSwitch)
+	defer stop()
+	defer func() {
+		// Whatever failed after an interrupt failed because of it.
+		if err != nil && ctx.Err() != nil && !errors.Is(err, proxy.ErrInterrupted) {
+			err = fmt.Errorf("%w: %w", proxy.ErrInterrupted, err)
+		}
+	}()
 
 	if err := os.Chdir(cfg.workDir); err != nil {
 		return fmt.Errorf("failed to change directory to program: %w", err)
 	}
 
-	cc, err := lookups.NewDefaultLookups(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to initialize AWS clients (set AWS_REGION or AWS_DEFAULT_REGION if not already configured): %w", err)
-	}
-
-	for _, stackRef := range cfg.stacks {
-		stackName := common.StackName(stackRef)
-		logger.Info("Getting stack resources", "stack", stackName)
-		// NOTE: CfnStackResources is keyed only by logical ID, so later stacks
-		// can overwrite earlier ones if names collide. We assume overlaps are rare
-		// across stacks generated by the same CDK app. TODO: Revisit if collisions
-		// show up in the wild and namespace by stack.
-		if err := cc.GetStackResources(ctx, stackName); err != nil {
+	// Verify doesn't import anything, so there is nothing to resume.
+	var checkpoint *lookups.Checkpoint
+	if cfg.mode != proxy.Verify {
+		checkpointPath := cfg.checkpoint.path
+		if checkpointPath == "" {
+			checkpointPath = resolvePath(cfg.invocationDir, defaultCheckpointFile)
+		}
+		checkpoint, err = lookups.OpenCheckpoint(checkpointPath, cfg.checkpoint.resume)
+		if err != nil {
 			return err
 		}
+		if n := checkpoint.Resumed(); n > 0 {
+			logger.Info("Resuming from checkpoint", "file", checkpointPath, "resources", n)
+		}
+		defer func() {
+			if closeErr := checkpoint.Close(); closeErr != nil {
+				logger.Warn("Failed to write checkpoint; --resume will repeat some lookups", "error", closeErr)
+				return
+			}
+			// Nothing is left to resume once every resource is imported.
+			if err == nil {
+				_ = os.Remove(checkpointPath)
+			}
+		}()
 	}
 
+	envs, finish, err := loadStackResources(ctx, logger, cfg.stacks, cfg.backend, cfg.lookups, checkpoint)
+	if err != nil {
+		return err
+	}
+	defer finish()
+
 	mode := cfg.mode
 	importPath := cfg.importFile
 	if importPath == "" && mode == proxy.CaptureImports {
@@ -94,9 +135,217 @@ func run(cfg runConfig) error {
 		Verbose:              cfg.verbose,
 		FilterFailuresOnly:   mode == proxy.RunPulumi && importPath != "",
 		IncludeAllRegistered: mode == proxy.CaptureImports,
+		ImportFileReferences: cfg.importRefs,
+		ReportFilePath:       cfg.reportFile,
+		Credentials:          cfg.lookups.credentials,
+		CheckProviderAccount: cfg.backend.replayFile == "",
+		ProviderVersions:     cfg.providers.versions,
+		KillSwitch:           killSwitch,
+		PluginDir:            cfg.providers.pluginDir,
+	}
+
+	return proxy.RunPulumiUpWithProxies(ctx, logger, envs, ".", options)
+}
+
+// loadStackResources initializes the AWS clients of every environment the requested CloudFormation
+// stacks are deployed to and fetches their resources, then applies the bootstrap stack, ID override,
+// cloud assembly, checkpoint and prefetch options to each environment. Resolver overrides are merged
+// into the embedded metadata first. The returned finish func must be called once all lookups are
+// done; it writes the cassette when recording.
+func loadStackResources(
+	ctx context.Context,
+	logger *slog.Logger,
+	stacks []string,
+	backend awsBackend,
+	opts lookupOptions,
+	checkpoint *lookups.Checkpoint,
+) (*lookups.Environments, func(), error) {
+	if backend.recordFile != "" && backend.replayFile != "" {
+		return nil, nil, fmt.Errorf("--record and --replay cannot be used together")
+	}
+
+	if err := opts.credentials.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	refs := make([]lookups.StackRef, 0, len(stacks))
+	for _, stack := range stacks {
+		ref, err := lookups.ParseStackRef(stack)
+		if err != nil {
+			return nil, nil, err
+		}
+		refs = append(refs, ref)
+	}
+
+	if opts.resolverConfig != "" {
+		resolverConfig, err := metadata.LoadResolverConfig(opts.resolverConfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := metadata.ApplyResolverConfig(resolverConfig); err != nil {
+			return nil, nil, err
+		}
+		logger.Info("Loaded resolver overrides", "file", opts.resolverConfig, "resourceTypes", len(resolverConfig.Resources))
+	}
+
+	byEnv, envs, err := loadEnvironments(ctx, logger, refs, backend, opts.credentials)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	finish := func() {}
+	if backend.recordFile != "" {
+		if len(envs.All()) > 1 {
+			return nil, nil, fmt.Errorf("--record only supports stacks from a single AWS environment")
+		}
+		recorder := envs.Default().Record()
+		finish = func() {
+			if err := recorder.Save(backend.recordFile); err != nil {
+				logger.Warn("Failed to write cassette", "file", backend.recordFile, "error", err)
+				return
+			}
+			logger.Info("Recorded AWS responses", "file", backend.recordFile)
+		}
+	}
+
+	for _, ref := range refs {
+		cc := byEnv[ref.Environment]
+		logger.Info("Getting stack resources", "stack", ref.StackName, "region", cc.Region, "account", cc.Account)
+		if err := cc.GetStackResources(ctx, ref.StackName); err != nil {
+			finish()
+			return nil, nil, err
+		}
+	}
+
+	if opts.bootstrapStack != "" {
+		// Every environment is bootstrapped separately, so each one has its own bootstrap stack.
+		bootstrapStack := common.StackName(opts.bootstrapStack)
+		for _, cc := range envs.All() {
+			bootstrap, err := cc.LoadBootstrapAssets(ctx, bootstrapStack)
+			if err != nil {
+				finish()
+				return nil, nil, err
+			}
+			cc.Bootstrap = bootstrap
+			logger.Info("Loaded CDK bootstrap asset storage", "stack", bootstrapStack, "region", cc.Region,
+				"bucket", string(bootstrap.Bucket.ID), "repository", string(bootstrap.Repository.ID))
+		}
+	}
+
+	if opts.idOverrides != "" {
+		overrides, err := lookups.LoadIDOverrides(opts.idOverrides)
+		if err != nil {
+			finish()
+			return nil, nil, err
+		}
+		selected := map[lookups.StackResourceKey]lookups.CfnStackResource{}
+		for _, cc := range envs.All() {
+			cc.IDOverrides = overrides
+			for key, r := range cc.CfnStackResources {
+				selected[key] = r
+			}
+		}
+		for _, key := range overrides.Unmatched(selected) {
+			logger.Warn("ID override doesn't match any resource in the selected stacks", "key", key)
+		}
+		logger.Info("Loaded ID overrides", "file", opts.idOverrides, "overrides", overrides.Len())
+	}
+
+	if opts.cdkOut != "" {
+		annotated := 0
+		for _, cc := range envs.All() {
+			n, err := cc.ApplyCloudAssembly(opts.cdkOut)
+			if err != nil {
+				finish()
+				return nil, nil, fmt.Errorf("failed to read cloud assembly %s: %w", opts.cdkOut, err)
+			}
+			annotated += n
+		}
+		logger.Info("Loaded construct paths from cloud assembly", "dir", opts.cdkOut, "resources", annotated)
+	}
+
+	for _, cc := range envs.All() {
+		cc.Checkpoint = checkpoint
+	}
+
+	if opts.prefetch {
+		for _, cc := range envs.All() {
+			logger.Info("Prefetching CCAPI resource listings", "region", cc.Region, "workers", opts.prefetchWorkers)
+			summary, err := cc.PrefetchCCAPI(ctx, opts.prefetchWorkers)
+			if err != nil {
+				finish()
+				return nil, nil, fmt.Errorf("prefetching CCAPI resources: %w", err)
+			}
+			for _, failure := range summary.Failures {
+				logger.Debug("Prefetch listing failed", "resourceType", failure.ResourceType, "error", failure.Err)
+			}
+			logger.Info("Prefetched CCAPI resource listings", "region", cc.Region, "types", len(summary.Listed), "failed", len(summary.Failures))
+		}
+	}
+	return envs, finish, nil
+}
+
+// loadEnvironments creates a Lookups for every AWS environment of refs using creds. References that
+// resolve to the same account and region share a Lookups. Unqualified stack names use the default
+// AWS configuration, which is also the default environment when any stack uses it.
+func loadEnvironments(ctx context.Context, logger *slog.Logger, refs []lookups.StackRef, backend awsBackend, creds lookups.Credentials) (map[lookups.Environment]*lookups.Lookups, *lookups.Environments, error) {
+	var declared []lookups.Environment
+	seen := map[lookups.Environment]bool{}
+	for _, ref := range refs {
+		if seen[ref.Environment] {
+			continue
+		}
+		seen[ref.Environment] = true
+		if ref.Environment == (lookups.Environment{}) {
+			declared = append([]lookups.Environment{ref.Environment}, declared...)
+		} else {
+			declared = append(declared, ref.Environment)
+		}
+	}
+
+	byEnv := map[lookups.Environment]*lookups.Lookups{}
+	if backend.replayFile != "" {
+		logger.Info("Replaying AWS responses from cassette", "file", backend.replayFile)
+		cc, err := lookups.NewReplayLookups(backend.replayFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load replay cassette: %w", err)
+		}
+		for _, env := range declared {
+			if (env.Region != "" && env.Region != cc.Region) || (env.Account != "" && env.Account != cc.Account) {
+				return nil, nil, fmt.Errorf("the cassette was recorded in %s/%s, not %s; --replay only supports stacks from a single AWS environment",
+					cc.Account, cc.Region, env)
+			}
+			byEnv[env] = cc
+		}
+		return byEnv, lookups.NewEnvironments(cc), nil
 	}
 
-	return proxy.RunPulumiUpWithProxies(ctx, logger, cc, ".", options)
+	type accountRegion struct{ account, region string }
+	shared := map[accountRegion]*lookups.Lookups{}
+	var all []*lookups.Lookups
+	for _, env := range declared {
+		cc, err := lookups.NewEnvironmentLookups(ctx, env, creds)
+		if err != nil {
+			if env == (lookups.Environment{}) {
+				return nil, nil, fmt.Errorf("failed to initialize AWS clients (set AWS_REGION or AWS_DEFAULT_REGION if not already configured): %w", err)
+			}
+			return nil, nil, fmt.Errorf("failed to initialize AWS clients for %s: %w", env, err)
+		}
+		key := accountRegion{cc.Account, cc.Region}
+		if existing, ok := shared[key]; ok {
+			cc = existing
+		} else {
+			shared[key] = cc
+			all = append(all, cc)
+		}
+		byEnv[env] = cc
+	}
+	if len(all) > 1 {
+		for _, cc := range all {
+			logger.Info("Importing from AWS environment", "region", cc.Region, "account", cc.Account, "profile", cc.Profile)
+		}
+	}
+	return byEnv, lookups.NewEnvironments(all[0], all[1:]...), nil
 }
 
 func validateConfig(cfg runConfig) error {
@@ -106,6 +355,14 @@ func validateConfig(cfg runConfig) error {
 	if cfg.workDir == "" {
 		return fmt.Errorf("program directory is required")
 	}
+	if cfg.mode == proxy.Verify {
+		if cfg.importFile != "" {
+			return fmt.Errorf("--import-file is not supported in verify mode")
+		}
+		if cfg.keepImportState || cfg.localStackFile != "" {
+			return fmt.Errorf("verify mode always uses a throwaway backend")
+		}
+	}
 	if cfg.mode == proxy.RunPulumi {
 		if cfg.keepImportState {
 			return fmt.Errorf("--keep-import-state is only supported in iterate mode")
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+)
+
+func TestLoadEnvironmentsReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	cassette := &lookups.Cassette{Version: 1, Region: "us-east-1", Account: "123456789012"}
+	if err := cassette.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	parse := func(stacks ...string) []lookups.StackRef {
+		var refs []lookups.StackRef
+		for _, s := range stacks {
+			ref, err := lookups.ParseStackRef(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			refs = append(refs, ref)
+		}
+		return refs
+	}
+
+	refs := parse("App", "123456789012/us-east-1:Certs")
+	byEnv, envs, err := loadEnvironments(context.Background(), logger, refs, awsBackend{replayFile: path}, lookups.Credentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(envs.All()) != 1 || byEnv[refs[0].Environment] != byEnv[refs[1].Environment] {
+		t.Fatalf("expected both stacks to share the replayed environment, got %d environments", len(envs.All()))
+	}
+
+	_, _, err = loadEnvironments(context.Background(), logger, parse("App", "eu-west-1:Other"), awsBackend{replayFile: path}, lookups.Credentials{})
+	if err == nil || !strings.Contains(err.Error(), "not eu-west-1") {
+		t.Fatalf("expected a stack in another region to be rejected, got %v", err)
+	}
+}
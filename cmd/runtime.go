@@ -11,6 +11,11 @@ import (
 func newRuntimeCommand() *cobra.Command {
 	var stacks stringSlice
 	var importFile string
+	var importReferences bool
+	var reportFile string
+	var lookupOpts lookupOptions
+	var providerOpts providerOptions
+	var checkpointOpts checkpointOptions
 	var skipCreate bool
 
 	cmd := &cobra.Command{
@@ -26,6 +31,9 @@ func newRuntimeCommand() *cobra.Command {
 				mode:            proxy.RunPulumi,
 				stacks:          stacks,
 				importFile:      resolvePath(invocationDir, importFile),
+				importRefs:      importReferences,
+				reportFile:      resolvePath(invocationDir, reportFile),
+				lookups:         lookupOpts.resolved(invocationDir),
 				skipCreate:      skipCreate,
 				workDir:         invocationDir,
 				invocationDir:   invocationDir,
@@ -33,15 +41,23 @@ func newRuntimeCommand() *cobra.Command {
 				localStackFile:  "",
 				debugLogging:    debugLogging,
 				verbose:         verbose,
+				backend:         currentAWSBackend(invocationDir),
+				providers:       providerOpts.resolved(invocationDir),
+				checkpoint:      checkpointOpts.resolved(invocationDir),
 			}
 			return run(cfg)
 		},
 	}
 
-	cmd.Flags().Var(&stacks, "stack", "CloudFormation stack name (can be specified multiple times or comma-separated)")
+	cmd.Flags().Var(&stacks, "stack", "CloudFormation stack name, optionally written as [profile@][account/]region:StackName (can be specified multiple times or comma-separated)")
 	_ = cmd.MarkFlagRequired("stack")
 	cmd.Flags().StringVar(&importFile, "import-file", "", "Path to write a Pulumi bulk import file after importing into the selected stack (default: import.json when provided without a value)")
 	cmd.Flags().Lookup("import-file").NoOptDefVal = defaultImportFileName
+	cmd.Flags().BoolVar(&importReferences, "import-file-references", false, "Emit the parent and provider of each resource in the import file, for programs with explicit providers or component hierarchies (default: import with the default provider and no parent)")
+	addLookupFlags(cmd, &lookupOpts)
+	addProviderFlags(cmd, &providerOpts)
+	addCheckpointFlags(cmd, &checkpointOpts)
+	cmd.Flags().StringVar(&reportFile, "report", "", "Path to write a JSON report describing how each resource was resolved and whether it was imported")
 	cmd.Flags().BoolVar(&skipCreate, "skip-create", false, "Skip creation of special resources and only capture metadata")
 
 	return cmd
@@ -5,10 +5,12 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
@@ -64,6 +66,7 @@ type Summary struct {
 
 // SkippedResource records resources we did not include in the output.
 type SkippedResource struct {
+	StackName    common.StackName
 	LogicalID    common.LogicalResourceID
 	ResourceType common.ResourceType
 	Reason       string
@@ -71,32 +74,62 @@ type SkippedResource struct {
 
 // PlaceholderEntry captures resources where we could not determine an ID.
 type PlaceholderEntry struct {
+	StackName    common.StackName
 	LogicalID    common.LogicalResourceID
 	ResourceType common.ResourceType
 	Error        string
 }
 
+// TokenSet selects the provider whose resource tokens an import file is built for.
+type TokenSet string
+
+const (
+	// TokenSetAWS builds the import file for the aws provider.
+	TokenSetAWS TokenSet = "aws"
+	// TokenSetAWSNative builds the import file for the aws-native provider.
+	TokenSetAWSNative TokenSet = "aws-native"
+)
+
+// ParseTokenSet validates a token set name.
+func ParseTokenSet(name string) (TokenSet, error) {
+	switch set := TokenSet(name); set {
+	case TokenSetAWS, TokenSetAWSNative:
+		return set, nil
+	}
+	return "", fmt.Errorf("unknown token set %q, expected %q or %q", name, TokenSetAWS, TokenSetAWSNative)
+}
+
 // BuildImportFile enumerates the CloudFormation stack resources tracked by the
-// provided lookups instance and returns an import file plus a summary.
-func BuildImportFile(ctx context.Context, l *lookups.Lookups) (*File, *Summary, error) {
+// provided lookups instance and returns an import file for the token set plus a summary.
+// The CloudFormation physical ID is used as the import ID, unless the lookups have an ID override
+// for the resource.
+func BuildImportFile(ctx context.Context, l *lookups.Lookups, set TokenSet) (*File, *Summary, error) {
 	summary := &Summary{
 		TotalResources: len(l.CfnStackResources),
 	}
 
-	keys := make([]string, 0, len(l.CfnStackResources))
-	for logical := range l.CfnStackResources {
-		keys = append(keys, string(logical))
+	keys := make([]lookups.StackResourceKey, 0, len(l.CfnStackResources))
+	stacksByLogicalID := map[common.LogicalResourceID]int{}
+	for key := range l.CfnStackResources {
+		keys = append(keys, key)
+		stacksByLogicalID[key.LogicalID]++
 	}
-	sort.Strings(keys)
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].StackName == keys[j].StackName {
+			return keys[i].LogicalID < keys[j].LogicalID
+		}
+		return keys[i].StackName < keys[j].StackName
+	})
 
 	resourceEntries := make([]Resource, 0, len(keys))
-	metadataSrc := metadata.NewAwsMetadataSource()
+	nativeSrc := metadata.NewCCApiMetadataSource()
 
-	for _, logicalKey := range keys {
-		logicalID := common.LogicalResourceID(logicalKey)
-		stackResource := l.CfnStackResources[logicalID]
+	for _, key := range keys {
+		logicalID := key.LogicalID
+		stackResource := l.CfnStackResources[key]
 		if skip, reason := shouldSkipResource(stackResource.ResourceType); skip {
 			summary.SkippedResources = append(summary.SkippedResources, SkippedResource{
+				StackName:    key.StackName,
 				LogicalID:    logicalID,
 				ResourceType: stackResource.ResourceType,
 				Reason:       reason,
@@ -104,12 +137,10 @@ func BuildImportFile(ctx context.Context, l *lookups.Lookups) (*File, *Summary,
 			continue
 		}
 
-		token, ok := metadataSrc.ResourceToken(stackResource.ResourceType)
-		if !ok {
-			token, ok = defaultClassicTokenFromCFType(stackResource.ResourceType)
-		}
-		if !ok {
+		targets := resourceTokens(set, stackResource.ResourceType)
+		if len(targets) == 0 {
 			summary.SkippedResources = append(summary.SkippedResources, SkippedResource{
+				StackName:    key.StackName,
 				LogicalID:    logicalID,
 				ResourceType: stackResource.ResourceType,
 				Reason:       "unsupported resource type",
@@ -118,21 +149,58 @@ func BuildImportFile(ctx context.Context, l *lookups.Lookups) (*File, *Summary,
 		}
 
 		id := string(stackResource.PhysicalID)
-		if id == "" {
+		if override, ok := l.IDOverrides.ForResource(key); ok {
+			id = string(override)
+		} else if id == "" {
 			summary.PlaceholderEntries = append(summary.PlaceholderEntries, PlaceholderEntry{
+				StackName:    key.StackName,
 				LogicalID:    logicalID,
 				ResourceType: stackResource.ResourceType,
 				Error:        "missing physical ID",
 			})
 			id = placeholderID
+		} else if set == TokenSetAWSNative {
+			// A composite CCAPI identifier needs more than the physical ID; it is resolved by the
+			// other commands, which read the resource properties.
+			props, _ := nativeSrc.PrimaryIdentifier(targets[0])
+			reason := ""
+			switch {
+			case len(props) > 1:
+				reason = fmt.Sprintf("composite primary identifier %v", props)
+			case len(props) == 1 && lookups.NativeIDNeedsLookup(stackResource.ResourceType, props[0], stackResource.PhysicalID):
+				// e.g. an ARN-identified type whose physical ID is a name.
+				reason = fmt.Sprintf("primary identifier %s is not the physical ID", props[0])
+			}
+			if reason != "" {
+				summary.PlaceholderEntries = append(summary.PlaceholderEntries, PlaceholderEntry{
+					StackName:    key.StackName,
+					LogicalID:    logicalID,
+					ResourceType: stackResource.ResourceType,
+					Error:        reason,
+				})
+				id = placeholderID
+			}
 		}
 
-		resourceEntries = append(resourceEntries, Resource{
-			Type:        string(token),
-			Name:        resourceName(logicalID),
-			ID:          id,
-			LogicalName: string(logicalID),
-		})
+		name := resourceName(logicalID)
+		if stacksByLogicalID[logicalID] > 1 {
+			// The same logical ID exists in several stacks; qualify the name so entries stay unique.
+			name = resourceName(common.LogicalResourceID(fmt.Sprintf("%s-%s", key.StackName, logicalID)))
+		}
+		for i, token := range targets {
+			entryName := name
+			if i > 0 {
+				// The resources split out of the CloudFormation resource share its logical name,
+				// which their types keep apart, but need their own names in generated code.
+				entryName = fmt.Sprintf("%s-%s", name, token.Name())
+			}
+			resourceEntries = append(resourceEntries, Resource{
+				Type:        string(token),
+				Name:        entryName,
+				ID:          id,
+				LogicalName: string(logicalID),
+			})
+		}
 	}
 
 	sort.Slice(resourceEntries, func(i, j int) bool {
@@ -146,6 +214,26 @@ func BuildImportFile(ctx context.Context, l *lookups.Lookups) (*File, *Summary,
 	}, summary, nil
 }
 
+// WriteSummary prints the resource counts of summary followed by a table of the skipped and
+// placeholder entries.
+func WriteSummary(w io.Writer, summary *Summary) error {
+	fmt.Fprintf(w, "%d CloudFormation resources: %d skipped, %d with placeholder IDs; import file entries: %d\n",
+		summary.TotalResources, len(summary.SkippedResources), len(summary.PlaceholderEntries), summary.EmittedResources)
+	if len(summary.SkippedResources) == 0 && len(summary.PlaceholderEntries) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STACK\tLOGICAL ID\tTYPE\tSTATUS\tREASON")
+	for _, e := range summary.PlaceholderEntries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\tplaceholder\t%s\n", e.StackName, e.LogicalID, e.ResourceType, e.Error)
+	}
+	for _, e := range summary.SkippedResources {
+		fmt.Fprintf(tw, "%s\t%s\t%s\tskipped\t%s\n", e.StackName, e.LogicalID, e.ResourceType, e.Reason)
+	}
+	return tw.Flush()
+}
+
 // WriteFile marshals the File as prettified JSON.
 func WriteFile(path string, file *File) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
@@ -197,6 +285,24 @@ func shouldSkipResource(resourceType common.ResourceType) (bool, string) {
 	return false, ""
 }
 
+// resourceTokens returns the Pulumi resources of the token set a CloudFormation resource of
+// resourceType is imported as, the primary resource first.
+func resourceTokens(set TokenSet, resourceType common.ResourceType) []tokens.Type {
+	if set == TokenSetAWSNative {
+		if token, ok := metadata.NewCCApiMetadataSource().ResourceToken(resourceType); ok {
+			return []tokens.Type{token}
+		}
+		return nil
+	}
+	if targets := metadata.NewAwsMetadataSource().ResourceTokens(resourceType); len(targets) > 0 {
+		return targets
+	}
+	if token, ok := defaultClassicTokenFromCFType(resourceType); ok {
+		return []tokens.Type{token}
+	}
+	return nil
+}
+
 func defaultClassicTokenFromCFType(resourceType common.ResourceType) (tokens.Type, bool) {
 	parts := strings.Split(string(resourceType), "::")
 	if len(parts) != 3 || parts[0] != "AWS" {
@@ -15,8 +15,8 @@ func TestBuildImportFileBasicBucket(t *testing.T) {
 	l := &lookups.Lookups{
 		Region:  "us-west-2",
 		Account: "123456789012",
-		CfnStackResources: map[common.LogicalResourceID]lookups.CfnStackResource{
-			common.LogicalResourceID("Bucket"): {
+		CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+			{StackName: "Stack", LogicalID: "Bucket"}: {
 				ResourceType: "AWS::S3::Bucket",
 				LogicalID:    common.LogicalResourceID("Bucket"),
 				PhysicalID:   common.PhysicalResourceID("my-bucket"),
@@ -24,25 +24,36 @@ func TestBuildImportFileBasicBucket(t *testing.T) {
 		},
 	}
 
-	file, summary, err := BuildImportFile(context.Background(), l)
+	file, summary, err := BuildImportFile(context.Background(), l, TokenSetAWS)
 	require.NoError(t, err)
-	assert.Equal(t, 1, summary.EmittedResources)
-	assert.Len(t, file.Resources, 1)
-
-	resource := file.Resources[0]
-	assert.Equal(t, "aws:s3/bucket:Bucket", resource.Type)
-	assert.Equal(t, "Bucket", resource.Name)
-	assert.Equal(t, "my-bucket", resource.ID)
-	assert.Equal(t, "Bucket", resource.LogicalName)
+	assert.Equal(t, 2, summary.EmittedResources)
 	assert.Empty(t, summary.PlaceholderEntries)
+
+	// The bucket is split into the bucket and its versioning, both imported by name. Encryption and
+	// lifecycle configuration may not exist, so they are left out.
+	var types, names []string
+	for _, resource := range file.Resources {
+		types = append(types, resource.Type)
+		names = append(names, resource.Name)
+		assert.Equal(t, "my-bucket", resource.ID)
+		assert.Equal(t, "Bucket", resource.LogicalName)
+	}
+	assert.Equal(t, []string{
+		"aws:s3/bucketV2:BucketV2",
+		"aws:s3/bucketVersioningV2:BucketVersioningV2",
+	}, types)
+	assert.Equal(t, []string{
+		"Bucket",
+		"Bucket-BucketVersioningV2",
+	}, names)
 }
 
 func TestBuildImportFilePlaceholderWhenIdUnknown(t *testing.T) {
 	l := &lookups.Lookups{
 		Region:  "us-west-2",
 		Account: "123456789012",
-		CfnStackResources: map[common.LogicalResourceID]lookups.CfnStackResource{
-			common.LogicalResourceID("Route"): {
+		CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+			{StackName: "Stack", LogicalID: "Route"}: {
 				ResourceType: "AWS::ApiGatewayV2::Route",
 				LogicalID:    common.LogicalResourceID("Route"),
 				PhysicalID:   "",
@@ -50,7 +61,7 @@ func TestBuildImportFilePlaceholderWhenIdUnknown(t *testing.T) {
 		},
 	}
 
-	file, summary, err := BuildImportFile(context.Background(), l)
+	file, summary, err := BuildImportFile(context.Background(), l, TokenSetAWS)
 	require.NoError(t, err)
 	assert.Equal(t, 1, summary.EmittedResources)
 	assert.Len(t, summary.PlaceholderEntries, 1)
@@ -66,21 +77,143 @@ func TestBuildImportFileSkipsUnsupportedTypes(t *testing.T) {
 	l := &lookups.Lookups{
 		Region:  "us-west-2",
 		Account: "123456789012",
-		CfnStackResources: map[common.LogicalResourceID]lookups.CfnStackResource{
-			common.LogicalResourceID("Metadata"): {
+		CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+			{StackName: "Stack", LogicalID: "Metadata"}: {
 				ResourceType: "AWS::CDK::Metadata",
 				LogicalID:    common.LogicalResourceID("Metadata"),
 			},
 		},
 	}
 
-	file, summary, err := BuildImportFile(context.Background(), l)
+	file, summary, err := BuildImportFile(context.Background(), l, TokenSetAWS)
 	require.NoError(t, err)
 	assert.Equal(t, 0, summary.EmittedResources)
 	assert.Len(t, summary.SkippedResources, 1)
 	assert.Empty(t, file.Resources)
 }
 
+func TestBuildImportFileQualifiesNamesAcrossStacks(t *testing.T) {
+	l := &lookups.Lookups{
+		Region:  "us-west-2",
+		Account: "123456789012",
+		CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+			{StackName: "StackA", LogicalID: "Queue"}: {
+				ResourceType: "AWS::SQS::Queue",
+				LogicalID:    "Queue",
+				PhysicalID:   "queue-a",
+				StackName:    "StackA",
+			},
+			{StackName: "StackB", LogicalID: "Queue"}: {
+				ResourceType: "AWS::SQS::Queue",
+				LogicalID:    "Queue",
+				PhysicalID:   "queue-b",
+				StackName:    "StackB",
+			},
+		},
+	}
+
+	file, summary, err := BuildImportFile(context.Background(), l, TokenSetAWS)
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.EmittedResources)
+	if assert.Len(t, file.Resources, 2) {
+		assert.Equal(t, "StackA-Queue", file.Resources[0].Name)
+		assert.Equal(t, "queue-a", file.Resources[0].ID)
+		assert.Equal(t, "StackB-Queue", file.Resources[1].Name)
+		assert.Equal(t, "queue-b", file.Resources[1].ID)
+	}
+}
+
+func TestBuildImportFileAWSNativeTokens(t *testing.T) {
+	l := &lookups.Lookups{
+		Region:  "us-west-2",
+		Account: "123456789012",
+		CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+			{StackName: "Stack", LogicalID: "Bucket"}: {
+				ResourceType: "AWS::S3::Bucket",
+				LogicalID:    "Bucket",
+				PhysicalID:   "my-bucket",
+			},
+			{StackName: "Stack", LogicalID: "ApiResource"}: {
+				ResourceType: "AWS::ApiGateway::Resource",
+				LogicalID:    "ApiResource",
+				PhysicalID:   "abc123",
+			},
+			{StackName: "Stack", LogicalID: "Unknown"}: {
+				ResourceType: "AWS::Unknown::Thing",
+				LogicalID:    "Unknown",
+				PhysicalID:   "thing",
+			},
+		},
+	}
+
+	file, summary, err := BuildImportFile(context.Background(), l, TokenSetAWSNative)
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.EmittedResources)
+	if assert.Len(t, file.Resources, 2) {
+		assert.Equal(t, "aws-native:apigateway:Resource", file.Resources[0].Type)
+		assert.Equal(t, placeholderID, file.Resources[0].ID, "composite identifiers are not the physical ID")
+		assert.Equal(t, "aws-native:s3:Bucket", file.Resources[1].Type)
+		assert.Equal(t, "my-bucket", file.Resources[1].ID)
+	}
+	if assert.Len(t, summary.PlaceholderEntries, 1) {
+		assert.Equal(t, common.LogicalResourceID("ApiResource"), summary.PlaceholderEntries[0].LogicalID)
+	}
+	if assert.Len(t, summary.SkippedResources, 1) {
+		assert.Equal(t, "unsupported resource type", summary.SkippedResources[0].Reason)
+	}
+}
+
+func TestBuildImportFileNativeIDsThatNeedLookups(t *testing.T) {
+	overrides, err := lookups.NewIDOverrides(map[string]string{"Stack/Topic": "arn:aws:sns:us-west-2:123456789012:topic"})
+	require.NoError(t, err)
+	l := &lookups.Lookups{
+		Region:  "us-west-2",
+		Account: "123456789012",
+		CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+			{StackName: "Stack", LogicalID: "Rule"}: {
+				ResourceType: "AWS::Events::Rule",
+				LogicalID:    "Rule",
+				PhysicalID:   "my-rule",
+			},
+			{StackName: "Stack", LogicalID: "Policy"}: {
+				ResourceType: "AWS::IAM::ManagedPolicy",
+				LogicalID:    "Policy",
+				PhysicalID:   "arn:aws:iam::123456789012:policy/my-policy",
+			},
+			{StackName: "Stack", LogicalID: "Topic"}: {
+				ResourceType: "AWS::SNS::Topic",
+				LogicalID:    "Topic",
+				PhysicalID:   "topic",
+			},
+		},
+		IDOverrides: overrides,
+	}
+
+	file, summary, err := BuildImportFile(context.Background(), l, TokenSetAWSNative)
+	require.NoError(t, err)
+	ids := map[string]string{}
+	for _, res := range file.Resources {
+		ids[res.Name] = res.ID
+	}
+	assert.Equal(t, map[string]string{
+		"Rule":   placeholderID,
+		"Policy": "arn:aws:iam::123456789012:policy/my-policy",
+		"Topic":  "arn:aws:sns:us-west-2:123456789012:topic",
+	}, ids, "an ARN identifier is only the physical ID when that is an ARN, and overrides win")
+	if assert.Len(t, summary.PlaceholderEntries, 1) {
+		assert.Equal(t, common.LogicalResourceID("Rule"), summary.PlaceholderEntries[0].LogicalID)
+		assert.Equal(t, "primary identifier arn is not the physical ID", summary.PlaceholderEntries[0].Error)
+	}
+}
+
+func TestParseTokenSet(t *testing.T) {
+	set, err := ParseTokenSet("aws-native")
+	require.NoError(t, err)
+	assert.Equal(t, TokenSetAWSNative, set)
+	_, err = ParseTokenSet("gcp")
+	assert.ErrorContains(t, err, `unknown token set "gcp"`)
+}
+
 func TestFilterPlaceholderResources(t *testing.T) {
 	original := &File{
 		NameTable: map[string]string{
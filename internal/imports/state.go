@@ -134,21 +134,6 @@ func sortResources(resources []Resource) {
 	})
 }
 
-func buildNameTable(resources []apitype.ResourceV3) map[string]string {
-	table := make(map[string]string, len(resources))
-	for _, res := range resources {
-		name := res.URN.Name()
-		if name == "" {
-			continue
-		}
-		if _, exists := table[name]; exists {
-			continue
-		}
-		table[name] = string(res.URN)
-	}
-	return table
-}
-
 func indexCaptures(captures []CaptureMetadata) map[string]CaptureMetadata {
 	idx := make(map[string]CaptureMetadata, len(captures))
 	for _, capture := range captures {
@@ -203,7 +188,202 @@ func readString(props map[string]any, key string) string {
 	return ""
 }
 
-func resolveParentName(parent resource.URN) (string, error) {
+// ResourceReferences are the parent and provider reference of a resource, as reported by the
+// engine when the resource was registered.
+type ResourceReferences struct {
+	URN       string
+	Parent    string
+	Provider  string
+	Component bool
+}
+
+// Target is the stack an import file is imported into. It is usually not the stack the references
+// were captured from, so references are rewritten to its stack and project.
+type Target struct {
+	Stack   string
+	Project string
+	// Deployment is the exported state of the target stack; it is empty for a new stack.
+	Deployment apitype.UntypedDeployment
+}
+
+// urn rewrites a URN of the captured stack to the target stack. URNs are kept as they are when the
+// target isn't known.
+func (t Target) urn(urn resource.URN) resource.URN {
+	if t.Stack == "" || t.Project == "" {
+		return urn
+	}
+	return resource.NewURN(tokens.QName(t.Stack), tokens.PackageName(t.Project), "", urn.QualifiedType(), urn.Name())
+}
+
+// urns returns the URNs in the state of the target stack.
+func (t Target) urns() (map[resource.URN]bool, error) {
+	typed, err := unmarshalDeployment(t.Deployment)
+	if err != nil {
+		return nil, err
+	}
+	urns := map[resource.URN]bool{}
+	if typed != nil {
+		for _, res := range typed.Resources {
+			urns[res.URN] = true
+		}
+	}
+	return urns, nil
+}
+
+// ResolveReferences sets the parent and provider of every resource in file and builds the name table
+// they refer to. References are read from the exported state, falling back to registrations for
+// resources that never made it into state, and are rewritten to the target stack. Parents that are
+// imported themselves are referenced by name, and component parents missing from the target stack
+// are added as component entries so the import creates them. The root stack and default providers
+// are left out, so those resources still import without a parent or with the default provider.
+func ResolveReferences(file *File, deployment apitype.UntypedDeployment, registrations []ResourceReferences, target Target) error {
+	typed, err := unmarshalDeployment(deployment)
+	if err != nil {
+		return err
+	}
+	existing, err := target.urns()
+	if err != nil {
+		return fmt.Errorf("reading target stack state: %w", err)
+	}
+	refs := make(map[resource.URN]ResourceReferences, len(registrations))
+	keys := make(map[string]resource.URN, len(registrations))
+	for _, reg := range registrations {
+		urn, err := resource.ParseURN(reg.URN)
+		if err != nil {
+			continue
+		}
+		refs[urn] = reg
+		keys[captureKey(string(urn.Type()), urn.Name())] = urn
+	}
+	if typed != nil {
+		for _, res := range typed.Resources {
+			refs[res.URN] = ResourceReferences{
+				URN:       string(res.URN),
+				Parent:    string(res.Parent),
+				Provider:  res.Provider,
+				Component: !res.Custom,
+			}
+			keys[captureKey(string(res.Type), res.URN.Name())] = res.URN
+		}
+	}
+
+	r := &referenceResolver{
+		refs:     refs,
+		existing: existing,
+		target:   target,
+		names:    newNameTable(),
+		entries:  map[resource.URN]string{},
+	}
+	for _, res := range file.Resources {
+		if urn, ok := keys[captureKey(res.Type, res.Name)]; ok && r.names.reserve(res.Name, urn) {
+			r.entries[urn] = res.Name
+		}
+	}
+	for i := range file.Resources {
+		res := &file.Resources[i]
+		res.Parent, res.Provider = "", ""
+		urn, ok := keys[captureKey(res.Type, res.Name)]
+		if !ok {
+			continue
+		}
+		ref := refs[urn]
+		if res.Parent, err = r.parentName(resource.URN(ref.Parent)); err != nil {
+			return fmt.Errorf("resolving parent of %s: %w", ref.URN, err)
+		}
+		if res.Provider, err = r.providerName(ref.Provider); err != nil {
+			return fmt.Errorf("resolving provider of %s: %w", ref.URN, err)
+		}
+	}
+	// Components go first, each after its own parent, so the import creates them before their children.
+	file.Resources = append(r.components, file.Resources...)
+	file.NameTable = r.names.table
+	return nil
+}
+
+// ValidateReferences checks that every name table entry is a valid URN of a resource in the target
+// stack, and that every parent and provider in file is in its name table or, for parents, is another
+// resource in the file. Name table entries aren't looked up when the target stack isn't known.
+func ValidateReferences(file *File, target Target) error {
+	problems := nameTableProblems(file.NameTable)
+	names := resourceNames(file)
+	for _, res := range file.Resources {
+		problems = append(problems, referenceProblems(res, file.NameTable, names)...)
+	}
+	if target.Stack != "" {
+		existing, err := target.urns()
+		if err != nil {
+			return fmt.Errorf("reading target stack state: %w", err)
+		}
+		for _, name := range sortedKeys(file.NameTable) {
+			if urn := resource.URN(file.NameTable[name]); urn.IsValid() && !existing[urn] {
+				problems = append(problems, fmt.Sprintf("name table entry %q is not in stack %s: %s", name, target.Stack, urn))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid import file references: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// nameTableProblems describes the name table entries that aren't valid URNs, in name order.
+func nameTableProblems(table map[string]string) []string {
+	var problems []string
+	for _, name := range sortedKeys(table) {
+		if urn := resource.URN(table[name]); !urn.IsValid() {
+			problems = append(problems, fmt.Sprintf("name table entry %q is not a valid URN: %q", name, urn))
+		}
+	}
+	return problems
+}
+
+func sortedKeys(table map[string]string) []string {
+	keys := make([]string, 0, len(table))
+	for name := range table {
+		keys = append(keys, name)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// resourceNames returns the names of the resources in file, which parents can refer to.
+func resourceNames(file *File) map[string]bool {
+	names := make(map[string]bool, len(file.Resources))
+	for _, res := range file.Resources {
+		names[res.Name] = true
+	}
+	return names
+}
+
+// referenceProblems describes the parent and provider of res that refer to neither the name table
+// nor, for parents, another resource in the file.
+func referenceProblems(res Resource, table map[string]string, names map[string]bool) []string {
+	var problems []string
+	if _, ok := table[res.Parent]; res.Parent != "" && !ok && (!names[res.Parent] || res.Parent == res.Name) {
+		problems = append(problems, fmt.Sprintf("parent %q of %s %q is neither in the name table nor a resource in the file", res.Parent, res.Type, res.Name))
+	}
+	if _, ok := table[res.Provider]; res.Provider != "" && !ok {
+		problems = append(problems, fmt.Sprintf("provider %q of %s %q is not in the name table", res.Provider, res.Type, res.Name))
+	}
+	return problems
+}
+
+// referenceResolver turns the parent and provider URNs of the captured stack into names in an import
+// file for the target stack.
+type referenceResolver struct {
+	refs     map[resource.URN]ResourceReferences
+	existing map[resource.URN]bool
+	target   Target
+	names    *nameTable
+	// entries maps the URNs of resources in the import file to their names.
+	entries    map[resource.URN]string
+	components []Resource
+}
+
+// parentName references a parent that is in the import file by its name, and one in the target
+// stack through the name table. Any other component parent is added to the file as a component
+// entry; custom parents are left in the name table for ValidateReferences to report.
+func (r *referenceResolver) parentName(parent resource.URN) (string, error) {
 	if parent == "" {
 		return "", nil
 	}
@@ -213,19 +393,117 @@ func resolveParentName(parent resource.URN) (string, error) {
 	if parent.QualifiedType() == resource.RootStackType {
 		return "", nil
 	}
-	return parent.Name(), nil
+	if name, ok := r.entries[parent]; ok {
+		return name, nil
+	}
+	ref, known := r.refs[parent]
+	if r.existing[r.target.urn(parent)] || (known && !ref.Component) {
+		return r.names.entry(parent, r.target.urn(parent)), nil
+	}
+
+	name := r.names.name(parent)
+	r.entries[parent] = name
+	component := Resource{Type: string(parent.Type()), Name: name, Component: true}
+	if name != parent.Name() {
+		component.LogicalName = parent.Name()
+	}
+	var err error
+	if component.Parent, err = r.parentName(resource.URN(ref.Parent)); err != nil {
+		return "", fmt.Errorf("resolving parent of %s: %w", parent, err)
+	}
+	r.components = append(r.components, component)
+	return name, nil
 }
 
-func resolveProvider(provider string, providers map[string]providerDetails) (string, string, error) {
+func (r *referenceResolver) providerName(provider string) (string, error) {
 	if provider == "" {
-		return "", "", nil
+		return "", nil
 	}
-	if details, ok := providers[provider]; ok {
-		return details.name, details.version, nil
+	urn, err := providerURN(provider)
+	if err != nil {
+		return "", err
+	}
+	if isDefaultProvider(urn) {
+		return "", nil
+	}
+	return r.names.entry(urn, r.target.urn(urn)), nil
+}
+
+// nameTable assigns each referenced URN a unique name, shared by the name table and the resources in
+// the import file.
+type nameTable struct {
+	table map[string]string
+	names map[resource.URN]string
+	taken map[string]resource.URN
+}
+
+func newNameTable() *nameTable {
+	return &nameTable{
+		table: map[string]string{},
+		names: map[resource.URN]string{},
+		taken: map[string]resource.URN{},
+	}
+}
+
+// reserve claims name for urn, reporting false if another URN already has it.
+func (t *nameTable) reserve(name string, urn resource.URN) bool {
+	if existing, ok := t.taken[name]; ok {
+		return existing == urn
+	}
+	t.taken[name] = urn
+	t.names[urn] = name
+	return true
+}
+
+// name returns the name of urn, picking an unused one based on its resource name.
+func (t *nameTable) name(urn resource.URN) string {
+	if name, ok := t.names[urn]; ok {
+		return name
+	}
+	base := urn.Name()
+	name := base
+	for n := 2; !t.reserve(name, urn); n++ {
+		name = fmt.Sprintf("%s-%d", base, n)
+	}
+	return name
+}
+
+// entry names urn and adds the name to the name table, referring to target.
+func (t *nameTable) entry(urn, target resource.URN) string {
+	name := t.name(urn)
+	t.table[name] = string(target)
+	return name
+}
+
+// providerURN returns the URN of a provider reference, which state writes as `<urn>::<id>`.
+func providerURN(provider string) (resource.URN, error) {
+	if i := strings.LastIndex(provider, "::"); i > 0 {
+		if urn := resource.URN(provider[:i]); urn.IsValid() {
+			return urn, nil
+		}
 	}
 	urn, err := resource.ParseURN(provider)
 	if err != nil {
-		return "", "", fmt.Errorf("invalid provider URN %q: %w", provider, err)
+		return "", fmt.Errorf("invalid provider URN %q: %w", provider, err)
+	}
+	return urn, nil
+}
+
+// isDefaultProvider matches the providers the engine creates for resources without an explicit one.
+func isDefaultProvider(urn resource.URN) bool {
+	return strings.HasPrefix(string(urn.Type()), "pulumi:providers:") && strings.HasPrefix(urn.Name(), "default")
+}
+
+func resolveProvider(provider string, providers map[string]providerDetails) (string, string, error) {
+	if provider == "" {
+		return "", "", nil
+	}
+	urn, err := providerURN(provider)
+	if err != nil {
+		return "", "", err
+	}
+	if details, ok := providers[string(urn)]; ok {
+		return details.name, details.version, nil
 	}
 	return urn.Name(), "", nil
 }
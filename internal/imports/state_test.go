@@ -130,3 +130,192 @@ func TestBuildFilePrefersCaptureIDWhenStateIDDiffers(t *testing.T) {
 	assert.Equal(t, "api-123/stage", res.ID)
 	assert.Equal(t, "StageLogical", res.LogicalName)
 }
+
+func TestResolveReferences(t *testing.T) {
+	t.Parallel()
+
+	stackURN := resource.URN("urn:pulumi:capture-app::proj::pulumi:pulumi:Stack::proj-capture-app")
+	componentURN := resource.URN("urn:pulumi:capture-app::proj::cdk:index:Stack::app")
+	defaultProviderURN := resource.URN("urn:pulumi:capture-app::proj::pulumi:providers:aws::default_7_11_0")
+	regionProviderURN := resource.URN("urn:pulumi:capture-app::proj::pulumi:providers:aws::us-east-1")
+	bucketURN := resource.URN("urn:pulumi:capture-app::proj::cdk:index:Stack$aws:s3/bucket:Bucket::bucket")
+	queueURN := resource.URN("urn:pulumi:capture-app::proj::aws:sqs/queue:Queue::queue")
+	topicURN := "urn:pulumi:capture-app::proj::cdk:index:Stack$aws:sns/topic:Topic::topic"
+
+	deployment := apitype.DeploymentV3{
+		Resources: []apitype.ResourceV3{
+			{URN: stackURN, Type: tokens.Type("pulumi:pulumi:Stack")},
+			{URN: componentURN, Type: tokens.Type("cdk:index:Stack"), Parent: stackURN},
+			{URN: defaultProviderURN, Type: tokens.Type("pulumi:providers:aws"), Custom: true, ID: "default-id"},
+			{
+				URN:    regionProviderURN,
+				Type:   tokens.Type("pulumi:providers:aws"),
+				Custom: true,
+				ID:     "region-id",
+				Parent: stackURN,
+				Inputs: map[string]any{"version": "7.11.0"},
+			},
+			{
+				URN:      bucketURN,
+				Type:     tokens.Type("aws:s3/bucket:Bucket"),
+				Custom:   true,
+				ID:       "bucket-123",
+				Parent:   componentURN,
+				Provider: string(regionProviderURN) + "::region-id",
+			},
+			{
+				URN:      queueURN,
+				Type:     tokens.Type("aws:sqs/queue:Queue"),
+				Custom:   true,
+				ID:       "queue-url",
+				Parent:   stackURN,
+				Provider: string(defaultProviderURN) + "::default-id",
+			},
+		},
+	}
+	bytes, err := json.Marshal(deployment)
+	require.NoError(t, err)
+	untyped := apitype.UntypedDeployment{Deployment: bytes}
+
+	// The file is imported into the dev stack, which already has the component and the provider.
+	targetComponentURN := "urn:pulumi:dev::proj::cdk:index:Stack::app"
+	targetProviderURN := "urn:pulumi:dev::proj::pulumi:providers:aws::us-east-1"
+	target := targetStack(t, "dev", "proj", targetComponentURN, targetProviderURN)
+
+	file, err := BuildFileFromDeployment(untyped, nil)
+	require.NoError(t, err)
+	file.Resources = append(file.Resources, Resource{Type: "aws:sns/topic:Topic", Name: "topic", ID: placeholderID})
+
+	err = ResolveReferences(file, untyped, []ResourceReferences{{
+		URN:      topicURN,
+		Parent:   string(componentURN),
+		Provider: string(regionProviderURN) + "::region-id",
+	}}, target)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"app":       targetComponentURN,
+		"us-east-1": targetProviderURN,
+	}, file.NameTable, "references point at the target stack, not the capture stack")
+	byName := map[string]Resource{}
+	for _, res := range file.Resources {
+		byName[res.Name] = res
+	}
+	require.Len(t, byName, 3)
+	assert.Equal(t, "app", byName["bucket"].Parent)
+	assert.Equal(t, "us-east-1", byName["bucket"].Provider)
+	assert.Equal(t, "7.11.0", byName["bucket"].Version)
+	assert.Equal(t, "", byName["queue"].Parent, "the root stack is not a parent")
+	assert.Equal(t, "", byName["queue"].Provider, "default providers are not referenced")
+	assert.Equal(t, "app", byName["topic"].Parent, "registrations cover resources missing from state")
+	assert.Equal(t, "us-east-1", byName["topic"].Provider)
+	assert.NoError(t, ValidateReferences(file, target))
+}
+
+func TestResolveReferencesAddsMissingComponents(t *testing.T) {
+	t.Parallel()
+
+	stackURN := "urn:pulumi:capture-app::proj::pulumi:pulumi:Stack::proj-capture-app"
+	appURN := "urn:pulumi:capture-app::proj::cdk:index:Stack::app"
+	constructURN := "urn:pulumi:capture-app::proj::cdk:index:Stack$cdk:index:Construct::app/Queue"
+	providerURN := "urn:pulumi:capture-app::proj::pulumi:providers:aws::us-east-1"
+	registrations := []ResourceReferences{
+		{URN: appURN, Parent: stackURN, Component: true},
+		{URN: constructURN, Parent: appURN, Component: true},
+		{
+			URN:      "urn:pulumi:capture-app::proj::cdk:index:Stack$cdk:index:Construct$aws:sqs/queue:Queue::queue",
+			Parent:   constructURN,
+			Provider: providerURN + "::provider-id",
+		},
+	}
+	file := &File{Resources: []Resource{{Type: "aws:sqs/queue:Queue", Name: "queue", ID: "queue-url"}}}
+	target := targetStack(t, "dev", "proj")
+
+	require.NoError(t, ResolveReferences(file, apitype.UntypedDeployment{}, registrations, target))
+
+	assert.Equal(t, []Resource{
+		{Type: "cdk:index:Stack", Name: "app", Component: true},
+		{Type: "cdk:index:Construct", Name: "app/Queue", Component: true, Parent: "app"},
+		{Type: "aws:sqs/queue:Queue", Name: "queue", ID: "queue-url", Parent: "app/Queue", Provider: "us-east-1"},
+	}, file.Resources, "components missing from the target stack are created, parents first")
+	assert.Equal(t, map[string]string{"us-east-1": "urn:pulumi:dev::proj::pulumi:providers:aws::us-east-1"}, file.NameTable)
+
+	err := ValidateReferences(file, target)
+	require.Error(t, err, "the provider must exist in the target stack")
+	assert.Contains(t, err.Error(), `name table entry "us-east-1" is not in stack dev`)
+}
+
+func TestResolveReferencesKeepsNamesUnique(t *testing.T) {
+	t.Parallel()
+
+	stackURN := resource.URN("urn:pulumi:dev::proj::pulumi:pulumi:Stack::proj-dev")
+	componentURN := resource.URN("urn:pulumi:dev::proj::cdk:index:Construct::bucket")
+	bucketURN := resource.URN("urn:pulumi:dev::proj::cdk:index:Construct$aws:s3/bucket:Bucket::bucket")
+
+	deployment := apitype.DeploymentV3{
+		Resources: []apitype.ResourceV3{
+			{URN: stackURN, Type: tokens.Type("pulumi:pulumi:Stack")},
+			{URN: componentURN, Type: tokens.Type("cdk:index:Construct"), Parent: stackURN},
+			{URN: bucketURN, Type: tokens.Type("aws:s3/bucket:Bucket"), Custom: true, ID: "bucket-123", Parent: componentURN},
+		},
+	}
+	bytes, err := json.Marshal(deployment)
+	require.NoError(t, err)
+	untyped := apitype.UntypedDeployment{Deployment: bytes}
+
+	file, err := BuildFileFromDeployment(untyped, nil)
+	require.NoError(t, err)
+	require.NoError(t, ResolveReferences(file, untyped, nil, Target{}))
+
+	require.Len(t, file.Resources, 2)
+	assert.Equal(t, Resource{Type: "cdk:index:Construct", Name: "bucket-2", LogicalName: "bucket", Component: true}, file.Resources[0],
+		"the component's name is taken by the bucket being imported")
+	assert.Equal(t, "bucket-2", file.Resources[1].Parent)
+	assert.Empty(t, file.NameTable)
+}
+
+func TestValidateReferences(t *testing.T) {
+	t.Parallel()
+
+	file := &File{
+		NameTable: map[string]string{
+			"app":    "urn:pulumi:dev::proj::cdk:index:Stack::app",
+			"broken": "not-a-urn",
+		},
+		Resources: []Resource{
+			{Type: "cdk:index:Construct", Name: "construct", Component: true, Parent: "app"},
+			{Type: "aws:s3/bucket:Bucket", Name: "bucket", ID: "b", Parent: "construct", Provider: "east"},
+			{Type: "aws:sqs/queue:Queue", Name: "queue", ID: "q", Parent: "missing"},
+		},
+	}
+
+	err := ValidateReferences(file, Target{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `name table entry "broken" is not a valid URN`)
+	assert.Contains(t, err.Error(), `provider "east" of aws:s3/bucket:Bucket "bucket" is not in the name table`)
+	assert.Contains(t, err.Error(), `parent "missing" of aws:sqs/queue:Queue "queue" is neither in the name table nor a resource in the file`)
+	assert.NotContains(t, err.Error(), `parent "construct"`, "parents can be other resources in the file")
+
+	delete(file.NameTable, "broken")
+	file.NameTable["east"] = "urn:pulumi:dev::proj::pulumi:providers:aws::east"
+	file.NameTable["missing"] = "urn:pulumi:dev::proj::cdk:index:Construct::missing"
+	assert.NoError(t, ValidateReferences(file, Target{}))
+
+	target := targetStack(t, "dev", "proj", file.NameTable["app"], file.NameTable["east"])
+	err = ValidateReferences(file, target)
+	require.Error(t, err)
+	assert.Equal(t, `invalid import file references: name table entry "missing" is not in stack dev: `+
+		`urn:pulumi:dev::proj::cdk:index:Construct::missing`, err.Error())
+}
+
+// targetStack returns a target whose state holds the given URNs.
+func targetStack(t *testing.T, stack, project string, urns ...string) Target {
+	t.Helper()
+	resources := make([]apitype.ResourceV3, 0, len(urns))
+	for _, urn := range urns {
+		resources = append(resources, apitype.ResourceV3{URN: resource.URN(urn), Type: resource.URN(urn).Type()})
+	}
+	bytes, err := json.Marshal(apitype.DeploymentV3{Resources: resources})
+	require.NoError(t, err)
+	return Target{Stack: stack, Project: project, Deployment: apitype.UntypedDeployment{Deployment: bytes}}
+}
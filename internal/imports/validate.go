@@ -0,0 +1,237 @@
+package imports
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/metadata"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+)
+
+// nativeSeparator joins the parts of a composite Cloud Control identifier.
+const nativeSeparator = "|"
+
+// Problem is a mistake found in an import file, at the line of the entry it concerns.
+type Problem struct {
+	Line    int
+	Warning bool
+	Message string
+}
+
+// String renders the problem as `<line>: error: <message>`.
+func (p Problem) String() string {
+	severity := "error"
+	if p.Warning {
+		severity = "warning"
+	}
+	return fmt.Sprintf("%d: %s: %s", p.Line, severity, p.Message)
+}
+
+// ValidateFile checks an import file against the embedded metadata without importing anything:
+// every resource type must exist, IDs must be set and have as many parts as the type's primary
+// identifier, component entries must not have an ID, parents must be in the name table or the file,
+// providers must be in the name table, and properties must be inputs of the resource. aws IDs may
+// have more parts than the primary identifier, because parts such as ARNs can contain the separator.
+//
+// aws types are checked against awsSchema when it is set. Without it, an aws type must be in the
+// embedded metadata or be named after a CloudFormation type, and its properties are reported as
+// unchecked warnings. It errors if data isn't an import file at all.
+func ValidateFile(data []byte, awsSchema *metadata.AwsSchema) ([]Problem, error) {
+	var file File
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("decoding import file: %w", err)
+	}
+	lines, err := entryLines(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	names := resourceNames(&file)
+	for _, msg := range nameTableProblems(file.NameTable) {
+		problems = append(problems, Problem{Line: lines.nameTable, Message: msg})
+	}
+	for i, res := range file.Resources {
+		line := 1
+		if i < len(lines.resources) {
+			line = lines.resources[i]
+		}
+		for _, p := range resourceProblems(res, awsSchema) {
+			p.Line = line
+			problems = append(problems, p)
+		}
+		for _, msg := range referenceProblems(res, file.NameTable, names) {
+			problems = append(problems, Problem{Line: line, Message: msg})
+		}
+	}
+	return problems, nil
+}
+
+// resourceProblems checks a single resource entry against the embedded metadata and, for aws
+// types, awsSchema if it is set.
+func resourceProblems(res Resource, awsSchema *metadata.AwsSchema) []Problem {
+	var problems []Problem
+	errorf := func(format string, args ...any) {
+		problems = append(problems, Problem{Message: fmt.Sprintf(format, args...)})
+	}
+	if res.Type == "" {
+		errorf("resource %q has no type", res.Name)
+		return problems
+	}
+	if res.Name == "" {
+		errorf("%s resource has no name", res.Type)
+	}
+
+	idSet := false
+	switch {
+	case res.Component:
+		if res.ID != "" {
+			errorf("component %s %q must not have an id", res.Type, res.Name)
+		}
+	case res.ID == "":
+		errorf("%s %q has no id", res.Type, res.Name)
+	case res.ID == placeholderID:
+		errorf("%s %q still has the placeholder id %s", res.Type, res.Name, placeholderID)
+	default:
+		idSet = true
+	}
+	if res.Component {
+		return problems
+	}
+
+	token := tokens.Type(res.Type)
+	switch {
+	case strings.HasPrefix(res.Type, "aws-native:"):
+		src := metadata.NewCCApiMetadataSource()
+		spec, err := src.Resource(res.Type)
+		if err != nil {
+			errorf("unknown aws-native resource type %s", res.Type)
+			return problems
+		}
+		if parts, _ := src.PrimaryIdentifier(token); idSet && len(parts) > 1 {
+			if got := strings.Split(res.ID, nativeSeparator); len(got) != len(parts) {
+				errorf("id %q of %s %q has %d parts separated by %q, but %s is identified by %d: %s",
+					res.ID, res.Type, res.Name, len(got), nativeSeparator, res.Type, len(parts), joinKeys(parts))
+			}
+		}
+		for _, prop := range res.Properties {
+			if _, ok := spec.Inputs[prop]; !ok {
+				errorf("property %q is not an input of %s", prop, res.Type)
+			}
+		}
+	case strings.HasPrefix(res.Type, "aws:"):
+		src := metadata.NewAwsMetadataSource()
+		switch {
+		case awsSchema != nil && !awsSchema.HasResource(token):
+			errorf("unknown aws resource type %s", res.Type)
+			return problems
+		case awsSchema != nil:
+			for _, prop := range res.Properties {
+				if !awsSchema.IsInput(token, prop) {
+					errorf("property %q is not an input of %s", prop, res.Type)
+				}
+			}
+		case !isKnownClassicType(token):
+			errorf("unknown aws resource type %s (pass --aws-schema to check it against the aws provider schema)", res.Type)
+			return problems
+		case len(res.Properties) > 0:
+			problems = append(problems, Problem{
+				Warning: true,
+				Message: fmt.Sprintf("properties of %s %q are not checked without --aws-schema", res.Type, res.Name),
+			})
+		}
+		if parts, _ := src.PrimaryIdentifier(token); idSet && len(parts) > 1 {
+			// Parts can contain the separator themselves (e.g. ARNs), so only too few parts is an error.
+			separator := src.Separator(token)
+			if got := strings.Split(res.ID, separator); len(got) < len(parts) {
+				errorf("id %q of %s %q has %d parts separated by %q, but %s is identified by %d: %s",
+					res.ID, res.Type, res.Name, len(got), separator, res.Type, len(parts), joinKeys(parts))
+			}
+		}
+	}
+	return problems
+}
+
+// isKnownClassicType reports whether token is an aws type of the embedded metadata, or the default
+// aws type of a CloudFormation type in the aws-native metadata.
+func isKnownClassicType(token tokens.Type) bool {
+	if _, ok := metadata.NewAwsMetadataSource().ResourceType(token); ok {
+		return true
+	}
+	for _, resourceType := range metadata.NewCCApiMetadataSource().ResourceTypes() {
+		if tok, ok := defaultClassicTokenFromCFType(resourceType); ok && tok == token {
+			return true
+		}
+	}
+	return false
+}
+
+func joinKeys[K ~string](keys []K) string {
+	strs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		strs = append(strs, string(k))
+	}
+	return strings.Join(strs, ", ")
+}
+
+// fileLines holds the line each entry of an import file starts on.
+type fileLines struct {
+	nameTable int
+	resources []int
+}
+
+// entryLines finds the line of the name table and of every resource in an import file.
+func entryLines(data []byte) (fileLines, error) {
+	lines := fileLines{nameTable: 1}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	lineAt := func() int {
+		offset := int(dec.InputOffset())
+		// The offset is right after the previous token; skip to the start of the next value.
+		for offset < len(data) && strings.ContainsRune(" \t\r\n,:", rune(data[offset])) {
+			offset++
+		}
+		return bytes.Count(data[:offset], []byte("\n")) + 1
+	}
+	invalid := func(err error) (fileLines, error) {
+		return fileLines{}, fmt.Errorf("decoding import file: %w", err)
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return invalid(err)
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return invalid(err)
+		}
+		// Keys match fields case-insensitively, like json.Unmarshal does.
+		switch name, _ := key.(string); strings.ToLower(name) {
+		case "nametable":
+			lines.nameTable = lineAt()
+		case "resources":
+			if tok, err := dec.Token(); err != nil {
+				return invalid(err)
+			} else if tok == nil {
+				continue
+			}
+			for dec.More() {
+				lines.resources = append(lines.resources, lineAt())
+				var raw json.RawMessage
+				if err := dec.Decode(&raw); err != nil {
+					return invalid(err)
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return invalid(err)
+			}
+			continue
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return invalid(err)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,109 @@
+package imports
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateFile(t *testing.T) {
+	t.Parallel()
+
+	data := []byte(`{
+  "nameTable": {
+    "app": "urn:pulumi:dev::proj::cdk:index:Stack::app",
+    "broken": "not-a-urn"
+  },
+  "resources": [
+    {
+      "type": "aws-native:s3:Bucket",
+      "name": "bucket",
+      "id": "my-bucket",
+      "parent": "app",
+      "properties": ["bucketName", "tags"]
+    },
+    {
+      "type": "aws-native:s3:Bucket",
+      "name": "pending",
+      "id": "<PLACEHOLDER>",
+      "provider": "west",
+      "properties": ["bucketNme"]
+    },
+    {
+      "type": "aws-native:apigateway:Resource",
+      "name": "apiResource",
+      "id": "abc123"
+    },
+    {"type": "cdk:index:Stack", "name": "app", "id": "app", "component": true},
+    {"type": "aws-native:nope:Thing", "name": "thing", "id": "x"},
+    {"type": "aws:iam/rolePolicyAttachment:RolePolicyAttachment", "name": "attachment", "id": "role"},
+    {"type": "aws:s3/bucketPolicy:BucketPolicy", "name": "policy", "id": "my-bucket"},
+    {"type": "aws:sqs/queue:Queue", "name": "queue"},
+    {"type": "aws:s3/bukcet:Bucket", "name": "typo", "id": "my-bucket"},
+    {"type": "cdk:index:Construct", "name": "construct", "component": true, "parent": "app"},
+    {"type": "aws:sqs/queue:Queue", "name": "child", "id": "url", "parent": "construct", "properties": ["name"]}
+  ]
+}
+`)
+
+	problems, err := ValidateFile(data, nil)
+	require.NoError(t, err)
+
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	assert.Equal(t, []string{
+		`2: error: name table entry "broken" is not a valid URN: "not-a-urn"`,
+		`14: error: aws-native:s3:Bucket "pending" still has the placeholder id <PLACEHOLDER>`,
+		`14: error: property "bucketNme" is not an input of aws-native:s3:Bucket`,
+		`14: error: provider "west" of aws-native:s3:Bucket "pending" is not in the name table`,
+		`21: error: id "abc123" of aws-native:apigateway:Resource "apiResource" has 1 parts separated by "|", but aws-native:apigateway:Resource is identified by 2: restApiId, resourceId`,
+		`26: error: component cdk:index:Stack "app" must not have an id`,
+		`27: error: unknown aws-native resource type aws-native:nope:Thing`,
+		`28: error: id "role" of aws:iam/rolePolicyAttachment:RolePolicyAttachment "attachment" has 1 parts separated by "/", but aws:iam/rolePolicyAttachment:RolePolicyAttachment is identified by 2: role, policyArn`,
+		`30: error: aws:sqs/queue:Queue "queue" has no id`,
+		`31: error: unknown aws resource type aws:s3/bukcet:Bucket (pass --aws-schema to check it against the aws provider schema)`,
+		`33: warning: properties of aws:sqs/queue:Queue "child" are not checked without --aws-schema`,
+	}, got)
+}
+
+func TestValidateFileWithAwsSchema(t *testing.T) {
+	t.Parallel()
+
+	var schema metadata.AwsSchema
+	require.NoError(t, json.Unmarshal([]byte(`{"resources": {
+  "aws:sqs/queue:Queue": {"inputProperties": {"name": {}, "delaySeconds": {}}},
+  "aws:ec2/vpc:Vpc": {"inputProperties": {"cidrBlock": {}}}
+}}`), &schema))
+	data := []byte(`{
+  "resources": [
+    {"type": "aws:sqs/queue:Queue", "name": "queue", "id": "url", "properties": ["name", "delay"]},
+    {"type": "aws:ec2/vpc:Vpc", "name": "vpc", "id": "vpc-123", "properties": ["cidrBlock"]},
+    {"type": "aws:s3/bucketPolicy:BucketPolicy", "name": "policy", "id": "my-bucket"}
+  ]
+}
+`)
+
+	problems, err := ValidateFile(data, &schema)
+	require.NoError(t, err)
+
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	assert.Equal(t, []string{
+		`3: error: property "delay" is not an input of aws:sqs/queue:Queue`,
+		`5: error: unknown aws resource type aws:s3/bucketPolicy:BucketPolicy`,
+	}, got, "types outside the embedded metadata are checked against the schema")
+}
+
+func TestValidateFileRejectsInvalidJSON(t *testing.T) {
+	t.Parallel()
+
+	_, err := ValidateFile([]byte(`{"resources": [`), nil)
+	assert.ErrorContains(t, err, "decoding import file")
+}
@@ -0,0 +1,160 @@
+package lookups
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+const (
+	cdkPathMetadataKey         = "aws:cdk:path"
+	assetPathMetadataKey       = "aws:asset:path"
+	nestedTemplateSuffix       = ".nested.template.json"
+	artifactTypeStack          = "aws:cloudformation:stack"
+	artifactTypeNestedAssembly = "cdk:cloud-assembly"
+)
+
+// cloudAssemblyManifest is the subset of a CDK cloud assembly `manifest.json` we need.
+type cloudAssemblyManifest struct {
+	Artifacts map[string]struct {
+		Type       string `json:"type"`
+		Properties struct {
+			TemplateFile  string `json:"templateFile"`
+			StackName     string `json:"stackName"`
+			DirectoryName string `json:"directoryName"`
+		} `json:"properties"`
+	} `json:"artifacts"`
+}
+
+// cloudAssemblyTemplate is the subset of a synthesized CloudFormation template we need.
+type cloudAssemblyTemplate struct {
+	Resources map[string]struct {
+		Type     string         `json:"Type"`
+		Metadata map[string]any `json:"Metadata"`
+	} `json:"Resources"`
+}
+
+// assemblyResourceKey identifies a template resource by the path of its stack (see
+// CfnStackResource.StackPath), since the deployed names of nested stacks aren't in the assembly.
+type assemblyResourceKey struct {
+	StackPath string
+	LogicalID common.LogicalResourceID
+}
+
+// ApplyCloudAssembly reads the CDK cloud assembly in dir (usually `cdk.out`) and records the
+// `aws:cdk:path` construct path of every known stack resource, so logical IDs can be found exactly
+// instead of by name heuristics. Nested stack templates are followed from their parent. Stacks in the
+// assembly that weren't loaded are ignored. It returns the number of resources that were annotated.
+func (l *Lookups) ApplyCloudAssembly(dir string) (int, error) {
+	paths := map[assemblyResourceKey]string{}
+	if err := readCloudAssembly(dir, paths); err != nil {
+		return 0, err
+	}
+	annotated := 0
+	for key, r := range l.CfnStackResources {
+		stackPath := r.StackPath
+		if stackPath == "" {
+			stackPath = string(key.StackName)
+		}
+		path, ok := paths[assemblyResourceKey{StackPath: stackPath, LogicalID: key.LogicalID}]
+		if !ok {
+			continue
+		}
+		r.ConstructPath = path
+		l.CfnStackResources[key] = r
+		annotated++
+	}
+	return annotated, nil
+}
+
+func readCloudAssembly(dir string, paths map[assemblyResourceKey]string) error {
+	manifestPath := filepath.Join(dir, "manifest.json")
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return fmt.Errorf("reading cloud assembly manifest: %w", err)
+	}
+	var manifest cloudAssemblyManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return fmt.Errorf("parsing %s: %w", manifestPath, err)
+	}
+	for id, artifact := range manifest.Artifacts {
+		switch artifact.Type {
+		case artifactTypeStack:
+			stackName := artifact.Properties.StackName
+			if stackName == "" {
+				stackName = id
+			}
+			templateFile := artifact.Properties.TemplateFile
+			if templateFile == "" {
+				continue
+			}
+			if err := readTemplatePaths(dir, templateFile, stackName, paths); err != nil {
+				return err
+			}
+		case artifactTypeNestedAssembly:
+			// Stages synthesize into their own nested assembly directory.
+			if artifact.Properties.DirectoryName == "" {
+				continue
+			}
+			if err := readCloudAssembly(filepath.Join(dir, artifact.Properties.DirectoryName), paths); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readTemplatePaths records the construct paths of the resources in the template of the stack at
+// stackPath, and of any nested stacks whose templates were synthesized next to it.
+func readTemplatePaths(dir, templateFile, stackPath string, paths map[assemblyResourceKey]string) error {
+	path := filepath.Join(dir, templateFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading template for stack %s: %w", stackPath, err)
+	}
+	var template cloudAssemblyTemplate
+	if err := json.Unmarshal(data, &template); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	for logicalID, r := range template.Resources {
+		if nestedTemplate, ok := r.Metadata[assetPathMetadataKey].(string); ok &&
+			r.Type == string(nestedStackResourceType) && strings.HasSuffix(nestedTemplate, nestedTemplateSuffix) {
+			if err := readTemplatePaths(dir, nestedTemplate, stackPath+"/"+logicalID, paths); err != nil {
+				return err
+			}
+		}
+		cdkPath, ok := r.Metadata[cdkPathMetadataKey].(string)
+		if !ok || cdkPath == "" {
+			continue
+		}
+		paths[assemblyResourceKey{StackPath: stackPath, LogicalID: common.LogicalResourceID(logicalID)}] = cdkPath
+	}
+	return nil
+}
+
+// constructPathMatches reports whether a URN name refers to the construct at cdkPath. pulumi-cdk
+// derives resource names from the construct path, dropping the stack and the trailing `Resource` or
+// `Default` child that L2 constructs use for their L1, and may join segments with `/` or `-`.
+func constructPathMatches(cdkPath string, urn resource.URN) bool {
+	segments := strings.Split(cdkPath, "/")
+	if n := len(segments); n > 1 && (segments[n-1] == "Resource" || segments[n-1] == "Default") {
+		segments = segments[:n-1]
+	}
+	name := urn.Name()
+	candidates := []string{cdkPath, strings.Join(segments, "/"), strings.Join(segments, "-")}
+	if len(segments) > 1 {
+		rel := segments[1:]
+		candidates = append(candidates, strings.Join(rel, "/"), strings.Join(rel, "-"))
+	}
+	for _, candidate := range candidates {
+		if strings.EqualFold(candidate, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+package lookups
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeAssemblyFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+}
+
+func TestApplyCloudAssembly(t *testing.T) {
+	dir := t.TempDir()
+	writeAssemblyFile(t, dir, "manifest.json", `{
+  "artifacts": {
+    "AppStack": {
+      "type": "aws:cloudformation:stack",
+      "properties": {"templateFile": "AppStack.template.json", "stackName": "app-stack"}
+    },
+    "assembly-Prod": {
+      "type": "cdk:cloud-assembly",
+      "properties": {"directoryName": "assembly-Prod"}
+    },
+    "Tree": {"type": "cdk:tree", "properties": {"file": "tree.json"}}
+  }
+}`)
+	writeAssemblyFile(t, dir, "AppStack.template.json", `{
+  "Resources": {
+    "Role1ABCD": {"Type": "AWS::IAM::Role", "Metadata": {"aws:cdk:path": "AppStack/Api/Role/Resource"}},
+    "Role2EFGH": {"Type": "AWS::IAM::Role", "Metadata": {"aws:cdk:path": "AppStack/Worker/Role/Resource"}},
+    "CDKMetadata": {"Type": "AWS::CDK::Metadata"}
+  }
+}`)
+	writeAssemblyFile(t, dir, "assembly-Prod/manifest.json", `{
+  "artifacts": {
+    "ProdDb": {"type": "aws:cloudformation:stack", "properties": {"templateFile": "ProdDb.template.json"}}
+  }
+}`)
+	writeAssemblyFile(t, dir, "assembly-Prod/ProdDb.template.json", `{
+  "Resources": {
+    "Table1234": {"Type": "AWS::DynamoDB::Table", "Metadata": {"aws:cdk:path": "Prod/Db/Table/Resource"}}
+  }
+}`)
+
+	l := &Lookups{CfnStackResources: map[StackResourceKey]CfnStackResource{
+		{StackName: "app-stack", LogicalID: "Role1ABCD"}: {LogicalID: "Role1ABCD", StackName: "app-stack", ResourceType: "AWS::IAM::Role"},
+		{StackName: "app-stack", LogicalID: "Role2EFGH"}: {LogicalID: "Role2EFGH", StackName: "app-stack", ResourceType: "AWS::IAM::Role"},
+		{StackName: "ProdDb", LogicalID: "Table1234"}:    {LogicalID: "Table1234", StackName: "ProdDb", ResourceType: "AWS::DynamoDB::Table"},
+	}}
+
+	annotated, err := l.ApplyCloudAssembly(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, annotated)
+	assert.Equal(t, "AppStack/Api/Role/Resource", l.CfnStackResources[StackResourceKey{StackName: "app-stack", LogicalID: "Role1ABCD"}].ConstructPath)
+	assert.Equal(t, "Prod/Db/Table/Resource", l.CfnStackResources[StackResourceKey{StackName: "ProdDb", LogicalID: "Table1234"}].ConstructPath)
+}
+
+func TestApplyCloudAssemblyNestedStacks(t *testing.T) {
+	dir := t.TempDir()
+	writeAssemblyFile(t, dir, "manifest.json", `{
+  "artifacts": {
+    "App": {"type": "aws:cloudformation:stack", "properties": {"templateFile": "App.template.json"}}
+  }
+}`)
+	writeAssemblyFile(t, dir, "App.template.json", `{
+  "Resources": {
+    "NetworkNestedStackNestedStackResource1A2B3C4D": {
+      "Type": "AWS::CloudFormation::Stack",
+      "Metadata": {
+        "aws:cdk:path": "App/Network.NestedStack/Network.NestedStackResource",
+        "aws:asset:path": "AppNetwork1A2B3C4D.nested.template.json"
+      }
+    }
+  }
+}`)
+	writeAssemblyFile(t, dir, "AppNetwork1A2B3C4D.nested.template.json", `{
+  "Resources": {
+    "Vpc8378EB38": {"Type": "AWS::EC2::VPC", "Metadata": {"aws:cdk:path": "App/Network/Vpc/Resource"}}
+  }
+}`)
+
+	vpc := StackResourceKey{StackName: "App-Network-1A2B3C", LogicalID: "Vpc8378EB38"}
+	l := &Lookups{CfnStackResources: map[StackResourceKey]CfnStackResource{
+		vpc: {LogicalID: "Vpc8378EB38", StackName: "App-Network-1A2B3C", StackPath: "App/NetworkNestedStackNestedStackResource1A2B3C4D", ResourceType: "AWS::EC2::VPC"},
+	}}
+
+	annotated, err := l.ApplyCloudAssembly(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, annotated)
+	assert.Equal(t, "App/Network/Vpc/Resource", l.CfnStackResources[vpc].ConstructPath)
+}
+
+func TestApplyCloudAssemblyMissingManifest(t *testing.T) {
+	l := &Lookups{CfnStackResources: map[StackResourceKey]CfnStackResource{}}
+	_, err := l.ApplyCloudAssembly(t.TempDir())
+	assert.ErrorContains(t, err, "reading cloud assembly manifest")
+}
+
+func TestConstructPathMatches(t *testing.T) {
+	urn := func(name string) resource.URN {
+		return resource.URN("urn:pulumi:stack::project::aws-native:iam:Role::" + name)
+	}
+	assert.True(t, constructPathMatches("AppStack/Api/Role/Resource", urn("Api/Role")))
+	assert.True(t, constructPathMatches("AppStack/Api/Role/Resource", urn("api-role")))
+	assert.True(t, constructPathMatches("AppStack/Api/Role/Resource", urn("AppStack/Api/Role")))
+	assert.True(t, constructPathMatches("AppStack/Api/Role/Resource", urn("AppStack/Api/Role/Resource")))
+	assert.False(t, constructPathMatches("AppStack/Api/Role/Resource", urn("Role")))
+	assert.False(t, constructPathMatches("AppStack/Api/Role/Resource", urn("Worker/Role")))
+}
@@ -14,11 +14,11 @@ import (
 type awsLookups struct {
 	region            string
 	account           string
-	cfnStackResources map[common.LogicalResourceID]CfnStackResource
+	cfnStackResources map[StackResourceKey]CfnStackResource
 }
 
 func NewAwsLookups(
-	resources map[common.LogicalResourceID]CfnStackResource,
+	resources map[StackResourceKey]CfnStackResource,
 	region string,
 	account string,
 ) *awsLookups {
@@ -31,36 +31,59 @@ func NewAwsLookups(
 
 func (c *awsLookups) FindLogicalResourceID(
 	urn resource.URN,
-) (common.LogicalResourceID, error) {
-	return findLogicalResourceID(urn, metadata.NewAwsMetadataSource(), c.cfnStackResources)
+) (StackResourceKey, error) {
+	key, err := findLogicalResourceID(urn, metadata.NewAwsMetadataSource(), c.cfnStackResources)
+	if err != nil && isPolicyPrincipalResource(urn.Type()) {
+		return findPolicyAttachmentLogicalID(urn, c.cfnStackResources)
+	}
+	return key, err
 }
 
 func (a *awsLookups) FindPrimaryResourceID(
 	ctx context.Context,
 	resourceToken tokens.Type,
-	logicalID common.LogicalResourceID,
+	key StackResourceKey,
 	props map[string]any,
 ) (common.PrimaryResourceID, error) {
+	id, _, err := a.ResolvePrimaryResourceID(ctx, resourceToken, key, props)
+	return id, err
+}
+
+// ResolvePrimaryResourceID behaves like [FindPrimaryResourceID] but also reports the strategy
+// that produced the ID.
+func (a *awsLookups) ResolvePrimaryResourceID(
+	ctx context.Context,
+	resourceToken tokens.Type,
+	key StackResourceKey,
+	props map[string]any,
+) (common.PrimaryResourceID, ResolutionStrategy, error) {
 	metadataSource := metadata.NewAwsMetadataSource()
 	resourceType, idParts, err := getPrimaryIdentifiers(metadataSource, resourceToken)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	if template, ok := metadataSource.IDTemplate(resourceType); ok {
+		id, err := expandIDTemplate(template, key, a.cfnStackResources[key], a.region, a.account, props)
+		return id, ResolutionTemplate, err
+	}
+	if resourceToken == iamPolicyToken {
+		return "", "", fmt.Errorf("%w (%s)", ErrInlinePolicy, key)
 	}
 	switch len(idParts) {
 	case 0:
-		return "", fmt.Errorf("ResourceType %q with logicalID %q has no primary identifiers", resourceType, logicalID)
+		return "", "", fmt.Errorf("ResourceType %q with logicalID %q has no primary identifiers", resourceType, key)
 	case 1:
 		// if there is only one primary identifier, then we should be able to
 		// use that to find the resource
-		return a.findOwnAwsId(resourceType, logicalID, idParts[0], props)
+		return a.findOwnAwsId(resourceType, key, idParts[0], props)
 	default:
 		// if there are multiple primary identifiers, then we probably need to use all of them
-		parts, err := buildIdentifierParts(idParts, props, string(a.cfnStackResources[logicalID].PhysicalID))
+		parts, err := buildIdentifierParts(idParts, props, string(a.cfnStackResources[key].PhysicalID))
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 		separator := metadataSource.Separator(resourceToken)
-		return common.PrimaryResourceID(strings.Join(parts, separator)), nil
+		return common.PrimaryResourceID(strings.Join(parts, separator)), ResolutionComposite, nil
 	}
 }
 
@@ -108,30 +131,31 @@ func buildIdentifierParts(
 // findOwnAwsId should only be used when the resource only has a single element in it's identifier
 func (a *awsLookups) findOwnAwsId(
 	resourceType common.ResourceType,
-	logicalID common.LogicalResourceID,
+	key StackResourceKey,
 	primaryID resource.PropertyKey,
 	props map[string]any,
-) (common.PrimaryResourceID, error) {
+) (common.PrimaryResourceID, ResolutionStrategy, error) {
 	idPropertyName := strings.ToLower(string(primaryID))
 
 	// Prefer the explicit property value when provided (common for queueUrl-style identifiers).
 	if val, ok := props[string(primaryID)]; ok {
 		if s, ok := val.(string); ok && s != "" {
-			return common.PrimaryResourceID(s), nil
+			return common.PrimaryResourceID(s), ResolutionProperty, nil
 		}
-		return "", fmt.Errorf("expected id property %q to be a string; got %v", primaryID, val)
+		return "", "", fmt.Errorf("expected id property %q to be a string; got %v", primaryID, val)
 	}
 
 	// If the identifier is an ARN, construct or look it up if we know how.
 	if strings.HasSuffix(idPropertyName, "arn") {
-		if r, ok := a.cfnStackResources[logicalID]; ok {
-			return a.getArnForResource(resourceType, string(r.PhysicalID))
+		if r, ok := a.cfnStackResources[key]; ok {
+			id, err := a.getArnForResource(resourceType, string(r.PhysicalID))
+			return id, ResolutionARN, err
 		}
 	}
 
 	// Default: assume the PhysicalID is the import identifier, regardless of naming.
-	if r, ok := a.cfnStackResources[logicalID]; ok {
-		return common.PrimaryResourceID(r.PhysicalID), nil
+	if r, ok := a.cfnStackResources[key]; ok {
+		return common.PrimaryResourceID(r.PhysicalID), ResolutionPhysicalID, nil
 	}
-	return "", fmt.Errorf("Resource doesn't exist in this stack which isn't possible!")
+	return "", "", fmt.Errorf("Resource doesn't exist in this stack which isn't possible!")
 }
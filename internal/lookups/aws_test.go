@@ -14,7 +14,7 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 	t.Run("apigateway stage", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws:apigatewayv2/stage:Stage")
-		logicalID := common.LogicalResourceID("Stage")
+		key := StackResourceKey{LogicalID: "Stage"}
 		props := map[string]interface{}{
 			"apiId": "apiId",
 		}
@@ -22,8 +22,8 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 		awsLookups := &awsLookups{
 			region:  "us-west-2",
 			account: "123456789012",
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"Stage": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "Stage"}: {
 					ResourceType: "AWS::ApiGatewayV2::Stage",
 					PhysicalID:   "stageId",
 					LogicalID:    "Stage",
@@ -31,7 +31,7 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 			},
 		}
 
-		actual, err := awsLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		actual, err := awsLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
 		assert.NoError(t, err)
 		assert.Equal(t, common.PrimaryResourceID("apiId/stageId"), actual)
 	})
@@ -39,14 +39,14 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 	t.Run("iam policy", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws:iam/policy:Policy")
-		logicalID := common.LogicalResourceID("Policy")
+		key := StackResourceKey{LogicalID: "Policy"}
 		props := map[string]interface{}{}
 
 		awsLookups := &awsLookups{
 			region:  "us-west-2",
 			account: "123456789012",
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"Policy": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "Policy"}: {
 					ResourceType: "AWS::IAM::Policy",
 					PhysicalID:   "Policy",
 					LogicalID:    "Policy",
@@ -54,15 +54,78 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 			},
 		}
 
-		actual, err := awsLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		_, err := awsLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
+		assert.ErrorIs(t, err, ErrInlinePolicy, "the physical ID of an AWS::IAM::Policy isn't a managed policy")
+
+		_, err = awsLookups.FindPrimaryResourceID(ctx, resourceToken, key, map[string]any{"name": "Policy", "path": "/service/"})
+		assert.ErrorIs(t, err, ErrInlinePolicy, "the policy name is the name of the inline policies, not of a managed policy")
+	})
+
+	t.Run("iam inline policies", func(t *testing.T) {
+		ctx := context.Background()
+		cfnStackResources := map[StackResourceKey]CfnStackResource{
+			{LogicalID: "Policy"}: {
+				ResourceType: "AWS::IAM::Policy",
+				PhysicalID:   "Stack-Polic-1ABC",
+				LogicalID:    "Policy",
+			},
+			{LogicalID: "PolicyDocument"}: {
+				ResourceType: "AWS::IAM::Policy",
+				PhysicalID:   "Stack-Polic-2DEF",
+				LogicalID:    "PolicyDocument",
+			},
+		}
+		awsLookups := NewAwsLookups(cfnStackResources, "us-west-2", "123456789012")
+
+		for token, props := range map[tokens.Type]map[string]any{
+			"aws:iam/rolePolicy:RolePolicy":   {"role": "MyRole", "name": "PolicyDocument"},
+			"aws:iam/userPolicy:UserPolicy":   {"user": "MyRole", "name": "PolicyDocument"},
+			"aws:iam/groupPolicy:GroupPolicy": {"group": "MyRole", "name": "PolicyDocument"},
+		} {
+			urn := resource.NewURN("stack", "project", "", token, "PolicyDocument-MyRole")
+			key, err := awsLookups.FindLogicalResourceID(urn)
+			assert.NoError(t, err, token)
+			assert.Equal(t, StackResourceKey{LogicalID: "PolicyDocument"}, key, token)
+
+			actual, strategy, err := awsLookups.ResolvePrimaryResourceID(ctx, token, key, props)
+			assert.NoError(t, err, token)
+			assert.Equal(t, ResolutionComposite, strategy, token)
+			assert.Equal(t, common.PrimaryResourceID("MyRole:PolicyDocument"), actual, token)
+		}
+	})
+
+	t.Run("iam role policy attachment", func(t *testing.T) {
+		ctx := context.Background()
+		resourceToken := tokens.Type("aws:iam/rolePolicyAttachment:RolePolicyAttachment")
+		policyArn := "arn:aws:iam::123456789012:policy/Policy"
+		cfnStackResources := map[StackResourceKey]CfnStackResource{
+			{LogicalID: "Policy"}: {
+				ResourceType: "AWS::IAM::Policy",
+				PhysicalID:   "Stack-Polic-1ABC",
+				LogicalID:    "Policy",
+			},
+			{LogicalID: "PolicyDocument"}: {
+				ResourceType: "AWS::IAM::Policy",
+				PhysicalID:   "Stack-Polic-2DEF",
+				LogicalID:    "PolicyDocument",
+			},
+		}
+		awsLookups := NewAwsLookups(cfnStackResources, "us-west-2", "123456789012")
+
+		key, err := awsLookups.FindLogicalResourceID(resource.URN("urn:pulumi:stack::project::aws:iam/rolePolicyAttachment:RolePolicyAttachment::PolicyDocument-MyRole"))
+		assert.NoError(t, err)
+		assert.Equal(t, StackResourceKey{LogicalID: "PolicyDocument"}, key, "the longest logical ID prefix wins")
+
+		actual, strategy, err := awsLookups.ResolvePrimaryResourceID(ctx, resourceToken, key, map[string]any{"role": "MyRole", "policyArn": policyArn})
 		assert.NoError(t, err)
-		assert.Equal(t, common.PrimaryResourceID("arn:aws:iam::123456789012:policy/Policy"), actual)
+		assert.Equal(t, ResolutionComposite, strategy)
+		assert.Equal(t, common.PrimaryResourceID("MyRole/"+policyArn), actual)
 	})
 
 	t.Run("iam role policy with colon separator", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws:iam/rolePolicy:RolePolicy")
-		logicalID := common.LogicalResourceID("RolePolicy")
+		key := StackResourceKey{LogicalID: "RolePolicy"}
 		props := map[string]interface{}{
 			"role": "MyRole",
 		}
@@ -70,8 +133,8 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 		awsLookups := &awsLookups{
 			region:  "us-west-2",
 			account: "123456789012",
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"RolePolicy": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "RolePolicy"}: {
 					ResourceType: "AWS::IAM::Policy",
 					PhysicalID:   "MyPolicy",
 					LogicalID:    "RolePolicy",
@@ -79,7 +142,7 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 			},
 		}
 
-		actual, err := awsLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		actual, err := awsLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
 		assert.NoError(t, err)
 		// Should use colon separator for RolePolicy
 		assert.Equal(t, common.PrimaryResourceID("MyRole:MyPolicy"), actual)
@@ -88,7 +151,7 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 	t.Run("service discovery private dns namespace keeps id order", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws:servicediscovery/privateDnsNamespace:PrivateDnsNamespace")
-		logicalID := common.LogicalResourceID("Namespace")
+		key := StackResourceKey{LogicalID: "Namespace"}
 		props := map[string]interface{}{
 			"vpc": "vpc-02c046362e72bd9be",
 		}
@@ -96,8 +159,8 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 		awsLookups := &awsLookups{
 			region:  "us-west-2",
 			account: "123456789012",
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"Namespace": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "Namespace"}: {
 					ResourceType: "AWS::ServiceDiscovery::PrivateDnsNamespace",
 					PhysicalID:   "ns-gwftkj6fpvjfzc7n",
 					LogicalID:    "Namespace",
@@ -105,7 +168,7 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 			},
 		}
 
-		actual, err := awsLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		actual, err := awsLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
 		assert.NoError(t, err)
 		assert.Equal(t, common.PrimaryResourceID("ns-gwftkj6fpvjfzc7n:vpc-02c046362e72bd9be"), actual)
 	})
@@ -113,7 +176,7 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 	t.Run("queue policy uses provided queueUrl", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws:sqs/queuePolicy:QueuePolicy")
-		logicalID := common.LogicalResourceID("QueuePolicy")
+		key := StackResourceKey{LogicalID: "QueuePolicy"}
 		queueURL := "https://sqs.us-west-2.amazonaws.com/123456789012/my-queue"
 		props := map[string]interface{}{
 			"queueUrl": queueURL,
@@ -122,8 +185,8 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 		awsLookups := &awsLookups{
 			region:  "us-west-2",
 			account: "123456789012",
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"QueuePolicy": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "QueuePolicy"}: {
 					ResourceType: "AWS::SQS::QueuePolicy",
 					PhysicalID:   "policy-physical-id",
 					LogicalID:    "QueuePolicy",
@@ -131,7 +194,7 @@ func TestFindAwsPrimaryResourceID(t *testing.T) {
 			},
 		}
 
-		actual, err := awsLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		actual, err := awsLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
 		assert.NoError(t, err)
 		assert.Equal(t, common.PrimaryResourceID(queueURL), actual)
 	})
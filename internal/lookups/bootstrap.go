@@ -0,0 +1,124 @@
+package lookups
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+)
+
+// DefaultBootstrapStack is the stack name `cdk bootstrap` uses unless told otherwise.
+const DefaultBootstrapStack = "CDKToolkit"
+
+// The outputs and logical IDs of the asset storage in the CDK bootstrap template.
+const (
+	bootstrapBucketOutput        = "BucketName"
+	bootstrapRepositoryOutput    = "ImageRepositoryName"
+	bootstrapBucketLogicalID     = "StagingBucket"
+	bootstrapRepositoryLogicalID = "ContainerAssetsRepository"
+)
+
+// bootstrapBucketTokens and bootstrapRepositoryTokens are the asset resources the pulumi-cdk
+// synthesizer creates, which are all imported by the name of the bucket or repository.
+var (
+	bootstrapBucketTokens = map[tokens.Type]bool{
+		"aws:s3/bucketV2:BucketV2": true,
+		"aws:s3/bucketLifecycleConfigurationV2:BucketLifecycleConfigurationV2":                       true,
+		"aws:s3/bucketServerSideEncryptionConfigurationV2:BucketServerSideEncryptionConfigurationV2": true,
+		"aws:s3/bucketPolicy:BucketPolicy":                                                           true,
+		"aws:s3/bucketVersioningV2:BucketVersioningV2":                                               true,
+	}
+	bootstrapRepositoryTokens = map[tokens.Type]bool{
+		"aws:ecr/repository:Repository":           true,
+		"aws:ecr/lifecyclePolicy:LifecyclePolicy": true,
+	}
+)
+
+// BootstrapAsset is an asset bucket or repository of the bootstrap stack.
+type BootstrapAsset struct {
+	Key          StackResourceKey
+	ResourceType common.ResourceType
+	ID           common.PrimaryResourceID
+}
+
+// BootstrapAssets is the asset storage of a CDK bootstrap stack. Either asset may be missing, e.g.
+// when the bootstrap template was customized.
+type BootstrapAssets struct {
+	Bucket     BootstrapAsset
+	Repository BootstrapAsset
+}
+
+// ForToken returns the bootstrap asset that a pulumi-cdk asset resource of the given type should be
+// imported against. It is nil-safe.
+func (b *BootstrapAssets) ForToken(token tokens.Type) (BootstrapAsset, bool) {
+	switch {
+	case b == nil:
+		return BootstrapAsset{}, false
+	case bootstrapBucketTokens[token]:
+		return b.Bucket, b.Bucket.ID != ""
+	case bootstrapRepositoryTokens[token]:
+		return b.Repository, b.Repository.ID != ""
+	}
+	return BootstrapAsset{}, false
+}
+
+// LoadBootstrapAssets reads the staging bucket and container asset repository of the bootstrap
+// stack from its outputs, falling back to its resources. The bootstrap stack's resources are kept
+// apart from CfnStackResources so program resources are never matched against them.
+func (l *Lookups) LoadBootstrapAssets(ctx context.Context, stackName common.StackName) (*BootstrapAssets, error) {
+	sn := string(stackName)
+	b := &BootstrapAssets{
+		Bucket:     BootstrapAsset{ResourceType: "AWS::S3::Bucket"},
+		Repository: BootstrapAsset{ResourceType: "AWS::ECR::Repository"},
+	}
+	paginator := cloudformation.NewListStackResourcesPaginator(l.CfnClient, &cloudformation.ListStackResourcesInput{
+		StackName: &sn,
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to list resources of bootstrap stack %s: %w", stackName, err)
+		}
+		for _, s := range output.StackResourceSummaries {
+			if s.LogicalResourceId == nil || s.PhysicalResourceId == nil {
+				continue
+			}
+			asset := BootstrapAsset{
+				Key: StackResourceKey{StackName: stackName, LogicalID: common.LogicalResourceID(*s.LogicalResourceId)},
+				ID:  common.PrimaryResourceID(*s.PhysicalResourceId),
+			}
+			switch *s.LogicalResourceId {
+			case bootstrapBucketLogicalID:
+				asset.ResourceType = b.Bucket.ResourceType
+				b.Bucket = asset
+			case bootstrapRepositoryLogicalID:
+				asset.ResourceType = b.Repository.ResourceType
+				b.Repository = asset
+			}
+		}
+	}
+
+	stacks, err := l.CfnClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: &sn})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to describe bootstrap stack %s: %w", stackName, err)
+	}
+	for _, stack := range stacks.Stacks {
+		for _, output := range stack.Outputs {
+			if output.OutputKey == nil || output.OutputValue == nil || *output.OutputValue == "" {
+				continue
+			}
+			switch *output.OutputKey {
+			case bootstrapBucketOutput:
+				b.Bucket.ID = common.PrimaryResourceID(*output.OutputValue)
+			case bootstrapRepositoryOutput:
+				b.Repository.ID = common.PrimaryResourceID(*output.OutputValue)
+			}
+		}
+	}
+	if b.Bucket.ID == "" && b.Repository.ID == "" {
+		return nil, fmt.Errorf("Bootstrap stack %s has no staging bucket or container asset repository", stackName)
+	}
+	return b, nil
+}
@@ -0,0 +1,66 @@
+package lookups
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bootstrapCloudFormation serves a CDKToolkit stack whose outputs may be left out.
+type bootstrapCloudFormation struct {
+	CloudFormationAPI
+	outputs []cfntypes.Output
+}
+
+func (bootstrapCloudFormation) ListStackResources(_ context.Context, _ *cloudformation.ListStackResourcesInput, _ ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error) {
+	return &cloudformation.ListStackResourcesOutput{StackResourceSummaries: []cfntypes.StackResourceSummary{
+		{LogicalResourceId: aws.String("StagingBucket"), PhysicalResourceId: aws.String("cdktoolkit-stagingbucket-1abc"), ResourceType: aws.String("AWS::S3::Bucket")},
+		{LogicalResourceId: aws.String("ContainerAssetsRepository"), PhysicalResourceId: aws.String("cdk-hnb659fds-container-assets"), ResourceType: aws.String("AWS::ECR::Repository")},
+		{LogicalResourceId: aws.String("CdkBootstrapVersion"), PhysicalResourceId: aws.String("/cdk-bootstrap/hnb659fds/version"), ResourceType: aws.String("AWS::SSM::Parameter")},
+	}}, nil
+}
+
+func (c bootstrapCloudFormation) DescribeStacks(_ context.Context, _ *cloudformation.DescribeStacksInput, _ ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	return &cloudformation.DescribeStacksOutput{Stacks: []cfntypes.Stack{{Outputs: c.outputs}}}, nil
+}
+
+func TestLoadBootstrapAssets(t *testing.T) {
+	t.Run("outputs win", func(t *testing.T) {
+		l := NewLookups("us-west-2", "123456789012", bootstrapCloudFormation{outputs: []cfntypes.Output{
+			{OutputKey: aws.String("BucketName"), OutputValue: aws.String("cdk-hnb659fds-assets-123456789012-us-west-2")},
+		}}, nil, nil)
+		b, err := l.LoadBootstrapAssets(context.Background(), DefaultBootstrapStack)
+		require.NoError(t, err)
+
+		bucket, ok := b.ForToken("aws:s3/bucketVersioningV2:BucketVersioningV2")
+		require.True(t, ok)
+		assert.Equal(t, BootstrapAsset{
+			Key:          StackResourceKey{StackName: "CDKToolkit", LogicalID: "StagingBucket"},
+			ResourceType: "AWS::S3::Bucket",
+			ID:           "cdk-hnb659fds-assets-123456789012-us-west-2",
+		}, bucket)
+
+		repo, ok := b.ForToken("aws:ecr/lifecyclePolicy:LifecyclePolicy")
+		require.True(t, ok)
+		assert.Equal(t, BootstrapAsset{
+			Key:          StackResourceKey{StackName: "CDKToolkit", LogicalID: "ContainerAssetsRepository"},
+			ResourceType: "AWS::ECR::Repository",
+			ID:           "cdk-hnb659fds-container-assets",
+		}, repo, "physical IDs are used when there is no output")
+
+		_, ok = b.ForToken("aws:s3/bucketObjectv2:BucketObjectv2")
+		assert.False(t, ok, "asset objects are always uploaded")
+		assert.Empty(t, l.CfnStackResources, "bootstrap resources are never matched to program resources")
+	})
+
+	t.Run("nil assets", func(t *testing.T) {
+		var b *BootstrapAssets
+		_, ok := b.ForToken("aws:s3/bucketV2:BucketV2")
+		assert.False(t, ok)
+	})
+}
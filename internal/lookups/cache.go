@@ -0,0 +1,207 @@
+package lookups
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/metadata"
+)
+
+// A key to use for caching resources
+type resourceCacheKey string
+
+// makeCacheKey creates a cache key for a resource based on its type and model
+// This combination should create a unique key for each resource
+func makeCacheKey(resourceType common.ResourceType, resourceModel map[string]string) resourceCacheKey {
+	keys := make([]string, 0, len(resourceModel))
+	for k := range resourceModel {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([][2]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, [2]string{k, resourceModel[k]})
+	}
+	// Marshalling a slice of string pairs can't fail.
+	encoded, _ := json.Marshal(pairs)
+	return resourceCacheKey(string(resourceType) + string(encoded))
+}
+
+// ResourceCache is a process-wide, concurrency-safe cache of CCAPI ListResources results keyed by
+// resource type and resource model. Concurrent misses for the same key share a single listing.
+// Failed listings are not cached.
+type ResourceCache struct {
+	mu      sync.RWMutex
+	entries map[resourceCacheKey][]types.ResourceDescription
+	group   singleflight.Group
+}
+
+// NewResourceCache creates an empty cache.
+func NewResourceCache() *ResourceCache {
+	return &ResourceCache{entries: make(map[resourceCacheKey][]types.ResourceDescription)}
+}
+
+func (c *ResourceCache) get(key resourceCacheKey) ([]types.ResourceDescription, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	val, ok := c.entries[key]
+	return val, ok
+}
+
+func (c *ResourceCache) set(key resourceCacheKey, val []types.ResourceDescription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = val
+}
+
+// load returns the cached value for key, calling fetch at most once across concurrent callers
+// when it is missing. The shared fetch isn't cancelled with the caller that started it; each
+// caller only stops waiting for it when its own ctx is done.
+func (c *ResourceCache) load(
+	ctx context.Context,
+	key resourceCacheKey,
+	fetch func(ctx context.Context) ([]types.ResourceDescription, error),
+) ([]types.ResourceDescription, error) {
+	if c == nil {
+		return fetch(ctx)
+	}
+	if val, ok := c.get(key); ok {
+		return val, nil
+	}
+	fetchCtx := context.WithoutCancel(ctx)
+	ch := c.group.DoChan(string(key), func() (any, error) {
+		if val, ok := c.get(key); ok {
+			return val, nil
+		}
+		val, err := fetch(fetchCtx)
+		if err != nil {
+			return nil, err
+		}
+		c.set(key, val)
+		return val, nil
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]types.ResourceDescription), nil
+	}
+}
+
+// PrefetchFailure records a resource type whose prefetch listing failed.
+type PrefetchFailure struct {
+	ResourceType common.ResourceType
+	Err          error
+}
+
+// PrefetchSummary describes the outcome of a prefetch.
+type PrefetchSummary struct {
+	Listed   []common.ResourceType
+	Failures []PrefetchFailure
+}
+
+// PrefetchCCAPI lists, in parallel with at most workers concurrent listings, every CCAPI resource
+// type the loaded stacks will need to look up by listing, and stores the results in CCAPICache so
+// the interceptors find them there. Failures are reported but don't stop the prefetch; the lookup
+// is simply retried (and its error surfaced) when the resource is imported.
+func (l *Lookups) PrefetchCCAPI(ctx context.Context, workers int) (PrefetchSummary, error) {
+	if l.CCAPICache == nil {
+		l.CCAPICache = NewResourceCache()
+	}
+	c, err := NewCCApiLookups(ctx, l.CCAPIClient, l.CfnStackResources, l.Region, l.Account, l.EventsClient, l.CCAPICache)
+	if err != nil {
+		return PrefetchSummary{}, err
+	}
+
+	resourceTypes := prefetchResourceTypes(l.CfnStackResources, l.IDOverrides, l.Checkpoint)
+	if workers < 1 {
+		workers = 1
+	}
+	var mu sync.Mutex
+	summary := PrefetchSummary{}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+	for _, resourceType := range resourceTypes {
+		g.Go(func() error {
+			_, err := c.listResources(gctx, resourceType, nil)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				summary.Failures = append(summary.Failures, PrefetchFailure{ResourceType: resourceType, Err: err})
+			} else {
+				summary.Listed = append(summary.Listed, resourceType)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return summary, err
+	}
+	if err := ctx.Err(); err != nil {
+		return summary, err
+	}
+	sort.Slice(summary.Listed, func(i, j int) bool { return summary.Listed[i] < summary.Listed[j] })
+	sort.Slice(summary.Failures, func(i, j int) bool {
+		return summary.Failures[i].ResourceType < summary.Failures[j].ResourceType
+	})
+	return summary, nil
+}
+
+// prefetchResourceTypes returns the resource types whose IDs findOwnNativeId resolves by listing
+// every resource of the type without a resource model: types with an explicit lookup strategy,
+// and ARN-identified types where some PhysicalID isn't already an ARN. Composite identifiers
+// depend on resource inputs and can't be prefetched. Resources with an ID override are never looked
+// up, and neither are resources resolved by a resumed checkpoint, so they don't count.
+func prefetchResourceTypes(resources map[StackResourceKey]CfnStackResource, overrides *IDOverrides, checkpoint *Checkpoint) []common.ResourceType {
+	md := metadata.NewCCApiMetadataSource()
+	nonARN := map[common.ResourceType]bool{}
+	for key, r := range resources {
+		if _, ok := overrides.ForResource(key); ok || checkpoint.resolved(key) {
+			continue
+		}
+		if _, ok := nonARN[r.ResourceType]; !ok {
+			nonARN[r.ResourceType] = false
+		}
+		if !strings.HasPrefix(string(r.PhysicalID), "arn:") {
+			nonARN[r.ResourceType] = true
+		}
+	}
+
+	var out []common.ResourceType
+	for resourceType, hasNonARN := range nonARN {
+		token, ok := md.ResourceToken(resourceType)
+		if !ok {
+			continue
+		}
+		idParts, ok := md.PrimaryIdentifier(token)
+		if !ok || len(idParts) != 1 {
+			continue
+		}
+		if len(md.ListHandlerRequiredProperties(resourceType)) > 0 {
+			continue
+		}
+		idPropertyName := strings.ToLower(string(idParts[0]))
+		switch md.GetIdPropertyStrategy(resourceType, idPropertyName) {
+		case metadata.StrategyLookup:
+			out = append(out, resourceType)
+		case metadata.StrategyPhysicalID, metadata.StrategyCustom:
+		default:
+			if hasNonARN && strings.HasSuffix(idPropertyName, "arn") {
+				out = append(out, resourceType)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
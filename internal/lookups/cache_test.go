@@ -0,0 +1,140 @@
+package lookups
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+// countingCloudControl counts ListResources calls per type and tracks peak concurrency.
+type countingCloudControl struct {
+	mu       sync.Mutex
+	calls    map[string]int
+	inFlight atomic.Int32
+	peak     atomic.Int32
+	delay    time.Duration
+}
+
+func (c *countingCloudControl) ListResources(_ context.Context, params *cloudcontrol.ListResourcesInput, _ ...func(*cloudcontrol.Options)) (*cloudcontrol.ListResourcesOutput, error) {
+	n := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		peak := c.peak.Load()
+		if n <= peak || c.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	c.mu.Lock()
+	c.calls[aws.ToString(params.TypeName)]++
+	c.mu.Unlock()
+	time.Sleep(c.delay)
+	return &cloudcontrol.ListResourcesOutput{
+		TypeName: params.TypeName,
+		ResourceDescriptions: []types.ResourceDescription{
+			{Identifier: aws.String("arn:aws:sns:us-west-2:123456789012:my-topic")},
+		},
+	}, nil
+}
+
+func TestMakeCacheKeyIsOrderIndependent(t *testing.T) {
+	a := makeCacheKey("AWS::ApiGateway::Stage", map[string]string{"RestApiId": "abc", "StageName": "prod"})
+	b := makeCacheKey("AWS::ApiGateway::Stage", map[string]string{"StageName": "prod", "RestApiId": "abc"})
+	assert.Equal(t, a, b)
+	assert.NotEqual(t, makeCacheKey("T", map[string]string{"ab": "c"}), makeCacheKey("T", map[string]string{"a": "bc"}))
+}
+
+func TestResourceCacheSharesConcurrentListings(t *testing.T) {
+	client := &countingCloudControl{calls: map[string]int{}, delay: 20 * time.Millisecond}
+	cache := NewResourceCache()
+	resources := map[StackResourceKey]CfnStackResource{
+		{LogicalID: "Topic"}: {ResourceType: "AWS::SNS::Topic", PhysicalID: "my-topic", LogicalID: "Topic"},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each interceptor call builds its own lookups; they must still share listings.
+			c, err := NewCCApiLookups(context.Background(), client, resources, "us-west-2", "123456789012", nil, cache)
+			require.NoError(t, err)
+			_, err = c.listResources(context.Background(), "AWS::SNS::Topic", nil)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, client.calls["AWS::SNS::Topic"])
+}
+
+func TestResourceCacheLoadOutlivesCancelledCaller(t *testing.T) {
+	cache := NewResourceCache()
+	key := makeCacheKey("AWS::SNS::Topic", nil)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	want := []types.ResourceDescription{{Identifier: aws.String("my-topic")}}
+
+	firstCtx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := cache.load(firstCtx, key, func(ctx context.Context) ([]types.ResourceDescription, error) {
+			close(started)
+			<-release
+			return want, ctx.Err()
+		})
+		firstErr <- err
+	}()
+	<-started
+
+	secondVal := make(chan []types.ResourceDescription, 1)
+	secondErr := make(chan error, 1)
+	go func() {
+		val, err := cache.load(context.Background(), key, func(context.Context) ([]types.ResourceDescription, error) {
+			t.Error("the second caller should share the first listing")
+			return nil, nil
+		})
+		secondVal <- val
+		secondErr <- err
+	}()
+
+	cancel()
+	assert.ErrorIs(t, <-firstErr, context.Canceled, "the cancelled caller stops waiting")
+	close(release)
+	require.NoError(t, <-secondErr, "the shared listing isn't cancelled with the caller that started it")
+	assert.Equal(t, want, <-secondVal)
+	cached, ok := cache.get(key)
+	assert.True(t, ok)
+	assert.Equal(t, want, cached)
+}
+
+func TestPrefetchCCAPI(t *testing.T) {
+	client := &countingCloudControl{calls: map[string]int{}, delay: 10 * time.Millisecond}
+	l := NewLookups("us-west-2", "123456789012", nil, client, nil)
+	l.CfnStackResources = map[StackResourceKey]CfnStackResource{
+		{LogicalID: "Topic"}:  {ResourceType: "AWS::SNS::Topic", PhysicalID: "my-topic", LogicalID: "Topic"},
+		{LogicalID: "Bucket"}: {ResourceType: "AWS::S3::Bucket", PhysicalID: "my-bucket", LogicalID: "Bucket"},
+	}
+
+	summary, err := l.PrefetchCCAPI(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, []common.ResourceType{"AWS::SNS::Topic"}, summary.Listed)
+	assert.Empty(t, summary.Failures)
+	assert.LessOrEqual(t, client.peak.Load(), int32(2))
+
+	c, err := NewCCApiLookups(context.Background(), l.CCAPIClient, l.CfnStackResources, l.Region, l.Account, nil, l.CCAPICache)
+	require.NoError(t, err)
+	id, _, err := c.findOwnNativeId(context.Background(), "AWS::SNS::Topic", StackResourceKey{LogicalID: "Topic"}, resource.PropertyKey("TopicArn"))
+	require.NoError(t, err)
+	assert.Equal(t, common.PrimaryResourceID("arn:aws:sns:us-west-2:123456789012:my-topic"), id)
+	assert.Equal(t, 1, client.calls["AWS::SNS::Topic"], "lookup should be served from the prefetched cache")
+}
@@ -0,0 +1,380 @@
+package lookups
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol"
+	cctypes "github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/smithy-go"
+)
+
+// cassetteVersion is bumped whenever the on-disk cassette format changes incompatibly.
+const cassetteVersion = 1
+
+const (
+	opListStackResources    = "cloudformation:ListStackResources"
+	opDescribeStackResource = "cloudformation:DescribeStackResource"
+	opDescribeStacks        = "cloudformation:DescribeStacks"
+	opGetTemplate           = "cloudformation:GetTemplate"
+	opListResources         = "cloudcontrol:ListResources"
+	opDescribeRule          = "eventbridge:DescribeRule"
+	opDescribeImages        = "ecr:DescribeImages"
+)
+
+// Cassette is a recording of every AWS call made during an import run. It can be replayed later
+// to reproduce the run without AWS credentials or network access.
+type Cassette struct {
+	Version      int           `json:"version"`
+	Region       string        `json:"region"`
+	Account      string        `json:"account"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded API call.
+type Interaction struct {
+	Operation string          `json:"operation"`
+	Request   json.RawMessage `json:"request"`
+	Response  json.RawMessage `json:"response,omitempty"`
+	Error     *RecordedError  `json:"error,omitempty"`
+}
+
+// RecordedError captures enough of an API error to rebuild it on replay.
+type RecordedError struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+	Fault   string `json:"fault,omitempty"`
+}
+
+// ReadCassette loads a cassette from disk.
+func ReadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	if c.Version != cassetteVersion {
+		return nil, fmt.Errorf("unsupported cassette version %d in %s (expected %d)", c.Version, path, cassetteVersion)
+	}
+	return &c, nil
+}
+
+// WriteFile writes the cassette to disk as indented JSON.
+func (c *Cassette) WriteFile(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Recorder wraps the AWS clients of a Lookups and records every call they make.
+type Recorder struct {
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// Record swaps the AWS clients of l for recording wrappers and returns the Recorder collecting
+// their calls. Call Recorder.Save once the run is finished, including when it failed.
+func (l *Lookups) Record() *Recorder {
+	r := &Recorder{cassette: Cassette{Version: cassetteVersion, Region: l.Region, Account: l.Account}}
+	if l.CfnClient != nil {
+		l.CfnClient = &recordingCloudFormation{r: r, inner: l.CfnClient}
+	}
+	if l.CCAPIClient != nil {
+		l.CCAPIClient = &recordingCloudControl{r: r, inner: l.CCAPIClient}
+	}
+	if l.EventsClient != nil {
+		l.EventsClient = &recordingEventBridge{r: r, inner: l.EventsClient}
+	}
+	if l.ECRClient != nil {
+		l.ECRClient = &recordingECR{r: r, inner: l.ECRClient}
+	}
+	return r
+}
+
+// Cassette returns a snapshot of the calls recorded so far.
+func (r *Recorder) Cassette() *Cassette {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.cassette
+	c.Interactions = append([]Interaction(nil), r.cassette.Interactions...)
+	return &c
+}
+
+// Save writes the recorded calls to path.
+func (r *Recorder) Save(path string) error {
+	return r.Cassette().WriteFile(path)
+}
+
+func (r *Recorder) add(op string, req, resp any, callErr error) {
+	reqData, err := json.Marshal(req)
+	if err != nil {
+		// Inputs are plain SDK structs, so this should never happen; don't fail the real call over it.
+		return
+	}
+	interaction := Interaction{Operation: op, Request: reqData}
+	if callErr != nil {
+		interaction.Error = recordError(callErr)
+	} else if respData, err := json.Marshal(resp); err == nil {
+		interaction.Response = respData
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+}
+
+func recordError(err error) *RecordedError {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return &RecordedError{
+			Code:    apiErr.ErrorCode(),
+			Message: apiErr.ErrorMessage(),
+			Fault:   apiErr.ErrorFault().String(),
+		}
+	}
+	return &RecordedError{Message: err.Error()}
+}
+
+type recordingCloudFormation struct {
+	r     *Recorder
+	inner CloudFormationAPI
+}
+
+func (c *recordingCloudFormation) ListStackResources(ctx context.Context, params *cloudformation.ListStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error) {
+	out, err := c.inner.ListStackResources(ctx, params, optFns...)
+	c.r.add(opListStackResources, params, out, err)
+	return out, err
+}
+
+func (c *recordingCloudFormation) DescribeStackResource(ctx context.Context, params *cloudformation.DescribeStackResourceInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceOutput, error) {
+	out, err := c.inner.DescribeStackResource(ctx, params, optFns...)
+	c.r.add(opDescribeStackResource, params, out, err)
+	return out, err
+}
+
+func (c *recordingCloudFormation) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	out, err := c.inner.DescribeStacks(ctx, params, optFns...)
+	c.r.add(opDescribeStacks, params, out, err)
+	return out, err
+}
+
+func (c *recordingCloudFormation) GetTemplate(ctx context.Context, params *cloudformation.GetTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error) {
+	out, err := c.inner.GetTemplate(ctx, params, optFns...)
+	c.r.add(opGetTemplate, params, out, err)
+	return out, err
+}
+
+type recordingCloudControl struct {
+	r     *Recorder
+	inner CloudControlAPI
+}
+
+func (c *recordingCloudControl) ListResources(ctx context.Context, params *cloudcontrol.ListResourcesInput, optFns ...func(*cloudcontrol.Options)) (*cloudcontrol.ListResourcesOutput, error) {
+	out, err := c.inner.ListResources(ctx, params, optFns...)
+	c.r.add(opListResources, params, out, err)
+	return out, err
+}
+
+type recordingEventBridge struct {
+	r     *Recorder
+	inner EventBridgeAPI
+}
+
+func (c *recordingEventBridge) DescribeRule(ctx context.Context, params *eventbridge.DescribeRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.DescribeRuleOutput, error) {
+	out, err := c.inner.DescribeRule(ctx, params, optFns...)
+	c.r.add(opDescribeRule, params, out, err)
+	return out, err
+}
+
+type recordingECR struct {
+	r     *Recorder
+	inner ECRAPI
+}
+
+func (c *recordingECR) DescribeImages(ctx context.Context, params *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	out, err := c.inner.DescribeImages(ctx, params, optFns...)
+	c.r.add(opDescribeImages, params, out, err)
+	return out, err
+}
+
+// NewReplayLookups creates a Lookups whose AWS clients answer from the cassette at path instead of
+// calling AWS.
+func NewReplayLookups(path string) (*Lookups, error) {
+	c, err := ReadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := newPlayer(c)
+	if err != nil {
+		return nil, err
+	}
+	l := NewLookups(
+		c.Region,
+		c.Account,
+		&replayCloudFormation{p: p},
+		&replayCloudControl{p: p},
+		&replayEventBridge{p: p},
+	)
+	l.ECRClient = &replayECR{p: p}
+	return l, nil
+}
+
+// player serves recorded responses. Calls are matched on operation and request; identical
+// requests are answered in recording order (so a throttled call followed by its successful retry
+// replays the same way), and the last answer is repeated once the recording runs out.
+type player struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	queues   map[string][]int
+	served   map[string]int
+}
+
+func newPlayer(c *Cassette) (*player, error) {
+	p := &player{cassette: c, queues: map[string][]int{}, served: map[string]int{}}
+	for i, interaction := range c.Interactions {
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, interaction.Request); err != nil {
+			return nil, fmt.Errorf("invalid request in cassette interaction %d: %w", i, err)
+		}
+		key := interaction.Operation + " " + buf.String()
+		p.queues[key] = append(p.queues[key], i)
+	}
+	return p, nil
+}
+
+func (p *player) play(op string, req, out any) error {
+	reqData, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	key := op + " " + string(reqData)
+
+	p.mu.Lock()
+	queue := p.queues[key]
+	if len(queue) == 0 {
+		p.mu.Unlock()
+		return fmt.Errorf("no recorded %s call matches request %s", op, reqData)
+	}
+	n := p.served[key]
+	if n >= len(queue) {
+		n = len(queue) - 1
+	}
+	p.served[key]++
+	interaction := p.cassette.Interactions[queue[n]]
+	p.mu.Unlock()
+
+	if interaction.Error != nil {
+		return replayError(op, interaction.Error)
+	}
+	if err := json.Unmarshal(interaction.Response, out); err != nil {
+		return fmt.Errorf("invalid recorded %s response: %w", op, err)
+	}
+	return nil
+}
+
+// replayError rebuilds a recorded error. Cloud Control errors the lookups branch on are
+// restored to their concrete types; everything else becomes a generic API error.
+func replayError(op string, e *RecordedError) error {
+	if e.Code == "" {
+		return errors.New(e.Message)
+	}
+	msg := &e.Message
+	if op == opListResources {
+		switch e.Code {
+		case "InvalidRequestException":
+			return &cctypes.InvalidRequestException{Message: msg}
+		case "UnsupportedActionException":
+			return &cctypes.UnsupportedActionException{Message: msg}
+		case "ThrottlingException":
+			return &cctypes.ThrottlingException{Message: msg}
+		case "ResourceNotFoundException":
+			return &cctypes.ResourceNotFoundException{Message: msg}
+		case "TypeNotFoundException":
+			return &cctypes.TypeNotFoundException{Message: msg}
+		}
+	}
+	fault := smithy.FaultUnknown
+	switch e.Fault {
+	case smithy.FaultClient.String():
+		fault = smithy.FaultClient
+	case smithy.FaultServer.String():
+		fault = smithy.FaultServer
+	}
+	return &smithy.GenericAPIError{Code: e.Code, Message: e.Message, Fault: fault}
+}
+
+type replayCloudFormation struct{ p *player }
+
+func (c *replayCloudFormation) ListStackResources(_ context.Context, params *cloudformation.ListStackResourcesInput, _ ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error) {
+	out := &cloudformation.ListStackResourcesOutput{}
+	if err := c.p.play(opListStackResources, params, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *replayCloudFormation) DescribeStackResource(_ context.Context, params *cloudformation.DescribeStackResourceInput, _ ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceOutput, error) {
+	out := &cloudformation.DescribeStackResourceOutput{}
+	if err := c.p.play(opDescribeStackResource, params, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *replayCloudFormation) DescribeStacks(_ context.Context, params *cloudformation.DescribeStacksInput, _ ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	out := &cloudformation.DescribeStacksOutput{}
+	if err := c.p.play(opDescribeStacks, params, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *replayCloudFormation) GetTemplate(_ context.Context, params *cloudformation.GetTemplateInput, _ ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error) {
+	out := &cloudformation.GetTemplateOutput{}
+	if err := c.p.play(opGetTemplate, params, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type replayCloudControl struct{ p *player }
+
+func (c *replayCloudControl) ListResources(_ context.Context, params *cloudcontrol.ListResourcesInput, _ ...func(*cloudcontrol.Options)) (*cloudcontrol.ListResourcesOutput, error) {
+	out := &cloudcontrol.ListResourcesOutput{}
+	if err := c.p.play(opListResources, params, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type replayEventBridge struct{ p *player }
+
+func (c *replayEventBridge) DescribeRule(_ context.Context, params *eventbridge.DescribeRuleInput, _ ...func(*eventbridge.Options)) (*eventbridge.DescribeRuleOutput, error) {
+	out := &eventbridge.DescribeRuleOutput{}
+	if err := c.p.play(opDescribeRule, params, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+type replayECR struct{ p *player }
+
+func (c *replayECR) DescribeImages(_ context.Context, params *ecr.DescribeImagesInput, _ ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	out := &ecr.DescribeImagesOutput{}
+	if err := c.p.play(opDescribeImages, params, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,158 @@
+package lookups
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol"
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+)
+
+const (
+	listenerArn     = "arn:aws:elasticloadbalancing:us-west-2:123456789012:listener/app/my-load-balancer/50dc6c495c0c9188/0467ef3c8400ae65"
+	loadBalancerArn = "arn:aws:elasticloadbalancing:us-west-2:123456789012:loadbalancer/app/my-load-balancer/50dc6c495c0c9188"
+)
+
+type fakeCloudFormation struct{ CloudFormationAPI }
+
+func (fakeCloudFormation) ListStackResources(_ context.Context, params *cloudformation.ListStackResourcesInput, _ ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error) {
+	if params.NextToken == nil {
+		return &cloudformation.ListStackResourcesOutput{
+			NextToken: aws.String("page-2"),
+			StackResourceSummaries: []cfntypes.StackResourceSummary{{
+				LogicalResourceId:  aws.String("Listener"),
+				PhysicalResourceId: aws.String(listenerArn),
+				ResourceType:       aws.String("AWS::ElasticLoadBalancingV2::Listener"),
+			}},
+		}, nil
+	}
+	return &cloudformation.ListStackResourcesOutput{
+		StackResourceSummaries: []cfntypes.StackResourceSummary{{
+			LogicalResourceId:  aws.String("Bucket"),
+			PhysicalResourceId: aws.String("my-bucket"),
+			ResourceType:       aws.String("AWS::S3::Bucket"),
+		}},
+	}, nil
+}
+
+const networkStackArn = "arn:aws:cloudformation:us-west-2:123456789012:stack/App-Network-1A2B3C/0a1b2c3d"
+
+// nestedCloudFormation serves an `App` stack with a nested `Network` stack that nests itself again,
+// which a real stack can't do but must not send discovery into a loop.
+type nestedCloudFormation struct{ CloudFormationAPI }
+
+func (nestedCloudFormation) ListStackResources(_ context.Context, params *cloudformation.ListStackResourcesInput, _ ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error) {
+	nested := cfntypes.StackResourceSummary{
+		LogicalResourceId:  aws.String("NetworkNestedStackNestedStackResource1A2B3C4D"),
+		PhysicalResourceId: aws.String(networkStackArn),
+		ResourceType:       aws.String("AWS::CloudFormation::Stack"),
+	}
+	switch aws.ToString(params.StackName) {
+	case "App":
+		return &cloudformation.ListStackResourcesOutput{StackResourceSummaries: []cfntypes.StackResourceSummary{nested, {
+			LogicalResourceId:  aws.String("Bucket"),
+			PhysicalResourceId: aws.String("app-bucket"),
+			ResourceType:       aws.String("AWS::S3::Bucket"),
+		}}}, nil
+	case networkStackArn:
+		return &cloudformation.ListStackResourcesOutput{StackResourceSummaries: []cfntypes.StackResourceSummary{nested, {
+			LogicalResourceId:  aws.String("Vpc"),
+			PhysicalResourceId: aws.String("vpc-1234"),
+			ResourceType:       aws.String("AWS::EC2::VPC"),
+		}}}, nil
+	}
+	return nil, fmt.Errorf("unexpected stack %q", aws.ToString(params.StackName))
+}
+
+func TestGetStackResourcesNestedStacks(t *testing.T) {
+	l := NewLookups("us-west-2", "123456789012", nestedCloudFormation{}, fakeCloudControl{}, &mockEventsClient{})
+	require.NoError(t, l.GetStackResources(context.Background(), "App"))
+
+	assert.Len(t, l.CfnStackResources, 4)
+	assert.Equal(t, CfnStackResource{
+		ResourceType: "AWS::EC2::VPC",
+		PhysicalID:   "vpc-1234",
+		LogicalID:    "Vpc",
+		StackName:    "App-Network-1A2B3C",
+		StackPath:    "App/NetworkNestedStackNestedStackResource1A2B3C4D",
+	}, l.CfnStackResources[StackResourceKey{StackName: "App-Network-1A2B3C", LogicalID: "Vpc"}])
+	assert.Equal(t, "App", l.CfnStackResources[StackResourceKey{StackName: "App", LogicalID: "Bucket"}].StackPath)
+}
+
+// fakeCloudControl rejects list requests without a LoadBalancerArn model, like the real listener
+// list handler does.
+type fakeCloudControl struct{}
+
+func (fakeCloudControl) ListResources(_ context.Context, params *cloudcontrol.ListResourcesInput, _ ...func(*cloudcontrol.Options)) (*cloudcontrol.ListResourcesOutput, error) {
+	if params.ResourceModel == nil {
+		return nil, &types.InvalidRequestException{
+			Message: aws.String("Missing Or Invalid ResourceModel property in AWS::ElasticLoadBalancingV2::Listener list handler request input. Required property: [LoadBalancerArn]"),
+		}
+	}
+	return &cloudcontrol.ListResourcesOutput{
+		TypeName:             params.TypeName,
+		ResourceDescriptions: []types.ResourceDescription{{Identifier: aws.String(listenerArn)}},
+	}, nil
+}
+
+func resolveListener(t *testing.T, l *Lookups) common.PrimaryResourceID {
+	t.Helper()
+	ctx := context.Background()
+	require.NoError(t, l.GetStackResources(ctx, "Stack"))
+
+	key := StackResourceKey{StackName: "Stack", LogicalID: "Listener"}
+	r := l.CfnStackResources[key]
+	r.Props = map[string]any{"LoadBalancerArn": loadBalancerArn}
+	l.CfnStackResources[key] = r
+
+	c, err := NewCCApiLookups(ctx, l.CCAPIClient, l.CfnStackResources, l.Region, l.Account, l.EventsClient, l.CCAPICache)
+	require.NoError(t, err)
+	id, err := c.findResourceIdentifier(ctx, "AWS::ElasticLoadBalancingV2::Listener", key, listenerArn, nil)
+	require.NoError(t, err)
+	return id
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	live := NewLookups("us-west-2", "123456789012", fakeCloudFormation{}, fakeCloudControl{}, &mockEventsClient{})
+	recorder := live.Record()
+	assert.Equal(t, common.PrimaryResourceID(listenerArn), resolveListener(t, live))
+	require.NoError(t, recorder.Save(path))
+
+	cassette, err := ReadCassette(path)
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", cassette.Region)
+	assert.Equal(t, "123456789012", cassette.Account)
+	require.Len(t, cassette.Interactions, 4)
+	assert.Equal(t, opListStackResources, cassette.Interactions[0].Operation)
+	assert.Equal(t, opListStackResources, cassette.Interactions[1].Operation)
+	assert.Equal(t, opListResources, cassette.Interactions[2].Operation)
+	require.NotNil(t, cassette.Interactions[2].Error)
+	assert.Equal(t, "InvalidRequestException", cassette.Interactions[2].Error.Code)
+
+	replayed, err := NewReplayLookups(path)
+	require.NoError(t, err)
+	assert.Equal(t, "us-west-2", replayed.Region)
+	assert.Equal(t, common.PrimaryResourceID(listenerArn), resolveListener(t, replayed))
+	assert.Equal(t, live.CfnStackResources, replayed.CfnStackResources)
+}
+
+func TestCassetteReplayUnknownRequest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	require.NoError(t, (&Cassette{Version: cassetteVersion, Region: "us-west-2"}).WriteFile(path))
+
+	l, err := NewReplayLookups(path)
+	require.NoError(t, err)
+	err = l.GetStackResources(context.Background(), "Stack")
+	assert.ErrorContains(t, err, "no recorded cloudformation:ListStackResources call matches request")
+}
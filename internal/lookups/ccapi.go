@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -40,26 +41,13 @@ var newCCAPIRetryer = func() aws.Retryer {
 	})
 }
 
-// A key to use for caching resources
-type resourceCacheKey string
-
-// makeCacheKey creates a cache key for a resource based on its type and model
-// This combination should create a unique key for each resource
-func makeCacheKey(resourceType common.ResourceType, resourceModel map[string]string) resourceCacheKey {
-	key := string(resourceType)
-	for k, v := range resourceModel {
-		key += k + v
-	}
-	return resourceCacheKey(key)
-}
-
 // CCAPIClient is a client for the cloudcontrol API
 type CCAPIClient interface {
 	GetPager(typeName string, resourceModel *string) ListResourcesPager
 }
 
 type ccapiClient struct {
-	client *cloudcontrol.Client
+	client CloudControlAPI
 }
 
 // GetPager gets a ListResourcesPager for a given type and resource model
@@ -72,25 +60,29 @@ func (c *ccapiClient) GetPager(typeName string, resourceModel *string) ListResou
 
 type ccapiLookups struct {
 	ccapiClient        CCAPIClient
-	cfnStackResources  map[common.LogicalResourceID]CfnStackResource
-	ccapiResourceCache map[resourceCacheKey][]types.ResourceDescription
+	cfnStackResources  map[StackResourceKey]CfnStackResource
+	ccapiResourceCache *ResourceCache
 	customResolvers    map[common.ResourceType]customResolver
-	eventsClient       eventsClient
+	eventsClient       EventBridgeAPI
 	region             string
 	account            string
+	// props holds the inputs of each resource being resolved, keyed by StackResourceKey. The
+	// stack resources are shared by concurrent creates, so they are never written to.
+	props sync.Map
 }
 
-type eventsClient interface {
-	DescribeRule(ctx context.Context, params *eventbridge.DescribeRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.DescribeRuleOutput, error)
-}
+type customResolver func(ctx context.Context, key StackResourceKey, primaryProp resource.PropertyKey) (common.PrimaryResourceID, error)
 
-type customResolver func(ctx context.Context, logicalID common.LogicalResourceID, primaryProp resource.PropertyKey) (common.PrimaryResourceID, error)
-
-func NewCCApiLookups(ctx context.Context, client *cloudcontrol.Client, cfnStackResources map[common.LogicalResourceID]CfnStackResource, region, account string, eventsClient eventsClient) (*ccapiLookups, error) {
+// NewCCApiLookups creates CCAPI lookups. ListResources results are shared through cache; pass nil
+// to use a cache private to the returned lookups.
+func NewCCApiLookups(ctx context.Context, client CloudControlAPI, cfnStackResources map[StackResourceKey]CfnStackResource, region, account string, eventsClient EventBridgeAPI, cache *ResourceCache) (*ccapiLookups, error) {
+	if cache == nil {
+		cache = NewResourceCache()
+	}
 	c := &ccapiLookups{
 		ccapiClient:        &ccapiClient{client: client},
 		cfnStackResources:  cfnStackResources,
-		ccapiResourceCache: make(map[resourceCacheKey][]types.ResourceDescription),
+		ccapiResourceCache: cache,
 		eventsClient:       eventsClient,
 		region:             region,
 		account:            account,
@@ -103,7 +95,7 @@ func NewCCApiLookups(ctx context.Context, client *cloudcontrol.Client, cfnStackR
 
 func (c *ccapiLookups) FindLogicalResourceID(
 	urn resource.URN,
-) (common.LogicalResourceID, error) {
+) (StackResourceKey, error) {
 	return findLogicalResourceID(urn, metadata.NewCCApiMetadataSource(), c.cfnStackResources)
 }
 
@@ -111,35 +103,60 @@ func (c *ccapiLookups) FindLogicalResourceID(
 func (c *ccapiLookups) FindPrimaryResourceID(
 	ctx context.Context,
 	resourceToken tokens.Type,
-	logicalID common.LogicalResourceID,
+	key StackResourceKey,
 	props map[string]any,
 ) (common.PrimaryResourceID, error) {
-	c.cfnStackResources[logicalID] = CfnStackResource{
-		ResourceType: c.cfnStackResources[logicalID].ResourceType,
-		LogicalID:    logicalID,
-		PhysicalID:   c.cfnStackResources[logicalID].PhysicalID,
-		Props:        props,
-	}
-	resourceType, idParts, err := getPrimaryIdentifiers(metadata.NewCCApiMetadataSource(), resourceToken)
+	id, _, err := c.ResolvePrimaryResourceID(ctx, resourceToken, key, props)
+	return id, err
+}
+
+// ResolvePrimaryResourceID behaves like [FindPrimaryResourceID] but also reports the strategy
+// that produced the ID.
+func (c *ccapiLookups) ResolvePrimaryResourceID(
+	ctx context.Context,
+	resourceToken tokens.Type,
+	key StackResourceKey,
+	props map[string]any,
+) (common.PrimaryResourceID, ResolutionStrategy, error) {
+	r := c.cfnStackResources[key]
+	r.LogicalID = key.LogicalID
+	r.StackName = key.StackName
+	c.props.Store(key, props)
+	md := metadata.NewCCApiMetadataSource()
+	resourceType, idParts, err := getPrimaryIdentifiers(md, resourceToken)
 	if err != nil {
-		return "", err
+		return "", "", err
+	}
+	if template, ok := md.IDTemplate(resourceType); ok {
+		id, err := expandIDTemplate(template, key, r, c.region, c.account, props)
+		return id, ResolutionTemplate, err
 	}
 	switch len(idParts) {
 	case 0:
-		return "", fmt.Errorf("ResourceType %q with logicalID %q has no primary identifiers", resourceType, logicalID)
+		return "", "", fmt.Errorf("ResourceType %q with logicalID %q has no primary identifiers", resourceType, key)
 	case 1:
-		return c.findOwnNativeId(ctx, resourceType, logicalID, idParts[0])
+		return c.findOwnNativeId(ctx, resourceType, key, idParts[0])
 	default:
 		// TODO: debug logging
-		// fmt.Printf("Rendering Resource Models for %s - %s: Parts: %v: Props: %v", resourceType, logicalID, idParts, props)
+		// fmt.Printf("Rendering Resource Models for %s - %s: Parts: %v: Props: %v", resourceType, key, idParts, props)
 		resourceModel, err := renderResourceModel(resourceType, idParts, props, func(s string) string {
 			return naming.ToCfnName(string(s), nil)
 		})
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
-		return c.findCCApiCompositeId(ctx, resourceType, logicalID, resourceModel)
+		id, err := c.findCCApiCompositeId(ctx, resourceType, key, resourceModel)
+		return id, ResolutionComposite, err
+	}
+}
+
+// resourceProps returns the inputs of the resource being resolved, falling back to the properties
+// the stack resource was discovered with.
+func (c *ccapiLookups) resourceProps(key StackResourceKey) map[string]any {
+	if props, ok := c.props.Load(key); ok {
+		return props.(map[string]any)
 	}
+	return c.cfnStackResources[key].Props
 }
 
 // findCCApiCompositeId attempts to find the resource where the identifier is a composite id made up
@@ -147,12 +164,12 @@ func (c *ccapiLookups) FindPrimaryResourceID(
 func (c *ccapiLookups) findCCApiCompositeId(
 	ctx context.Context,
 	resourceType common.ResourceType,
-	logicalID common.LogicalResourceID,
+	key StackResourceKey,
 	resourceModel map[string]string,
 ) (common.PrimaryResourceID, error) {
-	if r, ok := c.cfnStackResources[logicalID]; ok {
+	if r, ok := c.cfnStackResources[key]; ok {
 		suffix := string(r.PhysicalID)
-		id, err := c.findResourceIdentifier(ctx, resourceType, logicalID, suffix, resourceModel)
+		id, err := c.findResourceIdentifier(ctx, resourceType, key, suffix, resourceModel)
 		if err != nil {
 			return "", err
 		}
@@ -161,78 +178,97 @@ func (c *ccapiLookups) findCCApiCompositeId(
 	return "", fmt.Errorf("Couldn't find id")
 }
 
+// NativeIDNeedsLookup reports whether findOwnNativeId resolves the single-part Cloud Control
+// identifier of a resource with physicalID through a lookup, rather than using the physical ID as
+// is: the type's strategy says so, or the identifier is an ARN and the physical ID isn't.
+func NativeIDNeedsLookup(resourceType common.ResourceType, primaryID resource.PropertyKey, physicalID common.PhysicalResourceID) bool {
+	idPropertyName := strings.ToLower(string(primaryID))
+	switch metadata.NewCCApiMetadataSource().GetIdPropertyStrategy(resourceType, idPropertyName) {
+	case metadata.StrategyPhysicalID:
+		return false
+	case metadata.StrategyLookup:
+		return true
+	case metadata.StrategyCustom:
+		if strings.Contains(string(physicalID), "|") {
+			return true
+		}
+	}
+	return strings.HasSuffix(idPropertyName, "arn") && !strings.HasPrefix(string(physicalID), "arn:")
+}
+
 // findOwnId should only be used when the resource only has a single element in it's identifier
 func (c *ccapiLookups) findOwnNativeId(
 	ctx context.Context,
 	resourceType common.ResourceType,
-	logicalID common.LogicalResourceID,
+	key StackResourceKey,
 	primaryID resource.PropertyKey,
-) (common.PrimaryResourceID, error) {
+) (common.PrimaryResourceID, ResolutionStrategy, error) {
 	idPropertyName := strings.ToLower(string(primaryID))
 	md := metadata.NewCCApiMetadataSource()
 	strategy := md.GetIdPropertyStrategy(resourceType, idPropertyName)
 
 	// 1. Check for explicit strategy override
 	if strategy == metadata.StrategyPhysicalID {
-		if r, ok := c.cfnStackResources[logicalID]; ok {
+		if r, ok := c.cfnStackResources[key]; ok {
 			// NOTE! Assuming that PrimaryResourceID matches the PhysicalID.
-			return common.PrimaryResourceID(r.PhysicalID), nil
+			return common.PrimaryResourceID(r.PhysicalID), ResolutionPhysicalID, nil
 		}
-		return "", fmt.Errorf("Resource doesn't exist in this stack which isn't possible!")
+		return "", "", fmt.Errorf("Resource doesn't exist in this stack which isn't possible!")
 	} else if strategy == metadata.StrategyLookup {
-		if r, ok := c.cfnStackResources[logicalID]; ok {
+		if r, ok := c.cfnStackResources[key]; ok {
 			suffix := string(r.PhysicalID)
-			id, err := c.findResourceIdentifier(ctx, resourceType, logicalID, suffix, nil)
+			id, err := c.findResourceIdentifier(ctx, resourceType, key, suffix, nil)
 			if err != nil {
-				return "", fmt.Errorf("Could not find id for %s: %w", logicalID, err)
+				return "", "", fmt.Errorf("Could not find id for %s: %w", key, err)
 			}
-			return id, nil
+			return id, ResolutionLookup, nil
 		}
 	} else if strategy == metadata.StrategyCustom {
 		if resolver, ok := c.customResolvers[resourceType]; ok {
-			if r, ok := c.cfnStackResources[logicalID]; ok && strings.Contains(string(r.PhysicalID), "|") {
-				return resolver(ctx, logicalID, primaryID)
+			if r, ok := c.cfnStackResources[key]; ok && strings.Contains(string(r.PhysicalID), "|") {
+				id, err := resolver(ctx, key, primaryID)
+				return id, ResolutionCustom, err
 			}
 			// If the physical ID isn't composite, fall back to the ARN heuristic/lookup path below.
 		} else {
-			return "", fmt.Errorf("No custom resolver defined for %s", resourceType)
+			return "", "", fmt.Errorf("No custom resolver defined for %s", resourceType)
 		}
 	}
 
 	// 2. ARN heuristic: properties ending in 'arn' typically need lookup
 	if strings.HasSuffix(idPropertyName, "arn") {
-		if r, ok := c.cfnStackResources[logicalID]; ok {
+		if r, ok := c.cfnStackResources[key]; ok {
 			// Many resources already expose an ARN-shaped PhysicalID; accept it directly.
 			if strings.HasPrefix(string(r.PhysicalID), "arn:") {
-				return common.PrimaryResourceID(r.PhysicalID), nil
+				return common.PrimaryResourceID(r.PhysicalID), ResolutionARN, nil
 			}
 			suffix := string(r.PhysicalID)
-			id, err := c.findResourceIdentifier(ctx, resourceType, logicalID, suffix, nil)
+			id, err := c.findResourceIdentifier(ctx, resourceType, key, suffix, nil)
 			if err != nil {
-				return "", fmt.Errorf("Could not find id for %s: %w", logicalID, err)
+				return "", "", fmt.Errorf("Could not find id for %s: %w", key, err)
 			}
-			return id, nil
+			return id, ResolutionARN, nil
 		}
 	}
 
 	// 3. Default: assume PhysicalID matches the primary identifier
-	if r, ok := c.cfnStackResources[logicalID]; ok {
-		return common.PrimaryResourceID(r.PhysicalID), nil
+	if r, ok := c.cfnStackResources[key]; ok {
+		return common.PrimaryResourceID(r.PhysicalID), ResolutionPhysicalID, nil
 	}
-	return "", fmt.Errorf("Resource doesn't exist in this stack which isn't possible!")
+	return "", "", fmt.Errorf("Resource doesn't exist in this stack which isn't possible!")
 }
 
 func (c *ccapiLookups) resolveEventsRule(
 	ctx context.Context,
-	logicalID common.LogicalResourceID,
+	key StackResourceKey,
 	_ resource.PropertyKey,
 ) (common.PrimaryResourceID, error) {
 	if c.eventsClient == nil {
-		return "", fmt.Errorf("missing events client for %s", logicalID)
+		return "", fmt.Errorf("missing events client for %s", key)
 	}
-	r, ok := c.cfnStackResources[logicalID]
+	r, ok := c.cfnStackResources[key]
 	if !ok {
-		return "", fmt.Errorf("Resource %s not found in stack", logicalID)
+		return "", fmt.Errorf("Resource %s not found in stack", key)
 	}
 
 	parts := strings.SplitN(string(r.PhysicalID), "|", 2)
@@ -241,7 +277,7 @@ func (c *ccapiLookups) resolveEventsRule(
 	}
 	busName, ruleName := parts[0], parts[1]
 	if ruleName == "" {
-		return "", fmt.Errorf("rule name missing in physical id for %s", logicalID)
+		return "", fmt.Errorf("rule name missing in physical id for %s", key)
 	}
 
 	input := &eventbridge.DescribeRuleInput{
@@ -253,10 +289,10 @@ func (c *ccapiLookups) resolveEventsRule(
 
 	output, err := c.eventsClient.DescribeRule(ctx, input)
 	if err != nil {
-		return "", fmt.Errorf("describe rule failed for %s: %w", logicalID, err)
+		return "", fmt.Errorf("describe rule failed for %s: %w", key, err)
 	}
 	if output.Arn == nil || *output.Arn == "" {
-		return "", fmt.Errorf("describe rule returned empty arn for %s", logicalID)
+		return "", fmt.Errorf("describe rule returned empty arn for %s", key)
 	}
 	return common.PrimaryResourceID(*output.Arn), nil
 }
@@ -270,7 +306,7 @@ func (c *ccapiLookups) resolveEventsRule(
 func (c *ccapiLookups) findResourceIdentifier(
 	ctx context.Context,
 	resourceType common.ResourceType,
-	logicalID common.LogicalResourceID,
+	key StackResourceKey,
 	suffix string,
 	resourceModel map[string]string,
 ) (common.PrimaryResourceID, error) {
@@ -307,27 +343,27 @@ func (c *ccapiLookups) findResourceIdentifier(
 			}
 
 			if missingProperty != "" {
-				fmt.Printf("Found missing property for %s %s: %s", resourceType, logicalID, missingProperty)
+				fmt.Printf("Found missing property for %s %s: %s", resourceType, key, missingProperty)
 				required := []resource.PropertyKey{resource.PropertyKey(missingProperty)}
-				resourceModel, err = renderResourceModel(resourceType, []resource.PropertyKey{}, c.cfnStackResources[logicalID].Props, func(s string) string {
+				resourceModel, err = renderResourceModel(resourceType, []resource.PropertyKey{}, c.resourceProps(key), func(s string) string {
 					return s
 				}, required...)
 				if err != nil {
 					return "", fmt.Errorf("Error rendering resource model: %w", err)
 				}
 				if len(resourceModel) == 0 {
-					if derived := deriveMissingProperty(resourceType, missingProperty, c.cfnStackResources[logicalID].Props); len(derived) > 0 {
+					if derived := deriveMissingProperty(resourceType, missingProperty, c.resourceProps(key)); len(derived) > 0 {
 						resourceModel = derived
 					} else {
-						return "", fmt.Errorf("Error finding resource of type %s with resourceModel: %v Props: %v: MissingProperty %s", resourceType, resourceModel, c.cfnStackResources[logicalID].Props, missingProperty)
+						return "", fmt.Errorf("Error finding resource of type %s with resourceModel: %v Props: %v: MissingProperty %s", resourceType, resourceModel, c.resourceProps(key), missingProperty)
 					}
 				}
 				// run it again with the new resource model
-				return c.findResourceIdentifier(ctx, resourceType, logicalID, suffix, resourceModel)
+				return c.findResourceIdentifier(ctx, resourceType, key, suffix, resourceModel)
 			}
-			return "", fmt.Errorf("Error finding resource of type %s with resourceModel: %v Props: %v: MissingProperty %s:  %w", resourceType, resourceModel, c.cfnStackResources[logicalID].Props, missingProperty, err)
+			return "", fmt.Errorf("Error finding resource of type %s with resourceModel: %v Props: %v: MissingProperty %s:  %w", resourceType, resourceModel, c.resourceProps(key), missingProperty, err)
 		} else {
-			return "", fmt.Errorf("Unknown error: Error finding resource of type %s with resourceModel: %v Props: %v: %w", resourceType, resourceModel, c.cfnStackResources[logicalID].Props, err)
+			return "", fmt.Errorf("Unknown error: Error finding resource of type %s with resourceModel: %v Props: %v: %w", resourceType, resourceModel, c.resourceProps(key), err)
 		}
 	}
 
@@ -349,11 +385,17 @@ func (c *ccapiLookups) listResources(
 	resourceType common.ResourceType,
 	resourceModel map[string]string,
 ) ([]types.ResourceDescription, error) {
-	cacheKey := makeCacheKey(resourceType, resourceModel)
-	if val, ok := c.ccapiResourceCache[cacheKey]; ok {
-		return val, nil
-	}
+	return c.ccapiResourceCache.load(ctx, makeCacheKey(resourceType, resourceModel), func(ctx context.Context) ([]types.ResourceDescription, error) {
+		return c.fetchResources(ctx, resourceType, resourceModel)
+	})
+}
 
+// fetchResources pages through ListResources for a given type and model, retrying throttled calls.
+func (c *ccapiLookups) fetchResources(
+	ctx context.Context,
+	resourceType common.ResourceType,
+	resourceModel map[string]string,
+) ([]types.ResourceDescription, error) {
 	var model *string
 	if len(resourceModel) > 0 {
 		val, err := json.Marshal(resourceModel)
@@ -412,7 +454,6 @@ func (c *ccapiLookups) listResources(
 		resources = append(resources, output.ResourceDescriptions...)
 	}
 
-	c.ccapiResourceCache[cacheKey] = resources
 	return resources, nil
 }
 
@@ -3,6 +3,8 @@ package lookups
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -87,7 +89,7 @@ func TestFindPrimaryResourceID(t *testing.T) {
 	t.Run("simple", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws-native:s3:Bucket")
-		logicalID := common.LogicalResourceID("bucket")
+		key := StackResourceKey{LogicalID: "bucket"}
 		props := map[string]interface{}{}
 
 		ccapiClient := &mockCCAPIClient{
@@ -104,19 +106,19 @@ func TestFindPrimaryResourceID(t *testing.T) {
 		}
 
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"bucket": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "bucket"}: {
 					ResourceType: "AWS::S3::Bucket",
 					PhysicalID:   "bucket-name",
 					LogicalID:    "bucket",
 				},
 			},
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: map[resourceCacheKey][]types.ResourceDescription{},
+			ccapiResourceCache: NewResourceCache(),
 		}
 		stackResource := ccapiLookups.cfnStackResources
 
-		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
 		assert.NoError(t, err)
 		assert.Equal(t, common.PrimaryResourceID("bucket-name"), actual)
 		assert.Equal(t, stackResource, ccapiLookups.cfnStackResources)
@@ -125,15 +127,15 @@ func TestFindPrimaryResourceID(t *testing.T) {
 	t.Run("events rule custom resolver default bus", func(t *testing.T) {
 		ctx := context.Background()
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"Rule": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "Rule"}: {
 					ResourceType: "AWS::Events::Rule",
 					PhysicalID:   "default|my-rule",
 					LogicalID:    "Rule",
 				},
 			},
 			ccapiClient:        &mockCCAPIClient{},
-			ccapiResourceCache: make(map[resourceCacheKey][]types.ResourceDescription),
+			ccapiResourceCache: NewResourceCache(),
 			region:             "us-west-2",
 			account:            "123456789012",
 			eventsClient: &mockEventsClient{
@@ -144,10 +146,10 @@ func TestFindPrimaryResourceID(t *testing.T) {
 			"AWS::Events::Rule": ccapiLookups.resolveEventsRule,
 		}
 
-		actual, err := ccapiLookups.findOwnNativeId(
+		actual, _, err := ccapiLookups.findOwnNativeId(
 			ctx,
 			common.ResourceType("AWS::Events::Rule"),
-			common.LogicalResourceID("Rule"),
+			StackResourceKey{LogicalID: "Rule"},
 			resource.PropertyKey("Arn"),
 		)
 		assert.NoError(t, err)
@@ -163,15 +165,15 @@ func TestFindPrimaryResourceID(t *testing.T) {
 	t.Run("events rule custom resolver custom bus", func(t *testing.T) {
 		ctx := context.Background()
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"Rule": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "Rule"}: {
 					ResourceType: "AWS::Events::Rule",
 					PhysicalID:   "orders|match-order",
 					LogicalID:    "Rule",
 				},
 			},
 			ccapiClient:        &mockCCAPIClient{},
-			ccapiResourceCache: make(map[resourceCacheKey][]types.ResourceDescription),
+			ccapiResourceCache: NewResourceCache(),
 			region:             "us-west-2",
 			account:            "123456789012",
 			eventsClient: &mockEventsClient{
@@ -182,10 +184,10 @@ func TestFindPrimaryResourceID(t *testing.T) {
 			"AWS::Events::Rule": ccapiLookups.resolveEventsRule,
 		}
 
-		actual, err := ccapiLookups.findOwnNativeId(
+		actual, _, err := ccapiLookups.findOwnNativeId(
 			ctx,
 			common.ResourceType("AWS::Events::Rule"),
-			common.LogicalResourceID("Rule"),
+			StackResourceKey{LogicalID: "Rule"},
 			resource.PropertyKey("Arn"),
 		)
 		assert.NoError(t, err)
@@ -214,24 +216,24 @@ func TestFindPrimaryResourceID(t *testing.T) {
 			},
 		}
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"Rule": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "Rule"}: {
 					ResourceType: "AWS::Events::Rule",
 					PhysicalID:   "my-rule",
 					LogicalID:    "Rule",
 				},
 			},
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: make(map[resourceCacheKey][]types.ResourceDescription),
+			ccapiResourceCache: NewResourceCache(),
 		}
 		ccapiLookups.customResolvers = map[common.ResourceType]customResolver{
 			"AWS::Events::Rule": ccapiLookups.resolveEventsRule,
 		}
 
-		actual, err := ccapiLookups.findOwnNativeId(
+		actual, _, err := ccapiLookups.findOwnNativeId(
 			ctx,
 			common.ResourceType("AWS::Events::Rule"),
-			common.LogicalResourceID("Rule"),
+			StackResourceKey{LogicalID: "Rule"},
 			resource.PropertyKey("Arn"),
 		)
 		assert.NoError(t, err)
@@ -248,21 +250,21 @@ func TestFindPrimaryResourceID(t *testing.T) {
 		}
 
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"Topic": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "Topic"}: {
 					ResourceType: "AWS::SNS::Topic",
 					PhysicalID:   "arn:aws:sns:us-west-2:123456789012:my-topic",
 					LogicalID:    "Topic",
 				},
 			},
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: make(map[resourceCacheKey][]types.ResourceDescription),
+			ccapiResourceCache: NewResourceCache(),
 		}
 
-		actual, err := ccapiLookups.findOwnNativeId(
+		actual, _, err := ccapiLookups.findOwnNativeId(
 			ctx,
 			common.ResourceType("AWS::SNS::Topic"),
-			common.LogicalResourceID("Topic"),
+			StackResourceKey{LogicalID: "Topic"},
 			resource.PropertyKey("TopicArn"),
 		)
 		assert.NoError(t, err)
@@ -286,21 +288,21 @@ func TestFindPrimaryResourceID(t *testing.T) {
 		}
 
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"Topic": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "Topic"}: {
 					ResourceType: "AWS::SNS::Topic",
 					PhysicalID:   "my-topic",
 					LogicalID:    "Topic",
 				},
 			},
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: make(map[resourceCacheKey][]types.ResourceDescription),
+			ccapiResourceCache: NewResourceCache(),
 		}
 
-		actual, err := ccapiLookups.findOwnNativeId(
+		actual, _, err := ccapiLookups.findOwnNativeId(
 			ctx,
 			common.ResourceType("AWS::SNS::Topic"),
-			common.LogicalResourceID("Topic"),
+			StackResourceKey{LogicalID: "Topic"},
 			resource.PropertyKey("TopicArn"),
 		)
 		assert.NoError(t, err)
@@ -310,7 +312,7 @@ func TestFindPrimaryResourceID(t *testing.T) {
 	t.Run("composite id and multiple resources", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws-native:ec2:Route")
-		logicalID := common.LogicalResourceID("route1")
+		key := StackResourceKey{LogicalID: "route1"}
 		props := map[string]interface{}{
 			"RouteTableId": "rtb-1234",
 		}
@@ -332,32 +334,33 @@ func TestFindPrimaryResourceID(t *testing.T) {
 		}
 
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"route1": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "route1"}: {
 					ResourceType: "AWS::EC2::Route",
 					PhysicalID:   "rtb-1234|0.0.0.0/0",
 					LogicalID:    "route1",
 				},
-				"route2": {
+				{LogicalID: "route2"}: {
 					ResourceType: "AWS::EC2::Route",
 					PhysicalID:   "rtb-1234|10.0.0.0/16",
 					LogicalID:    "route2",
 				},
 			},
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: map[resourceCacheKey][]types.ResourceDescription{},
+			ccapiResourceCache: NewResourceCache(),
 		}
 
-		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
 		assert.NoError(t, err)
 		assert.Equal(t, common.PrimaryResourceID("rtb-1234|0.0.0.0/0"), actual)
-		assert.Equal(t, ccapiLookups.cfnStackResources[common.LogicalResourceID("route1")].Props, props)
+		assert.Equal(t, props, ccapiLookups.resourceProps(StackResourceKey{LogicalID: "route1"}))
+		assert.Nil(t, ccapiLookups.cfnStackResources[StackResourceKey{LogicalID: "route1"}].Props, "shared stack resources must not be written")
 	})
 
 	t.Run("error rendering resource model", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws-native:ec2:Route")
-		logicalID := common.LogicalResourceID("route1")
+		key := StackResourceKey{LogicalID: "route1"}
 		props := map[string]interface{}{
 			"RouteTableId": []string{"rtb-1234"}, // invalid type
 		}
@@ -369,19 +372,19 @@ func TestFindPrimaryResourceID(t *testing.T) {
 		}
 
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources:  map[common.LogicalResourceID]CfnStackResource{},
+			cfnStackResources:  map[StackResourceKey]CfnStackResource{},
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: map[resourceCacheKey][]types.ResourceDescription{},
+			ccapiResourceCache: NewResourceCache(),
 		}
 
-		_, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		_, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
 		assert.ErrorContains(t, err, "expected id property \"RouteTableId\" to be a string")
 	})
 
 	t.Run("Missing required property fallthrough", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws-native:elasticloadbalancingv2:Listener")
-		logicalID := common.LogicalResourceID("Listener")
+		key := StackResourceKey{LogicalID: "Listener"}
 		props := map[string]interface{}{
 			"LoadBalancerArn": "arn:aws:elasticloadbalancing:us-west-2:123456789012:loadbalancer/app/my-load-balancer/50dc6c495c0c9188",
 		}
@@ -409,8 +412,8 @@ func TestFindPrimaryResourceID(t *testing.T) {
 		}
 
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"Listener": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "Listener"}: {
 					ResourceType: "AWS::ElasticLoadBalancingV2::Listener",
 					PhysicalID:   "arn:aws:elasticloadbalancing:us-west-2:123456789012:listener/app/my-load-balancer/50dc6c495c0c9188/0467ef3c8400ae65",
 					LogicalID:    "Listener",
@@ -420,10 +423,10 @@ func TestFindPrimaryResourceID(t *testing.T) {
 				},
 			},
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: map[resourceCacheKey][]types.ResourceDescription{},
+			ccapiResourceCache: NewResourceCache(),
 		}
 
-		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
 		assert.NoError(t, err)
 		assert.Equal(t, common.PrimaryResourceID("arn:aws:elasticloadbalancing:us-west-2:123456789012:listener/app/my-load-balancer/50dc6c495c0c9188/0467ef3c8400ae65"), actual)
 	})
@@ -431,7 +434,7 @@ func TestFindPrimaryResourceID(t *testing.T) {
 	t.Run("ScalingPolicy uses composite identifier with retry", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws-native:applicationautoscaling:ScalingPolicy")
-		logicalID := common.LogicalResourceID("ScalingPolicy")
+		key := StackResourceKey{LogicalID: "ScalingPolicy"}
 		props := map[string]interface{}{
 			"PolicyName":        "MyPolicy",
 			"ResourceId":        "service/myCluster/myService",
@@ -460,8 +463,8 @@ func TestFindPrimaryResourceID(t *testing.T) {
 		}
 
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"ScalingPolicy": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "ScalingPolicy"}: {
 					ResourceType: "AWS::ApplicationAutoScaling::ScalingPolicy",
 					PhysicalID:   "arn:aws:autoscaling:us-west-2:123456789012:scalingPolicy:uuid:autoScalingGroupName/groupName:policyName/MyPolicy|ecs:service:DesiredCount",
 					LogicalID:    "ScalingPolicy",
@@ -469,10 +472,10 @@ func TestFindPrimaryResourceID(t *testing.T) {
 				},
 			},
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: make(map[resourceCacheKey][]types.ResourceDescription),
+			ccapiResourceCache: NewResourceCache(),
 		}
 
-		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
 		assert.NoError(t, err)
 		assert.Equal(t, common.PrimaryResourceID("arn:aws:autoscaling:us-west-2:123456789012:scalingPolicy:uuid:autoScalingGroupName/groupName:policyName/MyPolicy|ecs:service:DesiredCount"), actual)
 	})
@@ -480,7 +483,7 @@ func TestFindPrimaryResourceID(t *testing.T) {
 	t.Run("BucketPolicy uses physical ID strategy", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws-native:s3:BucketPolicy")
-		logicalID := common.LogicalResourceID("BucketPolicy")
+		key := StackResourceKey{LogicalID: "BucketPolicy"}
 		props := map[string]interface{}{
 			"Bucket": "my-bucket",
 			"PolicyDocument": map[string]interface{}{
@@ -497,8 +500,8 @@ func TestFindPrimaryResourceID(t *testing.T) {
 		}
 
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"BucketPolicy": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "BucketPolicy"}: {
 					ResourceType: "AWS::S3::BucketPolicy",
 					PhysicalID:   "my-bucket", // Physical ID is the bucket name
 					LogicalID:    "BucketPolicy",
@@ -506,10 +509,10 @@ func TestFindPrimaryResourceID(t *testing.T) {
 				},
 			},
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: make(map[resourceCacheKey][]types.ResourceDescription),
+			ccapiResourceCache: NewResourceCache(),
 		}
 
-		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
 		assert.NoError(t, err)
 		assert.Equal(t, common.PrimaryResourceID("my-bucket"), actual)
 	})
@@ -517,7 +520,7 @@ func TestFindPrimaryResourceID(t *testing.T) {
 	t.Run("Missing required property fallthrough - new format", func(t *testing.T) {
 		ctx := context.Background()
 		resourceToken := tokens.Type("aws-native:lambda:Permission")
-		logicalID := common.LogicalResourceID("Permission")
+		key := StackResourceKey{LogicalID: "Permission"}
 		props := map[string]interface{}{
 			"FunctionName": "my-function",
 		}
@@ -545,8 +548,8 @@ func TestFindPrimaryResourceID(t *testing.T) {
 		}
 
 		ccapiLookups := &ccapiLookups{
-			cfnStackResources: map[common.LogicalResourceID]CfnStackResource{
-				"Permission": {
+			cfnStackResources: map[StackResourceKey]CfnStackResource{
+				{LogicalID: "Permission"}: {
 					ResourceType: "AWS::Lambda::Permission",
 					PhysicalID:   "my-function/permission-id",
 					LogicalID:    "Permission",
@@ -556,10 +559,10 @@ func TestFindPrimaryResourceID(t *testing.T) {
 				},
 			},
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: map[resourceCacheKey][]types.ResourceDescription{},
+			ccapiResourceCache: NewResourceCache(),
 		}
 
-		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, logicalID, props)
+		actual, err := ccapiLookups.FindPrimaryResourceID(ctx, resourceToken, key, props)
 		assert.NoError(t, err)
 		assert.Equal(t, common.PrimaryResourceID("my-function/permission-id"), actual)
 	})
@@ -597,7 +600,7 @@ func TestFindPrimaryResourceID(t *testing.T) {
 
 		ccapiLookups := &ccapiLookups{
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: make(map[resourceCacheKey][]types.ResourceDescription),
+			ccapiResourceCache: NewResourceCache(),
 		}
 
 		res, err := ccapiLookups.listResources(ctx, common.ResourceType("AWS::S3::Bucket"), map[string]string{})
@@ -636,10 +639,52 @@ func TestFindPrimaryResourceID(t *testing.T) {
 
 		ccapiLookups := &ccapiLookups{
 			ccapiClient:        ccapiClient,
-			ccapiResourceCache: make(map[resourceCacheKey][]types.ResourceDescription),
+			ccapiResourceCache: NewResourceCache(),
 		}
 
 		_, err := ccapiLookups.listResources(ctx, common.ResourceType("AWS::S3::Bucket"), map[string]string{})
 		assert.Error(t, err)
 	})
 }
+
+func TestResolvePrimaryResourceIDConcurrently(t *testing.T) {
+	ctx := context.Background()
+	cfnStackResources := map[StackResourceKey]CfnStackResource{}
+	for i := range 8 {
+		logicalID := common.LogicalResourceID(fmt.Sprintf("route%d", i))
+		cfnStackResources[StackResourceKey{LogicalID: logicalID}] = CfnStackResource{
+			ResourceType: "AWS::EC2::Route",
+			PhysicalID:   common.PhysicalResourceID(fmt.Sprintf("rtb-%d|0.0.0.0/0", i)),
+			LogicalID:    logicalID,
+		}
+	}
+	client := &mockCCAPIClient{
+		mockGetPager: func(typeName string, resourceModel *string) ListResourcesPager {
+			var model map[string]string
+			assert.NoError(t, json.Unmarshal([]byte(*resourceModel), &model))
+			return &mockListResourcesPager{
+				typeName:             typeName,
+				resourceDescriptions: []types.ResourceDescription{{Identifier: aws.String(model["RouteTableId"] + "|0.0.0.0/0")}},
+			}
+		},
+	}
+	cache := NewResourceCache()
+
+	// Every create builds its own lookups over the same stack resources.
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := &ccapiLookups{cfnStackResources: cfnStackResources, ccapiClient: client, ccapiResourceCache: cache}
+			key := StackResourceKey{LogicalID: common.LogicalResourceID(fmt.Sprintf("route%d", i))}
+			id, err := c.FindPrimaryResourceID(ctx, "aws-native:ec2:Route", key, map[string]any{"RouteTableId": fmt.Sprintf("rtb-%d", i)})
+			assert.NoError(t, err)
+			assert.Equal(t, common.PrimaryResourceID(fmt.Sprintf("rtb-%d|0.0.0.0/0", i)), id)
+		}()
+	}
+	wg.Wait()
+	for key, r := range cfnStackResources {
+		assert.Nil(t, r.Props, "%s was written", key)
+	}
+}
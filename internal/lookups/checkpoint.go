@@ -0,0 +1,195 @@
+package lookups
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+)
+
+// CheckpointEntry is a resolved import ID, written to the checkpoint once the resource is imported.
+type CheckpointEntry struct {
+	URN       resource.URN             `json:"urn"`
+	StackName common.StackName         `json:"stackName,omitempty"`
+	LogicalID common.LogicalResourceID `json:"logicalId,omitempty"`
+	ID        common.PrimaryResourceID `json:"id"`
+	Strategy  ResolutionStrategy       `json:"strategy"`
+}
+
+func (e CheckpointEntry) key() StackResourceKey {
+	return StackResourceKey{StackName: e.StackName, LogicalID: e.LogicalID}
+}
+
+// Checkpoint records the import ID of every resource imported during a run in a JSON lines file,
+// so a run that failed partway can resume without repeating the lookups. A nil *Checkpoint records
+// nothing.
+type Checkpoint struct {
+	mu      sync.Mutex
+	file    *os.File
+	resumed map[resource.URN]CheckpointEntry
+	keys    map[StackResourceKey]bool
+	// pending holds the IDs resolved this run until their import succeeds
+	pending map[resource.URN]CheckpointEntry
+	err     error
+}
+
+// OpenCheckpoint starts the checkpoint at path. With resume, the entries already in the file are
+// used instead of resolving those resources again and kept in the file; otherwise the file is
+// started over.
+func OpenCheckpoint(path string, resume bool) (*Checkpoint, error) {
+	c := &Checkpoint{
+		resumed: map[resource.URN]CheckpointEntry{},
+		keys:    map[StackResourceKey]bool{},
+		pending: map[resource.URN]CheckpointEntry{},
+	}
+	var entries []CheckpointEntry
+	if resume {
+		var err error
+		if entries, err = readCheckpoint(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open checkpoint %s: %w", path, err)
+	}
+	c.file = file
+	// The entries are written back so a truncated last line doesn't end up in the middle of the file.
+	for _, e := range entries {
+		c.resumed[e.URN] = e
+		if e.LogicalID != "" {
+			c.keys[e.key()] = true
+		}
+		c.record(e)
+	}
+	if c.err != nil {
+		_ = file.Close()
+		return nil, c.err
+	}
+	return c, nil
+}
+
+// readCheckpoint reads the entries of a checkpoint file. A missing file has no entries, and a
+// truncated last line, left by a run that was killed while writing it, is ignored.
+func readCheckpoint(path string) ([]CheckpointEntry, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []CheckpointEntry
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var e CheckpointEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("Invalid checkpoint %s at line %d: %w", path, i+1, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Resumed returns the number of resources resolved by the checkpoint being resumed.
+func (c *Checkpoint) Resumed() int {
+	if c == nil {
+		return 0
+	}
+	return len(c.resumed)
+}
+
+// resolved reports whether the checkpoint being resumed resolved the CloudFormation resource key.
+func (c *Checkpoint) resolved(key StackResourceKey) bool {
+	return c != nil && c.keys[key]
+}
+
+// ResolveImportID resolves the import ID of urn like the package level [ResolveImportID], but
+// returns the ID a resumed checkpoint has for the resource instead of looking it up again.
+// Overrides still win over the checkpoint, so a wrong ID can be fixed without discarding it. A newly
+// looked up ID is only written to the checkpoint once [Checkpoint.Imported] reports that its import
+// succeeded, so a failed import is looked up again when the run is resumed.
+func (c *Checkpoint) ResolveImportID(
+	ctx context.Context,
+	r Resolver,
+	overrides *IDOverrides,
+	urn resource.URN,
+	props map[string]any,
+) (StackResourceKey, common.PrimaryResourceID, ResolutionStrategy, error) {
+	if c == nil {
+		return ResolveImportID(ctx, r, overrides, urn, props)
+	}
+	if e, ok := c.resumed[urn]; ok {
+		_, urnOverride := overrides.ForURN(urn)
+		_, keyOverride := overrides.ForResource(e.key())
+		if !urnOverride && !keyOverride {
+			return e.key(), e.ID, e.Strategy, nil
+		}
+	}
+	key, id, strategy, err := ResolveImportID(ctx, r, overrides, urn, props)
+	if err == nil && strategy != ResolutionOverride {
+		c.mu.Lock()
+		c.pending[urn] = CheckpointEntry{URN: urn, StackName: key.StackName, LogicalID: key.LogicalID, ID: id, Strategy: strategy}
+		c.mu.Unlock()
+	}
+	return key, id, strategy, err
+}
+
+// Imported writes the ID resolved for urn to the checkpoint, now that the resource was imported
+// with it.
+func (c *Checkpoint) Imported(urn resource.URN) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	e, ok := c.pending[urn]
+	delete(c.pending, urn)
+	c.mu.Unlock()
+	if ok {
+		c.record(e)
+	}
+}
+
+func (c *Checkpoint) record(e CheckpointEntry) {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if _, err := c.file.Write(append(line, '\n')); err != nil {
+		c.err = fmt.Errorf("Failed to write checkpoint %s: %w", c.file.Name(), err)
+	}
+}
+
+// Close closes the checkpoint file, returning the first error writing it.
+func (c *Checkpoint) Close() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.file.Close(); err != nil && c.err == nil {
+		c.err = err
+	}
+	return c.err
+}
@@ -0,0 +1,111 @@
+package lookups
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+)
+
+func TestCheckpointResume(t *testing.T) {
+	ctx := context.Background()
+	key := StackResourceKey{StackName: "ApiStack", LogicalID: "JobsPolicy"}
+	path := filepath.Join(t.TempDir(), ".pulumi", "import-checkpoint.jsonl")
+
+	c, err := OpenCheckpoint(path, false)
+	require.NoError(t, err)
+	r := &stubResolver{key: key}
+	_, id, _, err := c.ResolveImportID(ctx, r, nil, overrideURN, nil)
+	require.NoError(t, err)
+	assert.Equal(t, common.PrimaryResourceID("looked-up"), id)
+	c.Imported(overrideURN)
+	require.NoError(t, c.Close())
+
+	// A run killed while writing leaves a partial last line.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"urn":"urn:pulumi:dev::app::aws:s3/bucket:Bucket::bu`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	t.Run("resume skips the lookup", func(t *testing.T) {
+		c, err := OpenCheckpoint(path, true)
+		require.NoError(t, err)
+		defer c.Close()
+		assert.Equal(t, 1, c.Resumed())
+		assert.True(t, c.resolved(key))
+
+		r := &stubResolver{key: key}
+		gotKey, id, strategy, err := c.ResolveImportID(ctx, r, nil, overrideURN, nil)
+		require.NoError(t, err)
+		assert.Equal(t, key, gotKey)
+		assert.Equal(t, common.PrimaryResourceID("looked-up"), id)
+		assert.Equal(t, ResolutionPhysicalID, strategy)
+		assert.Zero(t, r.resolved)
+
+		entries, err := readCheckpoint(path)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1, "resumed entries are kept without the truncated line")
+	})
+
+	t.Run("overrides win over the checkpoint", func(t *testing.T) {
+		c, err := OpenCheckpoint(path, true)
+		require.NoError(t, err)
+		defer c.Close()
+		o, err := NewIDOverrides(map[string]string{"ApiStack/JobsPolicy": "fixed"})
+		require.NoError(t, err)
+
+		_, id, strategy, err := c.ResolveImportID(ctx, &stubResolver{key: key}, o, overrideURN, nil)
+		require.NoError(t, err)
+		assert.Equal(t, common.PrimaryResourceID("fixed"), id)
+		assert.Equal(t, ResolutionOverride, strategy)
+	})
+
+	t.Run("without resume the checkpoint starts over", func(t *testing.T) {
+		c, err := OpenCheckpoint(path, false)
+		require.NoError(t, err)
+		require.NoError(t, c.Close())
+		entries, err := readCheckpoint(path)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestCheckpointResumeAfterFailedImport(t *testing.T) {
+	ctx := context.Background()
+	key := StackResourceKey{StackName: "ApiStack", LogicalID: "JobsPolicy"}
+	path := filepath.Join(t.TempDir(), "import-checkpoint.jsonl")
+
+	c, err := OpenCheckpoint(path, false)
+	require.NoError(t, err)
+	_, _, _, err = c.ResolveImportID(ctx, &stubResolver{key: key}, nil, overrideURN, nil)
+	require.NoError(t, err)
+	// The import with the resolved ID fails, so Imported is never called.
+	require.NoError(t, c.Close())
+
+	entries, err := readCheckpoint(path)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "IDs are only recorded once the import succeeds")
+
+	c, err = OpenCheckpoint(path, true)
+	require.NoError(t, err)
+	defer c.Close()
+	assert.Zero(t, c.Resumed())
+	r := &stubResolver{key: key}
+	_, id, _, err := c.ResolveImportID(ctx, r, nil, overrideURN, nil)
+	require.NoError(t, err)
+	assert.Equal(t, common.PrimaryResourceID("looked-up"), id)
+	assert.Equal(t, 1, r.resolved, "the resumed run looks the failed resource up again")
+}
+
+func TestReadCheckpointRejectsCorruptLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("not json\n{\"urn\":\"urn:pulumi:dev::app::aws:s3/bucket:Bucket::b\",\"id\":\"b\"}\n"), 0o600))
+	_, err := readCheckpoint(path)
+	assert.ErrorContains(t, err, "at line 1")
+}
@@ -0,0 +1,54 @@
+package lookups
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+)
+
+// Why the interceptors create a resource instead of importing it.
+const (
+	createReasonUnsupported  = "Resource type is not supported for import"
+	createReasonInlinePolicy = "Inline policies can't be imported as a managed policy"
+	// CreateReasonPolicyAttachment is why an attachment of a policy that wasn't imported is created.
+	CreateReasonPolicyAttachment = "Policy attachment belongs to a policy that was not imported"
+)
+
+// assetTokens are the asset resources the pulumi-cdk synthesizer only maps to classic resources.
+// There is nothing to import them from unless the bootstrap stack's asset storage was loaded.
+var assetTokens = map[tokens.Type]bool{
+	"aws:s3/bucketObjectv2:BucketObjectv2":                                                       true,
+	"aws:s3/bucketV2:BucketV2":                                                                   true,
+	"aws:s3/bucketLifecycleConfigurationV2:BucketLifecycleConfigurationV2":                       true,
+	"aws:s3/bucketServerSideEncryptionConfigurationV2:BucketServerSideEncryptionConfigurationV2": true,
+	"aws:s3/bucketPolicy:BucketPolicy":                                                           true,
+	"aws:s3/bucketVersioningV2:BucketVersioningV2":                                               true,
+	"aws:ecr/repository:Repository":                                                              true,
+	"aws:ecr/lifecyclePolicy:LifecyclePolicy":                                                    true,
+}
+
+// CreateInstead reports whether a resource of type token is created (or skipped with skip-create)
+// rather than imported, and why. resolveErr is what resolving its import ID returned, or nil if it
+// wasn't resolved yet. Policy attachments follow their policy, see [PolicyImported].
+func CreateInstead(token tokens.Type, bootstrap *BootstrapAssets, resolveErr error) (string, bool) {
+	if assetTokens[token] {
+		if _, ok := bootstrap.ForToken(token); !ok {
+			return createReasonUnsupported, true
+		}
+	}
+	if errors.Is(resolveErr, ErrInlinePolicy) {
+		return createReasonInlinePolicy, true
+	}
+	return "", false
+}
+
+// PolicyImported reports whether the managed policy for the AWS::IAM::Policy key is imported rather
+// than created in place of its inline policies. Its attachments are only imported if it is.
+func PolicyImported(ctx context.Context, r Resolver, overrides *IDOverrides, key StackResourceKey) bool {
+	if _, ok := overrides.ForResource(key); ok {
+		return true
+	}
+	_, _, err := r.ResolvePrimaryResourceID(ctx, iamPolicyToken, key, nil)
+	return !errors.Is(err, ErrInlinePolicy)
+}
@@ -0,0 +1,40 @@
+package lookups
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+)
+
+func TestCreateInstead(t *testing.T) {
+	bootstrap := &BootstrapAssets{Bucket: BootstrapAsset{ID: "cdk-assets"}}
+
+	_, ok := CreateInstead("aws:s3/bucketV2:BucketV2", nil, nil)
+	assert.True(t, ok, "asset buckets are created without a bootstrap stack")
+	_, ok = CreateInstead("aws:s3/bucketV2:BucketV2", bootstrap, nil)
+	assert.False(t, ok, "asset buckets are imported against the bootstrap stack")
+	_, ok = CreateInstead("aws:ecr/repository:Repository", bootstrap, nil)
+	assert.True(t, ok, "the bootstrap stack has no repository")
+	_, ok = CreateInstead("aws:s3/bucketObjectv2:BucketObjectv2", bootstrap, nil)
+	assert.True(t, ok, "asset objects are never imported")
+
+	_, ok = CreateInstead("aws:iam/policy:Policy", nil, fmt.Errorf("%w (Policy)", ErrInlinePolicy))
+	assert.True(t, ok)
+	_, ok = CreateInstead("aws:iam/policy:Policy", nil, fmt.Errorf("No matching AWS::IAM::Policy"))
+	assert.False(t, ok, "other resolution errors are failures")
+}
+
+func TestPolicyImported(t *testing.T) {
+	key := StackResourceKey{StackName: "Stack", LogicalID: "Policy"}
+	r := NewAwsLookups(map[StackResourceKey]CfnStackResource{
+		key: {ResourceType: "AWS::IAM::Policy", LogicalID: "Policy", PhysicalID: "Stack-Polic-1ABC", StackName: "Stack"},
+	}, "us-west-2", "123456789012")
+
+	assert.False(t, PolicyImported(context.Background(), r, nil, key))
+	overrides := &IDOverrides{byKey: map[StackResourceKey]common.PrimaryResourceID{key: "arn:aws:iam::123456789012:policy/Policy"}}
+	assert.True(t, PolicyImported(context.Background(), r, overrides, key), "an overridden policy is imported")
+}
@@ -0,0 +1,89 @@
+package lookups
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// RoleSessionName is the session name of the roles assumed with Credentials.RoleARN.
+const RoleSessionName = "pulumi-cdk-importer"
+
+// Credentials selects the credentials of every AWS call made by the importer. The zero value uses
+// the default credential chain.
+type Credentials struct {
+	// Profile is the shared config profile; a profile in a stack reference takes precedence
+	Profile string
+	// RoleARN is a role assumed with the base credentials
+	RoleARN string
+	// ExternalID is passed when assuming RoleARN
+	ExternalID string
+	// SessionDuration of the assumed role session; zero uses the STS default
+	SessionDuration time.Duration
+}
+
+// Validate reports options that only make sense together with a role.
+func (c Credentials) Validate() error {
+	if c.RoleARN == "" && c.ExternalID != "" {
+		return fmt.Errorf("--external-id requires --role-arn")
+	}
+	if c.RoleARN == "" && c.SessionDuration != 0 {
+		return fmt.Errorf("--session-duration requires --role-arn")
+	}
+	if c.SessionDuration < 0 {
+		return fmt.Errorf("--session-duration must be positive")
+	}
+	return nil
+}
+
+// loadConfig loads the AWS configuration of env with creds applied.
+func loadConfig(ctx context.Context, env Environment, creds Credentials) (aws.Config, error) {
+	var opts []func(*config.LoadOptions) error
+	if env.Region != "" {
+		opts = append(opts, config.WithRegion(env.Region))
+	}
+	profile := creds.Profile
+	if env.Profile != "" {
+		profile = env.Profile
+	}
+	if profile != "" {
+		opts = append(opts, config.WithSharedConfigProfile(profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return cfg, err
+	}
+	if creds.RoleARN != "" {
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), creds.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = RoleSessionName
+			o.Duration = creds.SessionDuration
+			if creds.ExternalID != "" {
+				o.ExternalID = aws.String(creds.ExternalID)
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	return cfg, nil
+}
+
+// CallerAccount returns the account the credentials of env resolve to.
+func CallerAccount(ctx context.Context, env Environment, creds Credentials) (string, error) {
+	cfg, err := loadConfig(ctx, env, creds)
+	if err != nil {
+		return "", err
+	}
+	return callerAccount(ctx, cfg)
+}
+
+func callerAccount(ctx context.Context, cfg aws.Config) (string, error) {
+	res, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(res.Account), nil
+}
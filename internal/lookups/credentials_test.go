@@ -0,0 +1,17 @@
+package lookups
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialsValidate(t *testing.T) {
+	assert.NoError(t, Credentials{}.Validate())
+	assert.NoError(t, Credentials{Profile: "prod"}.Validate())
+	assert.NoError(t, Credentials{RoleARN: "arn:aws:iam::123456789012:role/importer", ExternalID: "ext", SessionDuration: time.Hour}.Validate())
+	assert.ErrorContains(t, Credentials{ExternalID: "ext"}.Validate(), "--external-id requires --role-arn")
+	assert.ErrorContains(t, Credentials{SessionDuration: time.Hour}.Validate(), "--session-duration requires --role-arn")
+	assert.ErrorContains(t, Credentials{RoleARN: "arn:aws:iam::123456789012:role/importer", SessionDuration: -time.Minute}.Validate(), "must be positive")
+}
@@ -0,0 +1,190 @@
+package lookups
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+)
+
+// CustomResourceEmulatorToken is the aws-native type pulumi-cdk gives CDK custom resources.
+const CustomResourceEmulatorToken = "aws-native:cloudformation:CustomResourceEmulator"
+
+// CustomResource is what CloudFormation knows about a deployed custom resource. The response data
+// returned by its handler isn't stored by CloudFormation; see [Lookups.CustomResourceData].
+type CustomResource struct {
+	PhysicalID common.PhysicalResourceID
+	// The ID (ARN) of the stack that owns the resource
+	StackID string
+	// The CloudFormation resource status, i.e. CREATE_COMPLETE
+	Status string
+}
+
+// customResourceLookups matches custom resources, which CloudFormation lists under the type given to
+// the emulator (`Custom::*` or `AWS::CloudFormation::CustomResource`), and imports them by physical ID.
+type customResourceLookups struct {
+	resourceType      common.ResourceType
+	cfnStackResources map[StackResourceKey]CfnStackResource
+}
+
+// CustomResourceResolver returns a Resolver for custom resources of the given CloudFormation type.
+func (l *Lookups) CustomResourceResolver(resourceType common.ResourceType) Resolver {
+	return &customResourceLookups{resourceType: resourceType, cfnStackResources: l.CfnStackResources}
+}
+
+func (c *customResourceLookups) FindLogicalResourceID(urn resource.URN) (StackResourceKey, error) {
+	return findLogicalResourceIDOfType(urn, c.resourceType, c.cfnStackResources)
+}
+
+func (c *customResourceLookups) ResolvePrimaryResourceID(
+	_ context.Context,
+	_ tokens.Type,
+	key StackResourceKey,
+	_ map[string]any,
+) (common.PrimaryResourceID, ResolutionStrategy, error) {
+	r, ok := c.cfnStackResources[key]
+	if !ok || r.PhysicalID == "" {
+		return "", "", fmt.Errorf("No physical ID for custom resource %s", key)
+	}
+	return common.PrimaryResourceID(r.PhysicalID), ResolutionPhysicalID, nil
+}
+
+// DescribeCustomResource reads the current CloudFormation state of a custom resource. Resources
+// that failed to create or are being deleted are reported as errors.
+func (l *Lookups) DescribeCustomResource(ctx context.Context, key StackResourceKey) (CustomResource, error) {
+	stackName := string(key.StackName)
+	logicalID := string(key.LogicalID)
+	out, err := l.CfnClient.DescribeStackResource(ctx, &cloudformation.DescribeStackResourceInput{
+		StackName:         &stackName,
+		LogicalResourceId: &logicalID,
+	})
+	if err != nil {
+		return CustomResource{}, fmt.Errorf("Failed to describe custom resource %s: %w", key, err)
+	}
+	detail := out.StackResourceDetail
+	if detail == nil || detail.PhysicalResourceId == nil {
+		return CustomResource{}, fmt.Errorf("Custom resource %s has no physical ID", key)
+	}
+	r := CustomResource{
+		PhysicalID: common.PhysicalResourceID(*detail.PhysicalResourceId),
+		Status:     string(detail.ResourceStatus),
+	}
+	if detail.StackId != nil {
+		r.StackID = *detail.StackId
+	}
+	switch detail.ResourceStatus {
+	case cfntypes.ResourceStatusCreateFailed,
+		cfntypes.ResourceStatusDeleteInProgress,
+		cfntypes.ResourceStatusDeleteComplete,
+		cfntypes.ResourceStatusDeleteFailed:
+		return r, fmt.Errorf("Custom resource %s is in state %s", key, r.Status)
+	}
+	return r, nil
+}
+
+// CustomResourceData recovers the response data attributes of a custom resource that its stack
+// exposes as outputs, i.e. outputs whose value is `Fn::GetAtt` of the resource. CloudFormation
+// doesn't keep the response itself, so every other attribute the template reads with `Fn::GetAtt`
+// is returned, sorted, as missing.
+func (l *Lookups) CustomResourceData(ctx context.Context, key StackResourceKey) (map[string]any, []string, error) {
+	stackName := string(key.StackName)
+	out, err := l.CfnClient.GetTemplate(ctx, &cloudformation.GetTemplateInput{StackName: &stackName})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get template of stack %s: %w", key.StackName, err)
+	}
+	if out.TemplateBody == nil {
+		return nil, nil, fmt.Errorf("Stack %s has no template", key.StackName)
+	}
+	var template struct {
+		Resources map[string]any `json:"Resources"`
+		Outputs   map[string]struct {
+			Value any `json:"Value"`
+		} `json:"Outputs"`
+	}
+	if err := json.Unmarshal([]byte(*out.TemplateBody), &template); err != nil {
+		return nil, nil, fmt.Errorf("Template of stack %s is not JSON: %w", key.StackName, err)
+	}
+
+	logicalID := string(key.LogicalID)
+	referenced := map[string]bool{}
+	collectGetAtts(template.Resources, logicalID, referenced)
+	outputs := map[string]string{}
+	for outputKey, output := range template.Outputs {
+		collectGetAtts(output.Value, logicalID, referenced)
+		if attr, ok := getAttOf(output.Value, logicalID); ok {
+			outputs[outputKey] = attr
+		}
+	}
+
+	data := map[string]any{}
+	if len(outputs) > 0 {
+		stacks, err := l.CfnClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{StackName: &stackName})
+		if err != nil {
+			return nil, nil, fmt.Errorf("Failed to describe stack %s: %w", key.StackName, err)
+		}
+		for _, stack := range stacks.Stacks {
+			for _, output := range stack.Outputs {
+				if output.OutputKey == nil || output.OutputValue == nil {
+					continue
+				}
+				if attr, ok := outputs[*output.OutputKey]; ok {
+					data[attr] = *output.OutputValue
+				}
+			}
+		}
+	}
+	var missing []string
+	for attr := range referenced {
+		if _, ok := data[attr]; !ok {
+			missing = append(missing, attr)
+		}
+	}
+	sort.Strings(missing)
+	return data, missing, nil
+}
+
+// getAttOf returns the attribute of an `Fn::GetAtt` of logicalID, in either its list or its
+// `LogicalID.Attribute` form.
+func getAttOf(v any, logicalID string) (string, bool) {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) != 1 {
+		return "", false
+	}
+	switch args := m["Fn::GetAtt"].(type) {
+	case []any:
+		if len(args) == 2 && args[0] == logicalID {
+			attr, ok := args[1].(string)
+			return attr, ok
+		}
+	case string:
+		if attr, ok := strings.CutPrefix(args, logicalID+"."); ok {
+			return attr, true
+		}
+	}
+	return "", false
+}
+
+// collectGetAtts adds the attributes of every `Fn::GetAtt` of logicalID in v to attrs.
+func collectGetAtts(v any, logicalID string, attrs map[string]bool) {
+	if attr, ok := getAttOf(v, logicalID); ok {
+		attrs[attr] = true
+		return
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for _, child := range v {
+			collectGetAtts(child, logicalID, attrs)
+		}
+	case []any:
+		for _, child := range v {
+			collectGetAtts(child, logicalID, attrs)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package lookups
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+)
+
+// Environment is the AWS account and region a CloudFormation stack is deployed to, and the shared
+// config profile used to reach it. Empty fields fall back to the default AWS configuration.
+type Environment struct {
+	Profile string
+	Account string
+	Region  string
+}
+
+// String renders the environment as `profile@account/region`, leaving out the empty parts.
+func (e Environment) String() string {
+	s := e.Region
+	if e.Account != "" {
+		s = e.Account + "/" + s
+	}
+	if e.Profile != "" {
+		s = e.Profile + "@" + s
+	}
+	if s == "" {
+		return "default"
+	}
+	return s
+}
+
+// StackRef is a stack selected with --stack: a stack name, optionally qualified with the
+// environment it is deployed to.
+type StackRef struct {
+	Environment
+	StackName common.StackName
+}
+
+// String renders the reference the way ParseStackRef accepts it.
+func (r StackRef) String() string {
+	if r.Environment == (Environment{}) {
+		return string(r.StackName)
+	}
+	return r.Environment.String() + ":" + string(r.StackName)
+}
+
+var accountIDPattern = regexp.MustCompile(`^[0-9]{12}$`)
+
+// ParseStackRef parses a stack reference of the form `[profile@][account/]region:StackName`. A
+// plain stack name is deployed to the default environment.
+func ParseStackRef(ref string) (StackRef, error) {
+	idx := strings.LastIndex(ref, ":")
+	if idx < 0 {
+		return StackRef{StackName: common.StackName(ref)}, nil
+	}
+	env, name := ref[:idx], ref[idx+1:]
+	if name == "" {
+		return StackRef{}, fmt.Errorf("Stack reference %q has no stack name", ref)
+	}
+	var out StackRef
+	out.StackName = common.StackName(name)
+	if profile, rest, ok := strings.Cut(env, "@"); ok {
+		if profile == "" {
+			return StackRef{}, fmt.Errorf("Stack reference %q has an empty profile", ref)
+		}
+		out.Profile, env = profile, rest
+	}
+	if account, region, ok := strings.Cut(env, "/"); ok {
+		if !accountIDPattern.MatchString(account) {
+			return StackRef{}, fmt.Errorf("Stack reference %q has an invalid account ID %q", ref, account)
+		}
+		out.Account, env = account, region
+	}
+	if env == "" || strings.ContainsAny(env, "@/") {
+		return StackRef{}, fmt.Errorf("Stack reference %q must be written as [profile@][account/]region:StackName", ref)
+	}
+	out.Region = env
+	return out, nil
+}
+
+// NewEnvironmentLookups creates a Lookups with AWS clients for env using creds. The account of the
+// resolved credentials must match the account of env, if it has one.
+func NewEnvironmentLookups(ctx context.Context, env Environment, creds Credentials) (*Lookups, error) {
+	cfg, err := loadConfig(ctx, env, creds)
+	if err != nil {
+		return nil, err
+	}
+	account, err := callerAccount(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if env.Account != "" && env.Account != account {
+		return nil, fmt.Errorf("Credentials for %s belong to account %s, not %s", env, account, env.Account)
+	}
+	l := NewLookups(
+		cfg.Region,
+		account,
+		cloudformation.NewFromConfig(cfg),
+		cloudcontrol.NewFromConfig(cfg),
+		eventbridge.NewFromConfig(cfg),
+	)
+	l.ECRClient = ecr.NewFromConfig(cfg)
+	l.Profile = env.Profile
+	if l.Profile == "" {
+		l.Profile = creds.Profile
+	}
+	return l, nil
+}
+
+// Environments holds a Lookups per AWS environment of the selected stacks. The first one is the
+// default, used by providers that don't configure a region.
+type Environments struct {
+	all []*Lookups
+}
+
+// NewEnvironments groups the Lookups of every environment, the default one first.
+func NewEnvironments(defaultLookups *Lookups, others ...*Lookups) *Environments {
+	return &Environments{all: append([]*Lookups{defaultLookups}, others...)}
+}
+
+// Default returns the Lookups of the default environment.
+func (e *Environments) Default() *Lookups {
+	return e.all[0]
+}
+
+// All returns the Lookups of every environment, the default one first.
+func (e *Environments) All() []*Lookups {
+	return e.all
+}
+
+// Select returns the Lookups of the environment a provider configured with region and profile
+// talks to. The profile is only used to choose between environments that share a region.
+func (e *Environments) Select(region, profile string) (*Lookups, error) {
+	if region == "" {
+		return e.Default(), nil
+	}
+	var matches []*Lookups
+	for _, l := range e.all {
+		if l.Region == region {
+			matches = append(matches, l)
+		}
+	}
+	switch {
+	case len(matches) == 1:
+		return matches[0], nil
+	case len(matches) == 0:
+		return nil, fmt.Errorf("None of the selected stacks is in region %s; add it to --stack as region:StackName", region)
+	}
+	for _, l := range matches {
+		if profile != "" && l.Profile == profile {
+			return l, nil
+		}
+	}
+	accounts := make([]string, 0, len(matches))
+	for _, l := range matches {
+		accounts = append(accounts, l.Account)
+	}
+	return nil, fmt.Errorf("Selected stacks in region %s belong to several accounts (%s); set the provider's profile to the profile of the stack",
+		region, strings.Join(accounts, ", "))
+}
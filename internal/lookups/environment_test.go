@@ -0,0 +1,62 @@
+package lookups
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseStackRef(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want StackRef
+	}{
+		{ref: "App", want: StackRef{StackName: "App"}},
+		{ref: "us-east-1:Certs", want: StackRef{Environment: Environment{Region: "us-east-1"}, StackName: "Certs"}},
+		{ref: "123456789012/eu-west-1:App", want: StackRef{Environment: Environment{Account: "123456789012", Region: "eu-west-1"}, StackName: "App"}},
+		{ref: "prod@eu-west-1:App", want: StackRef{Environment: Environment{Profile: "prod", Region: "eu-west-1"}, StackName: "App"}},
+		{ref: "prod@123456789012/eu-west-1:App", want: StackRef{Environment: Environment{Profile: "prod", Account: "123456789012", Region: "eu-west-1"}, StackName: "App"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ParseStackRef(tt.ref)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.ref, got.String())
+		})
+	}
+
+	for _, ref := range []string{"us-east-1:", ":App", "@us-east-1:App", "prod@:App", "1234/us-east-1:App", "123456789012/:App", "a/b/c:App"} {
+		t.Run("invalid "+ref, func(t *testing.T) {
+			_, err := ParseStackRef(ref)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEnvironmentsSelect(t *testing.T) {
+	primary := NewLookups("us-east-1", "111111111111", nil, nil, nil)
+	app := NewLookups("eu-west-1", "111111111111", nil, nil, nil)
+	prod := NewLookups("eu-west-1", "222222222222", nil, nil, nil)
+	prod.Profile = "prod"
+	envs := NewEnvironments(primary, app, prod)
+
+	l, err := envs.Select("", "")
+	require.NoError(t, err)
+	assert.Same(t, primary, l)
+
+	l, err = envs.Select("us-east-1", "prod")
+	require.NoError(t, err)
+	assert.Same(t, primary, l)
+
+	l, err = envs.Select("eu-west-1", "prod")
+	require.NoError(t, err)
+	assert.Same(t, prod, l)
+
+	_, err = envs.Select("eu-west-1", "")
+	assert.ErrorContains(t, err, "several accounts (111111111111, 222222222222)")
+
+	_, err = envs.Select("ap-southeast-2", "")
+	assert.ErrorContains(t, err, "region ap-southeast-2")
+}
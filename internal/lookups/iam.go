@@ -0,0 +1,94 @@
+package lookups
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+)
+
+const iamPolicyToken tokens.Type = "aws:iam/policy:Policy"
+
+// iamPolicyAttachmentTokens are the attachments pulumi-cdk creates for each role, user and group of
+// an AWS::IAM::Policy, keyed by the input naming the principal.
+var iamPolicyAttachmentTokens = map[tokens.Type]string{
+	"aws:iam/rolePolicyAttachment:RolePolicyAttachment":   "role",
+	"aws:iam/userPolicyAttachment:UserPolicyAttachment":   "user",
+	"aws:iam/groupPolicyAttachment:GroupPolicyAttachment": "group",
+}
+
+// iamInlinePolicyTokens are the inline policies an AWS::IAM::Policy can be modeled as, one for each
+// of its roles, users and groups. They are imported as `<principal>:<policy name>`.
+var iamInlinePolicyTokens = map[tokens.Type]string{
+	"aws:iam/rolePolicy:RolePolicy":   "role",
+	"aws:iam/userPolicy:UserPolicy":   "user",
+	"aws:iam/groupPolicy:GroupPolicy": "group",
+}
+
+// ErrInlinePolicy is returned for a managed policy that stands in for an AWS::IAM::Policy.
+// CloudFormation always creates that policy inline on each of its roles, users and groups, since
+// `PolicyName` is required, so there is no managed policy to import.
+var ErrInlinePolicy = errors.New("AWS::IAM::Policy is deployed as inline policies; there is no managed policy to import")
+
+// IsPolicyAttachment reports whether token is one of the managed policy attachments pulumi-cdk
+// creates for an AWS::IAM::Policy.
+func IsPolicyAttachment(token tokens.Type) bool {
+	_, ok := iamPolicyAttachmentTokens[token]
+	return ok
+}
+
+// isPolicyPrincipalResource reports whether token is created once per principal of an
+// AWS::IAM::Policy, i.e. an attachment or an inline policy.
+func isPolicyPrincipalResource(token tokens.Type) bool {
+	_, inline := iamInlinePolicyTokens[token]
+	return inline || IsPolicyAttachment(token)
+}
+
+// findPolicyAttachmentLogicalID matches an attachment or inline policy to its AWS::IAM::Policy.
+// pulumi-cdk names them after the policy's logical ID followed by the principal, so the policy
+// whose logical ID is the longest prefix of the URN name wins.
+func findPolicyAttachmentLogicalID(
+	urn resource.URN,
+	cfnStackResources map[StackResourceKey]CfnStackResource,
+) (StackResourceKey, error) {
+	name, stackPath := splitURNName(urn)
+	name = strings.ToLower(name)
+	var matches []StackResourceKey
+	longest := 0
+	for key, r := range cfnStackResources {
+		if r.ResourceType != "AWS::IAM::Policy" {
+			continue
+		}
+		logicalID := strings.ToLower(string(r.LogicalID))
+		if !strings.HasPrefix(name, logicalID) || len(logicalID) < longest {
+			continue
+		}
+		if len(logicalID) > longest {
+			longest, matches = len(logicalID), nil
+		}
+		matches = append(matches, key)
+	}
+	if len(matches) > 1 {
+		best := 0
+		var hinted []StackResourceKey
+		for _, key := range matches {
+			switch score := stackPathScore(stackPath, cfnStackResources[key]); {
+			case score > best:
+				best, hinted = score, []StackResourceKey{key}
+			case score == best:
+				hinted = append(hinted, key)
+			}
+		}
+		matches = hinted
+	}
+	switch len(matches) {
+	case 0:
+		return StackResourceKey{}, fmt.Errorf("No matching AWS::IAM::Policy for URN %v", urn)
+	case 1:
+		return matches[0], nil
+	default:
+		return StackResourceKey{}, fmt.Errorf("Ambiguous AWS::IAM::Policy for URN %v", urn)
+	}
+}
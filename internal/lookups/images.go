@@ -0,0 +1,74 @@
+package lookups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/smithy-go"
+)
+
+// ErrImageNotFound is returned when a container image doesn't exist in ECR.
+var ErrImageNotFound = errors.New("container image not found")
+
+// ecrImagePattern matches `<account>.dkr.ecr.<region>.amazonaws.com[.cn]/<repository>:<tag>`.
+var ecrImagePattern = regexp.MustCompile(`^(\d{12})\.dkr\.ecr\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?/([^:@]+):([^:@]+)$`)
+
+// ImageTag is a tagged image in an ECR repository.
+type ImageTag struct {
+	Registry   string
+	Account    string
+	Region     string
+	Repository string
+	Tag        string
+}
+
+// ParseImageTag parses an ECR image name such as
+// `123456789012.dkr.ecr.us-west-2.amazonaws.com/repo:tag`.
+func ParseImageTag(name string) (ImageTag, bool) {
+	m := ecrImagePattern.FindStringSubmatch(name)
+	if m == nil {
+		return ImageTag{}, false
+	}
+	registry := name[:len(name)-len(m[3])-len(m[4])-2]
+	return ImageTag{Registry: registry, Account: m[1], Region: m[2], Repository: m[3], Tag: m[4]}, true
+}
+
+// String renders the image name.
+func (t ImageTag) String() string {
+	return fmt.Sprintf("%s/%s:%s", t.Registry, t.Repository, t.Tag)
+}
+
+// Ref is the reference to an image by digest, e.g. `<registry>/<repository>@sha256:...`.
+func (t ImageTag) Ref(digest string) string {
+	return fmt.Sprintf("%s/%s@%s", t.Registry, t.Repository, digest)
+}
+
+// FindImageDigest returns the digest of the image tagged tag in the repository, or ErrImageNotFound
+// if the repository or tag doesn't exist.
+func (l *Lookups) FindImageDigest(ctx context.Context, tag ImageTag) (string, error) {
+	if l.ECRClient == nil {
+		return "", fmt.Errorf("No ECR client to resolve image %s", tag)
+	}
+	out, err := l.ECRClient.DescribeImages(ctx, &ecr.DescribeImagesInput{
+		RepositoryName: &tag.Repository,
+		RegistryId:     &tag.Account,
+		ImageIds:       []ecrtypes.ImageIdentifier{{ImageTag: &tag.Tag}},
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "ImageNotFoundException" || apiErr.ErrorCode() == "RepositoryNotFoundException") {
+		return "", fmt.Errorf("%w: %s", ErrImageNotFound, tag)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to describe image %s: %w", tag, err)
+	}
+	for _, image := range out.ImageDetails {
+		if image.ImageDigest != nil && *image.ImageDigest != "" {
+			return *image.ImageDigest, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrImageNotFound, tag)
+}
@@ -0,0 +1,59 @@
+package lookups
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeECR holds image digests by `repository:tag`.
+type fakeECR struct {
+	digests map[string]string
+}
+
+func (c fakeECR) DescribeImages(_ context.Context, params *ecr.DescribeImagesInput, _ ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	digest, ok := c.digests[*params.RepositoryName+":"+*params.ImageIds[0].ImageTag]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "ImageNotFoundException", Message: "not found"}
+	}
+	return &ecr.DescribeImagesOutput{ImageDetails: []ecrtypes.ImageDetail{{ImageDigest: aws.String(digest)}}}, nil
+}
+
+func TestParseImageTag(t *testing.T) {
+	tag, ok := ParseImageTag("123456789012.dkr.ecr.us-west-2.amazonaws.com/cdk-hnb659fds-container-assets:abc123")
+	require.True(t, ok)
+	assert.Equal(t, ImageTag{
+		Registry:   "123456789012.dkr.ecr.us-west-2.amazonaws.com",
+		Account:    "123456789012",
+		Region:     "us-west-2",
+		Repository: "cdk-hnb659fds-container-assets",
+		Tag:        "abc123",
+	}, tag)
+	assert.Equal(t, "123456789012.dkr.ecr.us-west-2.amazonaws.com/cdk-hnb659fds-container-assets@sha256:f00", tag.Ref("sha256:f00"))
+
+	_, ok = ParseImageTag("docker.io/library/nginx:latest")
+	assert.False(t, ok)
+	_, ok = ParseImageTag("123456789012.dkr.ecr.us-west-2.amazonaws.com/repo@sha256:f00")
+	assert.False(t, ok, "digest references have no tag to look up")
+}
+
+func TestFindImageDigest(t *testing.T) {
+	l := NewLookups("us-west-2", "123456789012", nil, nil, nil)
+	l.ECRClient = fakeECR{digests: map[string]string{"repo:abc123": "sha256:f00"}}
+
+	tag, ok := ParseImageTag("123456789012.dkr.ecr.us-west-2.amazonaws.com/repo:abc123")
+	require.True(t, ok)
+	digest, err := l.FindImageDigest(context.Background(), tag)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256:f00", digest)
+
+	tag.Tag = "missing"
+	_, err = l.FindImageDigest(context.Background(), tag)
+	assert.ErrorIs(t, err, ErrImageNotFound)
+}
@@ -3,13 +3,14 @@ package lookups
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"sort"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cloudcontrol"
 	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
 	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
-	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/metadata"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
@@ -17,35 +18,112 @@ import (
 )
 
 type Lookups struct {
-	CCAPIClient       *cloudcontrol.Client
-	CfnClient         *cloudformation.Client
+	CCAPIClient       CloudControlAPI
+	CfnClient         CloudFormationAPI
 	Region            string
 	Account           string
-	CfnStackResources map[common.LogicalResourceID]CfnStackResource
-	EventsClient      *eventbridge.Client
+	CfnStackResources map[StackResourceKey]CfnStackResource
+	EventsClient      EventBridgeAPI
+	// ECRClient resolves the container images of CDK assets; nil when images aren't resolved
+	ECRClient ECRAPI
+	// CCAPICache is shared by every CCAPI lookup made with these clients
+	CCAPICache *ResourceCache
+	// IDOverrides are import IDs chosen by the user that bypass every lookup
+	IDOverrides *IDOverrides
+	// Checkpoint records resolved import IDs, and answers from a previous run when resuming
+	Checkpoint *Checkpoint
+	// Bootstrap holds the asset storage of the CDK bootstrap stack, if it was loaded
+	Bootstrap *BootstrapAssets
+	// Profile is the shared config profile the clients were created with, if any
+	Profile string
 }
 
-func NewDefaultLookups(ctx context.Context) (*Lookups, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
-	if err != nil {
-		return nil, err
-	}
-	cfnClient := cloudformation.NewFromConfig(cfg)
-	stsClient := sts.NewFromConfig(cfg)
-	res, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
-	if err != nil {
-		return nil, err
+// CloudControlAPI is the subset of the Cloud Control API used by the importer.
+type CloudControlAPI interface {
+	ListResources(ctx context.Context, params *cloudcontrol.ListResourcesInput, optFns ...func(*cloudcontrol.Options)) (*cloudcontrol.ListResourcesOutput, error)
+}
+
+// CloudFormationAPI is the subset of the CloudFormation API used by the importer.
+type CloudFormationAPI interface {
+	ListStackResources(ctx context.Context, params *cloudformation.ListStackResourcesInput, optFns ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error)
+	DescribeStackResource(ctx context.Context, params *cloudformation.DescribeStackResourceInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceOutput, error)
+	DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
+	GetTemplate(ctx context.Context, params *cloudformation.GetTemplateInput, optFns ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error)
+}
+
+// EventBridgeAPI is the subset of the EventBridge API used by the importer.
+type EventBridgeAPI interface {
+	DescribeRule(ctx context.Context, params *eventbridge.DescribeRuleInput, optFns ...func(*eventbridge.Options)) (*eventbridge.DescribeRuleOutput, error)
+}
+
+// ECRAPI is the subset of the ECR API used by the importer.
+type ECRAPI interface {
+	DescribeImages(ctx context.Context, params *ecr.DescribeImagesInput, optFns ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error)
+}
+
+// ResolutionStrategy names the approach that produced a primary resource ID.
+type ResolutionStrategy string
+
+const (
+	// ResolutionPhysicalID means the CloudFormation Physical ID was used as-is
+	ResolutionPhysicalID ResolutionStrategy = "PhysicalID"
+	// ResolutionProperty means the ID was read from the resource's own input properties
+	ResolutionProperty ResolutionStrategy = "Property"
+	// ResolutionLookup means the ID was found by listing resources via CCAPI
+	ResolutionLookup ResolutionStrategy = "Lookup"
+	// ResolutionCustom means a resource-specific resolver produced the ID
+	ResolutionCustom ResolutionStrategy = "Custom"
+	// ResolutionARN means the ARN heuristic was used (accepting, constructing or looking up an ARN)
+	ResolutionARN ResolutionStrategy = "ARN"
+	// ResolutionComposite means the ID was assembled from multiple identifier parts
+	ResolutionComposite ResolutionStrategy = "Composite"
+	// ResolutionTemplate means a user supplied ID template from the resolver config rendered the ID
+	ResolutionTemplate ResolutionStrategy = "Template"
+	// ResolutionOverride means the ID came from the user supplied --id-overrides file
+	ResolutionOverride ResolutionStrategy = "Override"
+	// ResolutionBootstrap means the ID is an asset bucket or repository of the CDK bootstrap stack
+	ResolutionBootstrap ResolutionStrategy = "Bootstrap"
+	// ResolutionCreate means the resource can't be imported and is created instead, or skipped with
+	// skip-create
+	ResolutionCreate ResolutionStrategy = "Create"
+)
+
+// StackResourceKey identifies a CloudFormation resource by the stack that owns it and its logical ID.
+// Logical IDs are only unique within a single stack, so lookups spanning several stacks must use both.
+type StackResourceKey struct {
+	StackName common.StackName
+	LogicalID common.LogicalResourceID
+}
+
+// String renders the key as `StackName/LogicalID`, or just the logical ID when the stack is unknown.
+func (k StackResourceKey) String() string {
+	if k.StackName == "" {
+		return string(k.LogicalID)
 	}
+	return fmt.Sprintf("%s/%s", k.StackName, k.LogicalID)
+}
+
+// NewDefaultLookups creates a Lookups for the default AWS environment.
+func NewDefaultLookups(ctx context.Context) (*Lookups, error) {
+	return NewEnvironmentLookups(ctx, Environment{}, Credentials{})
+}
+
+// NewLookups creates a Lookups backed by the given API clients, e.g. recorded or replayed ones.
+func NewLookups(region, account string, cfn CloudFormationAPI, ccapi CloudControlAPI, events EventBridgeAPI) *Lookups {
 	return &Lookups{
-		CCAPIClient:       cloudcontrol.NewFromConfig(cfg),
-		Region:            cfg.Region,
-		Account:           *res.Account,
-		CfnClient:         cfnClient,
-		CfnStackResources: make(map[common.LogicalResourceID]CfnStackResource),
-		EventsClient:      eventbridge.NewFromConfig(cfg),
-	}, nil
+		CCAPIClient:       ccapi,
+		Region:            region,
+		Account:           account,
+		CfnClient:         cfn,
+		CfnStackResources: make(map[StackResourceKey]CfnStackResource),
+		EventsClient:      events,
+		CCAPICache:        NewResourceCache(),
+	}
 }
 
+// nestedStackResourceType is the type of the resource that creates a nested stack in its parent stack.
+const nestedStackResourceType common.ResourceType = "AWS::CloudFormation::Stack"
+
 // cfnStackResource represents a CloudFormation resource
 type CfnStackResource struct {
 	// The type of the resource, i.e. AWS::S3::Bucket
@@ -59,6 +137,16 @@ type CfnStackResource struct {
 	// See https://docs.aws.amazon.com/AWSCloudFormation/latest/UserGuide/resources-section-structure.html#resources-section-logical-id
 	LogicalID common.LogicalResourceID
 
+	// The CloudFormation stack that owns the resource
+	StackName common.StackName
+
+	// The path to the owning stack from the top-level stack: the top-level stack name followed by the
+	// logical ID of each nested stack resource on the way, joined with `/`, i.e. `App/NetworkNestedStackNestedStackResource1A2B3C4D`
+	StackPath string
+
+	// The CDK construct path (`aws:cdk:path` metadata) of the resource, if a cloud assembly was loaded
+	ConstructPath string
+
 	// The Input properties for this resource
 	Props map[string]any
 }
@@ -126,6 +214,49 @@ func renderResourceModel(
 	return model, nil
 }
 
+// expandIDTemplate renders a resolver config ID template for the resource identified by key.
+func expandIDTemplate(
+	template string,
+	key StackResourceKey,
+	r CfnStackResource,
+	region, account string,
+	props map[string]any,
+) (common.PrimaryResourceID, error) {
+	vars := map[string]string{
+		"account":    account,
+		"region":     region,
+		"partition":  partitionForRegion(region),
+		"physicalId": string(r.PhysicalID),
+		"logicalId":  string(key.LogicalID),
+		"stackName":  string(key.StackName),
+	}
+	id, err := metadata.ExpandIDTemplate(template, func(name string) (string, bool) {
+		if val, ok := vars[name]; ok && val != "" {
+			return val, true
+		}
+		if val, ok := props[name].(string); ok && val != "" {
+			return val, true
+		}
+		return "", false
+	})
+	if err != nil {
+		return "", fmt.Errorf("Could not render id for %s: %w", key, err)
+	}
+	return common.PrimaryResourceID(id), nil
+}
+
+// partitionForRegion returns the AWS partition a region belongs to.
+func partitionForRegion(region string) string {
+	switch {
+	case strings.HasPrefix(region, "cn-"):
+		return "aws-cn"
+	case strings.HasPrefix(region, "us-gov-"):
+		return "aws-us-gov"
+	default:
+		return "aws"
+	}
+}
+
 // getPrimaryIdentifiers gets the primary identifier from the CFN metadata
 func getPrimaryIdentifiers(metadata metadata.MetadataSource, resourceToken tokens.Type) (common.ResourceType, []resource.PropertyKey, error) {
 	resourceType, ok := metadata.ResourceType(resourceToken)
@@ -140,42 +271,189 @@ func getPrimaryIdentifiers(metadata metadata.MetadataSource, resourceToken token
 }
 
 // Correlate and do a best guess to find a CFN Logical ID based on a Pulumi URN.
+//
+// When resources carry construct paths from a CDK cloud assembly, an exact construct path match
+// wins; the name heuristic below is only used when there is none.
+//
+// The URN name may be a `/`-separated construct path (e.g. `MyStack/Api/Stage`); the last segment is
+// matched against logical IDs and the leading segments are used to pick the owning stack when
+// candidates exist in more than one stack. pulumi-cdk flattens nested stacks into their parent, so a
+// stack whose path matches more of those segments (e.g. `MyStack/Network/Vpc` for a resource in the
+// `Network` nested stack) wins over its parent. If that doesn't settle it the match is reported as ambiguous.
 func findLogicalResourceID(
 	urn resource.URN,
 	metadata metadata.MetadataSource,
-	cfnStackResources map[common.LogicalResourceID]CfnStackResource,
-) (common.LogicalResourceID, error) {
+	cfnStackResources map[StackResourceKey]CfnStackResource,
+) (StackResourceKey, error) {
 	resourceToken := urn.Type()
 	resourceType, ok := metadata.ResourceType(resourceToken)
 	if !ok {
-		return "", fmt.Errorf("Unknown resource type: %v", resourceToken)
+		return StackResourceKey{}, fmt.Errorf("Unknown resource type: %v", resourceToken)
+	}
+	return findLogicalResourceIDOfType(urn, resourceType, cfnStackResources)
+}
+
+// findLogicalResourceIDOfType is findLogicalResourceID for a URN whose CloudFormation type is
+// already known, i.e. custom resources whose type is one of their inputs.
+func findLogicalResourceIDOfType(
+	urn resource.URN,
+	resourceType common.ResourceType,
+	cfnStackResources map[StackResourceKey]CfnStackResource,
+) (StackResourceKey, error) {
+	name, stackPath := splitURNName(urn)
+	var exact []StackResourceKey
+	for key, r := range cfnStackResources {
+		if r.ResourceType == resourceType && r.ConstructPath != "" && constructPathMatches(r.ConstructPath, urn) {
+			exact = append(exact, key)
+		}
+	}
+	if len(exact) > 1 {
+		var hinted []StackResourceKey
+		best := 1
+		for _, key := range exact {
+			switch score := stackPathScore(stackPath, cfnStackResources[key]); {
+			case score > best:
+				best, hinted = score, []StackResourceKey{key}
+			case score == best:
+				hinted = append(hinted, key)
+			}
+		}
+		if len(hinted) != 1 {
+			// The construct path is the same in several stacks, so the name heuristic can't do better.
+			if len(hinted) > 1 {
+				exact = hinted
+			}
+			candidates := map[common.StackName][]StackResourceKey{}
+			for _, key := range exact {
+				candidates[key.StackName] = append(candidates[key.StackName], key)
+			}
+			return StackResourceKey{}, fmt.Errorf("Ambiguous CF resources for URN %v: construct path matches in multiple stacks: %s",
+				urn, strings.Join(describeMatches(candidates), ", "))
+		}
+		exact = hinted
+	}
+	if len(exact) == 1 {
+		return exact[0], nil
 	}
 
-	matchCount := 0
-	var match CfnStackResource
-	for _, r := range cfnStackResources {
+	matches := map[common.StackName][]StackResourceKey{}
+	for key, r := range cfnStackResources {
 		if r.ResourceType != resourceType {
 			continue
 		}
-		if strings.Contains(strings.ToLower(string(r.LogicalID)), strings.ToLower(urn.Name())) {
-			match = r
-			matchCount++
+		if strings.Contains(strings.ToLower(string(r.LogicalID)), strings.ToLower(name)) {
+			matches[key.StackName] = append(matches[key.StackName], key)
+		}
+	}
+	if len(matches) == 0 {
+		return StackResourceKey{}, fmt.Errorf("No matching CF resources for URN %v", urn)
+	}
+	if len(matches) > 1 {
+		hinted := map[common.StackName][]StackResourceKey{}
+		best := 1
+		for stackName, keys := range matches {
+			switch score := stackPathScore(stackPath, cfnStackResources[keys[0]]); {
+			case score > best:
+				best, hinted = score, map[common.StackName][]StackResourceKey{stackName: keys}
+			case score == best:
+				hinted[stackName] = keys
+			}
+		}
+		if len(hinted) != 1 {
+			return StackResourceKey{}, fmt.Errorf("Ambiguous CF resources for URN %v: candidates in multiple stacks: %s",
+				urn, strings.Join(describeMatches(matches), ", "))
+		}
+		matches = hinted
+	}
+	for _, keys := range matches {
+		if len(keys) > 1 {
+			return StackResourceKey{}, fmt.Errorf("Conflicting matching CF resources for URN %v", urn)
+		}
+		return keys[0], nil
+	}
+	return StackResourceKey{}, fmt.Errorf("No matching CF resources for URN %v", urn)
+}
+
+// splitURNName splits a URN name into the resource name (its last `/` segment) and the
+// leading construct path segments, if any.
+func splitURNName(urn resource.URN) (string, []string) {
+	segments := strings.Split(urn.Name(), "/")
+	return segments[len(segments)-1], segments[:len(segments)-1]
+}
+
+// stackPathScore counts how many stacks on the path to the stack that owns r are referenced by the
+// leading URN name segments. Nested stacks are referenced by their construct ID, which CDK turns into
+// the logical ID of the nested stack resource.
+func stackPathScore(urnPath []string, r CfnStackResource) int {
+	stacks := []string{string(r.StackName)}
+	if r.StackPath != "" {
+		stacks = strings.Split(r.StackPath, "/")
+		for i := 1; i < len(stacks); i++ {
+			stacks[i] = nestedStackConstructID(stacks[i])
+		}
+	}
+	score := 0
+	for _, stack := range stacks {
+		if stack == "" {
+			continue
+		}
+		for _, segment := range urnPath {
+			if strings.EqualFold(segment, stack) {
+				score++
+				break
+			}
 		}
 	}
-	if matchCount == 0 {
-		return "", fmt.Errorf("No matching CF resources for URN %v", urn)
+	return score
+}
+
+// nestedStackLogicalIDPattern matches the logical ID CDK gives the resource of a `NestedStack`
+// with construct ID `Network`, i.e. `NetworkNestedStackNestedStackResource1A2B3C4D`.
+var nestedStackLogicalIDPattern = regexp.MustCompile(`^(.+)NestedStackNestedStackResource[0-9A-F]{8}$`)
+
+// nestedStackConstructID recovers the construct ID of a CDK nested stack from the logical ID of its
+// stack resource, or returns the logical ID unchanged if it wasn't generated by CDK.
+func nestedStackConstructID(logicalID string) string {
+	if m := nestedStackLogicalIDPattern.FindStringSubmatch(logicalID); m != nil {
+		return m[1]
 	}
-	if matchCount > 1 {
-		return "", fmt.Errorf("Conflicting matching CF resources for URN %v", urn)
+	return logicalID
+}
+
+func describeMatches(matches map[common.StackName][]StackResourceKey) []string {
+	out := []string{}
+	for _, keys := range matches {
+		for _, key := range keys {
+			out = append(out, key.String())
+		}
 	}
-	return match.LogicalID, nil
+	sort.Strings(out)
+	return out
 }
 
-// GetStackResources Gets all the resources from a CloudFormation stack
+// GetStackResources Gets all the resources from a CloudFormation stack, including the resources of
+// any nested stacks it contains
 func (l *Lookups) GetStackResources(ctx context.Context, stackName common.StackName) error {
-	sn := string(stackName)
+	return l.getStackResources(ctx, string(stackName), stackName, string(stackName), map[string]bool{})
+}
+
+// getStackResources lists the stack identified by ref (a stack name or, for nested stacks, the stack
+// ARN) and descends into every nested stack it finds.
+func (l *Lookups) getStackResources(
+	ctx context.Context,
+	ref string,
+	stackName common.StackName,
+	stackPath string,
+	visited map[string]bool,
+) error {
+	if visited[ref] {
+		return nil
+	}
+	visited[ref] = true
+
+	var nested []CfnStackResource
 	paginator := cloudformation.NewListStackResourcesPaginator(l.CfnClient, &cloudformation.ListStackResourcesInput{
-		StackName: &sn,
+		StackName: &ref,
 	})
 	for paginator.HasMorePages() {
 		output, err := paginator.NextPage(ctx)
@@ -190,9 +468,34 @@ func (l *Lookups) GetStackResources(ctx context.Context, stackName common.StackN
 				ResourceType: common.ResourceType(*s.ResourceType),
 				LogicalID:    common.LogicalResourceID(*s.LogicalResourceId),
 				PhysicalID:   common.PhysicalResourceID(*s.PhysicalResourceId),
+				StackName:    stackName,
+				StackPath:    stackPath,
+			}
+			l.CfnStackResources[StackResourceKey{StackName: stackName, LogicalID: r.LogicalID}] = r
+			if r.ResourceType == nestedStackResourceType {
+				nested = append(nested, r)
 			}
-			l.CfnStackResources[r.LogicalID] = r
+		}
+	}
+
+	for _, r := range nested {
+		arn := string(r.PhysicalID)
+		err := l.getStackResources(ctx, arn, stackNameFromARN(arn), stackPath+"/"+string(r.LogicalID), visited)
+		if err != nil {
+			return fmt.Errorf("Failed to get resources of nested stack %s/%s: %w", stackName, r.LogicalID, err)
 		}
 	}
 	return nil
 }
+
+// stackNameFromARN returns the stack name of a stack ARN, i.e.
+// `arn:aws:cloudformation:us-east-1:123456789012:stack/App-Network-1A2B3C/guid` => `App-Network-1A2B3C`.
+// Anything that isn't a stack ARN is returned as is.
+func stackNameFromARN(arn string) common.StackName {
+	_, rest, ok := strings.Cut(arn, ":stack/")
+	if !ok {
+		return common.StackName(arn)
+	}
+	name, _, _ := strings.Cut(rest, "/")
+	return common.StackName(name)
+}
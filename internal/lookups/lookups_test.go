@@ -165,8 +165,8 @@ func Test_getPrimaryIdentifiers(t *testing.T) {
 func Test_findLogicalResourceID(t *testing.T) {
 	t.Run("simple", func(t *testing.T) {
 		urn := resource.URN("urn:pulumi:stack::project::aws:apigatewayv2/stage:Stage::stage")
-		cfnStackResources := map[common.LogicalResourceID]CfnStackResource{
-			"stage": {
+		cfnStackResources := map[StackResourceKey]CfnStackResource{
+			{LogicalID: "Stage"}: {
 				LogicalID:    "Stage",
 				ResourceType: "AWS::ApiGatewayV2::Stage",
 			},
@@ -178,17 +178,17 @@ func Test_findLogicalResourceID(t *testing.T) {
 				},
 			}}, cfnStackResources)
 		assert.NoError(t, err)
-		assert.Equal(t, common.LogicalResourceID("Stage"), actual)
+		assert.Equal(t, StackResourceKey{LogicalID: "Stage"}, actual)
 	})
 
 	t.Run("too many matches", func(t *testing.T) {
 		urn := resource.URN("urn:pulumi:stack::project::aws:apigatewayv2/stage:Stage::stage")
-		cfnStackResources := map[common.LogicalResourceID]CfnStackResource{
-			"stage": {
+		cfnStackResources := map[StackResourceKey]CfnStackResource{
+			{LogicalID: "Stage"}: {
 				LogicalID:    "Stage",
 				ResourceType: "AWS::ApiGatewayV2::Stage",
 			},
-			"otherResource": {
+			{LogicalID: "OtherStage"}: {
 				LogicalID:    "OtherStage",
 				ResourceType: "AWS::ApiGatewayV2::Stage",
 			},
@@ -204,12 +204,12 @@ func Test_findLogicalResourceID(t *testing.T) {
 
 	t.Run("no matches", func(t *testing.T) {
 		urn := resource.URN("urn:pulumi:stack::project::aws:apigatewayv2/stage:Stage::stage")
-		cfnStackResources := map[common.LogicalResourceID]CfnStackResource{
-			"stage": {
+		cfnStackResources := map[StackResourceKey]CfnStackResource{
+			{LogicalID: "Other"}: {
 				LogicalID:    "Other",
 				ResourceType: "AWS::ApiGatewayV2::Stage",
 			},
-			"otherResource": {
+			{LogicalID: "Resource"}: {
 				LogicalID:    "Resource",
 				ResourceType: "AWS::ApiGatewayV2::Stage",
 			},
@@ -222,4 +222,163 @@ func Test_findLogicalResourceID(t *testing.T) {
 			}}, cfnStackResources)
 		assert.ErrorContains(t, err, "No matching CF resources")
 	})
+
+	t.Run("same logical id in multiple stacks is ambiguous", func(t *testing.T) {
+		urn := resource.URN("urn:pulumi:stack::project::aws:apigatewayv2/stage:Stage::stage")
+		cfnStackResources := map[StackResourceKey]CfnStackResource{
+			{StackName: "api-east", LogicalID: "Stage"}: {
+				LogicalID:    "Stage",
+				StackName:    "api-east",
+				ResourceType: "AWS::ApiGatewayV2::Stage",
+			},
+			{StackName: "api-west", LogicalID: "Stage"}: {
+				LogicalID:    "Stage",
+				StackName:    "api-west",
+				ResourceType: "AWS::ApiGatewayV2::Stage",
+			},
+		}
+		_, err := findLogicalResourceID(urn, &mockMetadataSource{
+			resources: map[string]providerMetadata.CloudAPIResource{
+				"aws:apigatewayv2/stage:Stage": {
+					CfType: "AWS::ApiGatewayV2::Stage",
+				},
+			}}, cfnStackResources)
+		assert.ErrorContains(t, err, "Ambiguous CF resources")
+		assert.ErrorContains(t, err, "api-east/Stage, api-west/Stage")
+	})
+
+	t.Run("construct path match wins over name heuristic", func(t *testing.T) {
+		urn := resource.URN("urn:pulumi:stack::project::aws:apigatewayv2/stage:Stage::Api/Stage")
+		cfnStackResources := map[StackResourceKey]CfnStackResource{
+			{LogicalID: "ApiStage1234"}: {
+				LogicalID:     "ApiStage1234",
+				ResourceType:  "AWS::ApiGatewayV2::Stage",
+				ConstructPath: "Stack/Api/Stage/Resource",
+			},
+			{LogicalID: "OtherApiStage5678"}: {
+				LogicalID:     "OtherApiStage5678",
+				ResourceType:  "AWS::ApiGatewayV2::Stage",
+				ConstructPath: "Stack/OtherApi/Stage/Resource",
+			},
+		}
+		actual, err := findLogicalResourceID(urn, &mockMetadataSource{
+			resources: map[string]providerMetadata.CloudAPIResource{
+				"aws:apigatewayv2/stage:Stage": {
+					CfType: "AWS::ApiGatewayV2::Stage",
+				},
+			}}, cfnStackResources)
+		assert.NoError(t, err)
+		assert.Equal(t, StackResourceKey{LogicalID: "ApiStage1234"}, actual)
+	})
+
+	t.Run("same construct path in multiple stacks is ambiguous", func(t *testing.T) {
+		urn := resource.URN("urn:pulumi:stack::project::aws:apigatewayv2/stage:Stage::Api/Stage")
+		cfnStackResources := map[StackResourceKey]CfnStackResource{
+			{StackName: "api-east", LogicalID: "ApiStage1234"}: {
+				LogicalID:     "ApiStage1234",
+				StackName:     "api-east",
+				ResourceType:  "AWS::ApiGatewayV2::Stage",
+				ConstructPath: "api-east/Api/Stage/Resource",
+			},
+			{StackName: "api-west", LogicalID: "ApiStage5678"}: {
+				LogicalID:     "ApiStage5678",
+				StackName:     "api-west",
+				ResourceType:  "AWS::ApiGatewayV2::Stage",
+				ConstructPath: "api-west/Api/Stage/Resource",
+			},
+			{StackName: "api-west", LogicalID: "Stage"}: {
+				LogicalID:    "Stage",
+				StackName:    "api-west",
+				ResourceType: "AWS::ApiGatewayV2::Stage",
+			},
+		}
+		_, err := findLogicalResourceID(urn, &mockMetadataSource{
+			resources: map[string]providerMetadata.CloudAPIResource{
+				"aws:apigatewayv2/stage:Stage": {
+					CfType: "AWS::ApiGatewayV2::Stage",
+				},
+			}}, cfnStackResources)
+		assert.ErrorContains(t, err, "Ambiguous CF resources")
+		assert.ErrorContains(t, err, "api-east/ApiStage1234, api-west/ApiStage5678")
+	})
+
+	t.Run("stack path in urn name selects stack", func(t *testing.T) {
+		urn := resource.URN("urn:pulumi:stack::project::aws:apigatewayv2/stage:Stage::api-west/Stage")
+		cfnStackResources := map[StackResourceKey]CfnStackResource{
+			{StackName: "api-east", LogicalID: "ApiStage"}: {
+				LogicalID:    "ApiStage",
+				StackName:    "api-east",
+				ResourceType: "AWS::ApiGatewayV2::Stage",
+			},
+			{StackName: "api-west", LogicalID: "ApiStage"}: {
+				LogicalID:    "ApiStage",
+				StackName:    "api-west",
+				ResourceType: "AWS::ApiGatewayV2::Stage",
+			},
+		}
+		actual, err := findLogicalResourceID(urn, &mockMetadataSource{
+			resources: map[string]providerMetadata.CloudAPIResource{
+				"aws:apigatewayv2/stage:Stage": {
+					CfType: "AWS::ApiGatewayV2::Stage",
+				},
+			}}, cfnStackResources)
+		assert.NoError(t, err)
+		assert.Equal(t, StackResourceKey{StackName: "api-west", LogicalID: "ApiStage"}, actual)
+	})
+
+	t.Run("nested stack wins over its parent", func(t *testing.T) {
+		urn := resource.URN("urn:pulumi:stack::project::aws:apigatewayv2/stage:Stage::App/Api/Stage")
+		cfnStackResources := map[StackResourceKey]CfnStackResource{
+			{StackName: "App", LogicalID: "Stage"}: {
+				LogicalID:    "Stage",
+				StackName:    "App",
+				StackPath:    "App",
+				ResourceType: "AWS::ApiGatewayV2::Stage",
+			},
+			{StackName: "App-ApiNestedStack-1XYZ", LogicalID: "Stage"}: {
+				LogicalID:    "Stage",
+				StackName:    "App-ApiNestedStack-1XYZ",
+				StackPath:    "App/ApiNestedStackNestedStackResource1A2B3C4D",
+				ResourceType: "AWS::ApiGatewayV2::Stage",
+			},
+		}
+		actual, err := findLogicalResourceID(urn, &mockMetadataSource{
+			resources: map[string]providerMetadata.CloudAPIResource{
+				"aws:apigatewayv2/stage:Stage": {
+					CfType: "AWS::ApiGatewayV2::Stage",
+				},
+			}}, cfnStackResources)
+		assert.NoError(t, err)
+		assert.Equal(t, StackResourceKey{StackName: "App-ApiNestedStack-1XYZ", LogicalID: "Stage"}, actual)
+	})
+}
+
+func Test_nestedStackConstructID(t *testing.T) {
+	assert.Equal(t, "Network", nestedStackConstructID("NetworkNestedStackNestedStackResource1A2B3C4D"))
+	assert.Equal(t, "Network", nestedStackConstructID("Network"))
+	assert.Equal(t, common.StackName("App-Network-1A2B3C"),
+		stackNameFromARN("arn:aws:cloudformation:us-east-1:123456789012:stack/App-Network-1A2B3C/0a1b2c3d"))
+	assert.Equal(t, common.StackName("App"), stackNameFromARN("App"))
+}
+
+func Test_expandIDTemplate(t *testing.T) {
+	key := StackResourceKey{StackName: "Stack", LogicalID: "Role"}
+	r := CfnStackResource{ResourceType: "AWS::IAM::Role", PhysicalID: "Stack-Role-1ABC"}
+
+	t.Run("built in placeholders", func(t *testing.T) {
+		id, err := expandIDTemplate("arn:{partition}:iam::{account}:role/{physicalId}", key, r, "cn-north-1", "123456789012", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, common.PrimaryResourceID("arn:aws-cn:iam::123456789012:role/Stack-Role-1ABC"), id)
+	})
+
+	t.Run("resource properties", func(t *testing.T) {
+		id, err := expandIDTemplate("{stackName}/{Path}{physicalId}", key, r, "us-west-2", "123456789012", map[string]any{"Path": "/service/"})
+		assert.NoError(t, err)
+		assert.Equal(t, common.PrimaryResourceID("Stack//service/Stack-Role-1ABC"), id)
+	})
+
+	t.Run("missing placeholder", func(t *testing.T) {
+		_, err := expandIDTemplate("{RoleName}", key, r, "us-west-2", "123456789012", map[string]any{})
+		assert.ErrorContains(t, err, `no value for placeholder "RoleName"`)
+	})
 }
@@ -0,0 +1,169 @@
+package lookups
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	"gopkg.in/yaml.v3"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+)
+
+// IDOverrides maps resources to import IDs chosen by the user, bypassing every lookup. Keys are a
+// Pulumi URN, a stack-qualified logical ID (`Stack/LogicalID`) or a bare logical ID that applies in
+// every stack. A nil *IDOverrides has no overrides.
+type IDOverrides struct {
+	byURN       map[resource.URN]common.PrimaryResourceID
+	byKey       map[StackResourceKey]common.PrimaryResourceID
+	byLogicalID map[common.LogicalResourceID]common.PrimaryResourceID
+}
+
+// LoadIDOverrides reads an overrides file: a YAML or JSON object of key to import ID, e.g.
+//
+//	MyBucketF68F3FF0: my-bucket
+//	ApiStack/ServiceRole: arn:aws:iam::123456789012:role/service
+//	"urn:pulumi:dev::app::aws:sqs/queue:Queue::jobs": https://sqs.us-west-2.amazonaws.com/123456789012/jobs
+func LoadIDOverrides(path string) (*IDOverrides, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading id overrides: %w", err)
+	}
+	var raw map[string]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing id overrides %s: %w", path, err)
+	}
+	return NewIDOverrides(raw)
+}
+
+// NewIDOverrides builds overrides from key to import ID pairs.
+func NewIDOverrides(raw map[string]string) (*IDOverrides, error) {
+	o := &IDOverrides{
+		byURN:       map[resource.URN]common.PrimaryResourceID{},
+		byKey:       map[StackResourceKey]common.PrimaryResourceID{},
+		byLogicalID: map[common.LogicalResourceID]common.PrimaryResourceID{},
+	}
+	for key, id := range raw {
+		if id == "" {
+			return nil, fmt.Errorf("id override for %q is empty", key)
+		}
+		primaryID := common.PrimaryResourceID(id)
+		if strings.HasPrefix(key, "urn:") {
+			urn, err := resource.ParseURN(key)
+			if err != nil {
+				return nil, fmt.Errorf("id override key %q: %w", key, err)
+			}
+			o.byURN[urn] = primaryID
+			continue
+		}
+		stack, logicalID, qualified := strings.Cut(key, "/")
+		switch {
+		case key == "" || (qualified && (stack == "" || logicalID == "" || strings.Contains(logicalID, "/"))):
+			return nil, fmt.Errorf("id override key %q must be a URN, LogicalID or StackName/LogicalID", key)
+		case qualified:
+			o.byKey[StackResourceKey{StackName: common.StackName(stack), LogicalID: common.LogicalResourceID(logicalID)}] = primaryID
+		default:
+			o.byLogicalID[common.LogicalResourceID(key)] = primaryID
+		}
+	}
+	return o, nil
+}
+
+// Len returns the number of overrides.
+func (o *IDOverrides) Len() int {
+	if o == nil {
+		return 0
+	}
+	return len(o.byURN) + len(o.byKey) + len(o.byLogicalID)
+}
+
+// ForURN returns the override for urn.
+func (o *IDOverrides) ForURN(urn resource.URN) (common.PrimaryResourceID, bool) {
+	if o == nil {
+		return "", false
+	}
+	id, ok := o.byURN[urn]
+	return id, ok
+}
+
+// ForResource returns the override for a CloudFormation resource. A stack-qualified override wins
+// over a bare logical ID.
+func (o *IDOverrides) ForResource(key StackResourceKey) (common.PrimaryResourceID, bool) {
+	if o == nil {
+		return "", false
+	}
+	if id, ok := o.byKey[key]; ok {
+		return id, true
+	}
+	id, ok := o.byLogicalID[key.LogicalID]
+	return id, ok
+}
+
+// Unmatched returns the logical ID overrides that don't name any of resources, usually a typo or a
+// stack that wasn't loaded. URN overrides can't be checked before the program runs.
+func (o *IDOverrides) Unmatched(resources map[StackResourceKey]CfnStackResource) []string {
+	if o == nil {
+		return nil
+	}
+	logicalIDs := map[common.LogicalResourceID]bool{}
+	for key := range resources {
+		logicalIDs[key.LogicalID] = true
+	}
+	var unmatched []string
+	for key := range o.byKey {
+		if _, ok := resources[key]; !ok {
+			unmatched = append(unmatched, key.String())
+		}
+	}
+	for logicalID := range o.byLogicalID {
+		if !logicalIDs[logicalID] {
+			unmatched = append(unmatched, string(logicalID))
+		}
+	}
+	sort.Strings(unmatched)
+	return unmatched
+}
+
+// Resolver maps a Pulumi resource to its CloudFormation resource and import ID. It is implemented
+// by the aws and aws-native lookups.
+type Resolver interface {
+	FindLogicalResourceID(urn resource.URN) (StackResourceKey, error)
+	ResolvePrimaryResourceID(
+		ctx context.Context,
+		resourceToken tokens.Type,
+		key StackResourceKey,
+		props map[string]any,
+	) (common.PrimaryResourceID, ResolutionStrategy, error)
+}
+
+// ResolveImportID finds the CloudFormation resource for urn and its import ID. Overrides are
+// consulted before any lookup: a URN override applies even when no logical ID matches, in which
+// case the returned key is empty.
+func ResolveImportID(
+	ctx context.Context,
+	r Resolver,
+	overrides *IDOverrides,
+	urn resource.URN,
+	props map[string]any,
+) (StackResourceKey, common.PrimaryResourceID, ResolutionStrategy, error) {
+	if id, ok := overrides.ForURN(urn); ok {
+		key, _ := r.FindLogicalResourceID(urn)
+		return key, id, ResolutionOverride, nil
+	}
+	key, err := r.FindLogicalResourceID(urn)
+	if err != nil {
+		return StackResourceKey{}, "", "", err
+	}
+	if id, ok := overrides.ForResource(key); ok {
+		return key, id, ResolutionOverride, nil
+	}
+	id, strategy, err := r.ResolvePrimaryResourceID(ctx, urn.Type(), key, props)
+	if err != nil {
+		return key, "", "", err
+	}
+	return key, id, strategy, nil
+}
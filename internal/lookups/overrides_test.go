@@ -0,0 +1,121 @@
+package lookups
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+)
+
+const overrideURN = "urn:pulumi:dev::app::aws:sqs/queuePolicy:QueuePolicy::Jobs/Policy"
+
+func TestLoadIDOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "overrides.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+JobsPolicy: https://sqs/jobs
+ApiStack/JobsPolicy: https://sqs/api-jobs
+"`+overrideURN+`": https://sqs/by-urn
+`), 0o600))
+
+	o, err := LoadIDOverrides(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, o.Len())
+
+	id, ok := o.ForURN(overrideURN)
+	assert.True(t, ok)
+	assert.Equal(t, common.PrimaryResourceID("https://sqs/by-urn"), id)
+
+	id, ok = o.ForResource(StackResourceKey{StackName: "ApiStack", LogicalID: "JobsPolicy"})
+	assert.True(t, ok)
+	assert.Equal(t, common.PrimaryResourceID("https://sqs/api-jobs"), id, "stack-qualified key wins")
+
+	id, ok = o.ForResource(StackResourceKey{StackName: "WorkerStack", LogicalID: "JobsPolicy"})
+	assert.True(t, ok)
+	assert.Equal(t, common.PrimaryResourceID("https://sqs/jobs"), id)
+
+	_, ok = o.ForResource(StackResourceKey{StackName: "ApiStack", LogicalID: "Other"})
+	assert.False(t, ok)
+
+	unmatched := o.Unmatched(map[StackResourceKey]CfnStackResource{
+		{StackName: "WorkerStack", LogicalID: "JobsPolicy"}: {},
+	})
+	assert.Equal(t, []string{"ApiStack/JobsPolicy"}, unmatched)
+}
+
+func TestNewIDOverridesRejectsInvalidKeys(t *testing.T) {
+	for _, key := range []string{"", "/Logical", "Stack/", "A/B/C", "urn:pulumi:bad"} {
+		_, err := NewIDOverrides(map[string]string{key: "id"})
+		assert.Error(t, err, key)
+	}
+	_, err := NewIDOverrides(map[string]string{"Logical": ""})
+	assert.ErrorContains(t, err, "is empty")
+}
+
+func TestNilIDOverrides(t *testing.T) {
+	var o *IDOverrides
+	assert.Equal(t, 0, o.Len())
+	_, ok := o.ForURN(overrideURN)
+	assert.False(t, ok)
+	_, ok = o.ForResource(StackResourceKey{LogicalID: "JobsPolicy"})
+	assert.False(t, ok)
+}
+
+type stubResolver struct {
+	key      StackResourceKey
+	findErr  error
+	resolved int
+}
+
+func (s *stubResolver) FindLogicalResourceID(resource.URN) (StackResourceKey, error) {
+	return s.key, s.findErr
+}
+
+func (s *stubResolver) ResolvePrimaryResourceID(context.Context, tokens.Type, StackResourceKey, map[string]any) (common.PrimaryResourceID, ResolutionStrategy, error) {
+	s.resolved++
+	return "looked-up", ResolutionPhysicalID, nil
+}
+
+func TestResolveImportID(t *testing.T) {
+	ctx := context.Background()
+	key := StackResourceKey{StackName: "ApiStack", LogicalID: "JobsPolicy"}
+
+	t.Run("logical id override skips the lookup", func(t *testing.T) {
+		r := &stubResolver{key: key}
+		o, err := NewIDOverrides(map[string]string{"JobsPolicy": "overridden"})
+		require.NoError(t, err)
+		gotKey, id, strategy, err := ResolveImportID(ctx, r, o, overrideURN, nil)
+		require.NoError(t, err)
+		assert.Equal(t, key, gotKey)
+		assert.Equal(t, common.PrimaryResourceID("overridden"), id)
+		assert.Equal(t, ResolutionOverride, strategy)
+		assert.Zero(t, r.resolved)
+	})
+
+	t.Run("urn override applies without a logical id match", func(t *testing.T) {
+		r := &stubResolver{findErr: fmt.Errorf("No matching CF resources")}
+		o, err := NewIDOverrides(map[string]string{overrideURN: "by-urn"})
+		require.NoError(t, err)
+		gotKey, id, strategy, err := ResolveImportID(ctx, r, o, overrideURN, nil)
+		require.NoError(t, err)
+		assert.Equal(t, StackResourceKey{}, gotKey)
+		assert.Equal(t, common.PrimaryResourceID("by-urn"), id)
+		assert.Equal(t, ResolutionOverride, strategy)
+	})
+
+	t.Run("falls back to lookups", func(t *testing.T) {
+		r := &stubResolver{key: key}
+		_, id, strategy, err := ResolveImportID(ctx, r, nil, overrideURN, nil)
+		require.NoError(t, err)
+		assert.Equal(t, common.PrimaryResourceID("looked-up"), id)
+		assert.Equal(t, ResolutionPhysicalID, strategy)
+		assert.Equal(t, 1, r.resolved)
+	})
+}
@@ -1,9 +1,13 @@
 package metadata
 
 import (
+	"cmp"
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"os"
+	"slices"
 	"strings"
 
 	"github.com/pulumi/pulumi-aws-native/provider/pkg/metadata"
@@ -19,6 +23,12 @@ func NewAwsMetadataSource() *awsClassicMetadataSource {
 type awsClassicMetadataSource struct {
 	cloudApiMetadata metadata.CloudAPIMetadata
 	separator        map[string]string
+	idTemplates      map[common.ResourceType]string
+	// resourceTokens is the reverse of cloudApiMetadata, most preferred token first.
+	resourceTokens map[common.ResourceType][]tokens.Type
+	// splitTokens are the Pulumi resources a CloudFormation resource is split into, which are
+	// all imported rather than picking one of them.
+	splitTokens map[common.ResourceType][]tokens.Type
 }
 
 type primaryIdentifierSet struct {
@@ -31,6 +41,12 @@ type primaryIdentifierEntry struct {
 	Provider          string               `json:"provider"`
 	PrimaryIdentifier primaryIdentifierSet `json:"primaryIdentifier"`
 	PulumiTypes       []string             `json:"pulumiTypes"`
+	// Split marks PulumiTypes that together make up the CloudFormation resource, rather than
+	// alternatives to pick from.
+	Split bool `json:"split,omitempty"`
+	// OptionalPulumiTypes are the split resources that only exist when the CloudFormation resource
+	// configures them, e.g. a bucket's lifecycle rules. They aren't imported with the split resource.
+	OptionalPulumiTypes []string `json:"optionalPulumiTypes,omitempty"`
 }
 
 // Convert a Pulumi resource token into the matching CF ResourceType.
@@ -42,15 +58,43 @@ func (src *awsClassicMetadataSource) ResourceType(resourceToken tokens.Type) (co
 	return common.ResourceType(r.CfType), true
 }
 
-// Inverse of [ResourceType].
+// Inverse of [ResourceType]. When several tokens map to the CloudFormation type, the most specific
+// mapping wins, then the one listed first in the schema.
 func (src *awsClassicMetadataSource) ResourceToken(resourceType common.ResourceType) (tokens.Type, bool) {
-	// TODO: pre-compute the reverse map.
-	for tok, r := range src.cloudApiMetadata.Resources {
-		if r.CfType == string(resourceType) {
-			return tokens.Type(tok), true
+	toks := src.resourceTokens[resourceType]
+	if len(toks) == 0 {
+		return "", false
+	}
+	return toks[0], true
+}
+
+// ResourceTokens returns every Pulumi resource a CloudFormation resource of resourceType is imported
+// as: all the resources it is split into, or else the single [ResourceToken].
+func (src *awsClassicMetadataSource) ResourceTokens(resourceType common.ResourceType) []tokens.Type {
+	if split, ok := src.splitTokens[resourceType]; ok {
+		return slices.Clone(split)
+	}
+	if tok, ok := src.ResourceToken(resourceType); ok {
+		return []tokens.Type{tok}
+	}
+	return nil
+}
+
+// preferToken maps tok to resourceType ahead of the tokens already mapped to it, moving it from
+// any other type. The type is no longer imported as the resources it was split into.
+func (src *awsClassicMetadataSource) preferToken(resourceType common.ResourceType, tok tokens.Type) {
+	delete(src.splitTokens, resourceType)
+	for rt, toks := range src.resourceTokens {
+		if i := slices.Index(toks, tok); i >= 0 {
+			src.resourceTokens[rt] = slices.Delete(slices.Clone(toks), i, i+1)
+		}
+	}
+	for rt, toks := range src.splitTokens {
+		if slices.Contains(toks, tok) {
+			delete(src.splitTokens, rt)
 		}
 	}
-	return "", false
+	src.resourceTokens[resourceType] = append([]tokens.Type{tok}, src.resourceTokens[resourceType]...)
 }
 
 // Find which Pulumi properties are needed to construct a Primary Resource Identifier.
@@ -84,6 +128,12 @@ func (src *awsClassicMetadataSource) Separator(resourceToken tokens.Type) string
 	return "/"
 }
 
+// IDTemplate returns the user supplied template that renders the import ID for resourceType.
+func (src *awsClassicMetadataSource) IDTemplate(resourceType common.ResourceType) (string, bool) {
+	tmpl, ok := src.idTemplates[resourceType]
+	return tmpl, ok
+}
+
 // deriveSeparator attempts to infer the separator between identifier parts from the provided format string.
 func deriveSeparator(format string, parts []string) string {
 	if len(parts) < 2 || format == "" {
@@ -113,8 +163,14 @@ var awsClassicMetadata *awsClassicMetadataSource
 var primaryIdentifiersBytes []byte
 
 func init() {
+	awsClassicMetadata = loadAwsClassicMetadata(primaryIdentifiersBytes)
+}
+
+// loadAwsClassicMetadata indexes the aws entries of the primary identifiers schema. CloudFormation
+// types are visited in sorted order, so ties between them resolve the same way on every run.
+func loadAwsClassicMetadata(data []byte) *awsClassicMetadataSource {
 	var raw map[string]json.RawMessage
-	if err := json.Unmarshal(primaryIdentifiersBytes, &raw); err != nil {
+	if err := json.Unmarshal(data, &raw); err != nil {
 		panic(fmt.Errorf("decoding primary identifiers: %w", err))
 	}
 
@@ -122,11 +178,16 @@ func init() {
 		resource metadata.CloudAPIResource
 		count    int
 		sep      string
+		// entry and position order the candidates of a CloudFormation type as listed in the schema.
+		entry    int
+		position int
 	}
 
 	candidates := map[string]resourceCandidate{}
+	splitTokens := map[common.ResourceType][]tokens.Type{}
 
-	for cfType, rawEntry := range raw {
+	for _, cfType := range slices.Sorted(maps.Keys(raw)) {
+		rawEntry := raw[cfType]
 		var entries []primaryIdentifierEntry
 		if len(rawEntry) > 0 && rawEntry[0] == '[' {
 			if err := json.Unmarshal(rawEntry, &entries); err != nil {
@@ -140,63 +201,131 @@ func init() {
 			entries = append(entries, entry)
 		}
 
-		for _, entry := range entries {
+		for entryIdx, entry := range entries {
 			if entry.Provider != "aws" {
 				continue
 			}
 
 			count := len(entry.PulumiTypes)
-			for _, pulumiType := range entry.PulumiTypes {
+			if entry.Split {
+				// Every split resource maps to the CloudFormation type as specifically as a single one.
+				count = 1
+				for _, pulumiType := range entry.PulumiTypes {
+					if slices.Contains(entry.OptionalPulumiTypes, pulumiType) {
+						continue
+					}
+					splitTokens[common.ResourceType(cfType)] = append(splitTokens[common.ResourceType(cfType)], tokens.Type(pulumiType))
+				}
+			}
+			for position, pulumiType := range entry.PulumiTypes {
 				sep := deriveSeparator(entry.PrimaryIdentifier.Format, entry.PrimaryIdentifier.Parts)
-				// Prefer more specific mappings (fewer pulumiTypes attached to a CF type).
-				if existing, ok := candidates[pulumiType]; ok && existing.count <= count {
-					continue
+				// Prefer more specific mappings (fewer pulumiTypes attached to a CF type), then the CF
+				// type named like the resource, then the first CF type in sorted order.
+				if existing, ok := candidates[pulumiType]; ok {
+					if existing.count < count {
+						continue
+					}
+					if existing.count == count && (namedLike(existing.resource.CfType, pulumiType) || !namedLike(cfType, pulumiType)) {
+						continue
+					}
 				}
 				candidates[pulumiType] = resourceCandidate{
 					resource: metadata.CloudAPIResource{
 						CfType:            cfType,
 						PrimaryIdentifier: entry.PrimaryIdentifier.Parts,
 					},
-					count: count,
-					sep:   sep,
+					count:    count,
+					sep:      sep,
+					entry:    entryIdx,
+					position: position,
 				}
 			}
 		}
 	}
 
-	// Manual overrides for resources that are either absent from the schema or require custom identifiers.
-	manualResources := map[string]metadata.CloudAPIResource{
-		"aws:iam/rolePolicyAttachment:RolePolicyAttachment": {
-			CfType: "AWS::IAM::Policy",
-			PrimaryIdentifier: []string{
-				"policyArn",
-				"role",
-			},
-		},
-	}
-	for tok, res := range manualResources {
-		candidates[tok] = resourceCandidate{
-			resource: res,
-			count:    0,
-			sep:      deriveSeparator("", res.PrimaryIdentifier),
-		}
-	}
-
 	resources := map[string]metadata.CloudAPIResource{}
 	separators := map[string]string{}
+	resourceTokens := map[common.ResourceType][]tokens.Type{}
 	for tok, candidate := range candidates {
 		resources[tok] = candidate.resource
 		sep := candidate.sep
 		if sep != "/" {
 			separators[tok] = sep
 		}
+		resourceType := common.ResourceType(candidate.resource.CfType)
+		resourceTokens[resourceType] = append(resourceTokens[resourceType], tokens.Type(tok))
+	}
+	for _, toks := range resourceTokens {
+		slices.SortFunc(toks, func(a, b tokens.Type) int {
+			ca, cb := candidates[string(a)], candidates[string(b)]
+			return cmp.Or(
+				cmp.Compare(ca.count, cb.count),
+				cmp.Compare(ca.entry, cb.entry),
+				cmp.Compare(ca.position, cb.position),
+				cmp.Compare(a, b),
+			)
+		})
+	}
+	// A split resource only imports as a whole if every part still maps to it.
+	for resourceType, toks := range splitTokens {
+		for _, tok := range toks {
+			if candidates[string(tok)].resource.CfType != string(resourceType) {
+				delete(splitTokens, resourceType)
+				break
+			}
+		}
 	}
 
-	awsClassicMetadata = &awsClassicMetadataSource{
-		separator: separators,
+	return &awsClassicMetadataSource{
+		separator:      separators,
+		idTemplates:    map[common.ResourceType]string{},
+		resourceTokens: resourceTokens,
+		splitTokens:    splitTokens,
 		cloudApiMetadata: metadata.CloudAPIMetadata{
 			Resources: resources,
 		},
 	}
+}
+
+// namedLike reports whether the CloudFormation type and the Pulumi token name the same resource,
+// e.g. AWS::MediaConvert::Queue and aws:mediaconvert/queue:Queue.
+func namedLike(cfType, pulumiType string) bool {
+	cfName := cfType[strings.LastIndex(cfType, ":")+1:]
+	return strings.EqualFold(cfName, tokens.Type(pulumiType).Name().String())
+}
+
+// AwsSchema is the schema of the aws provider, as printed by `pulumi package get-schema aws`. The
+// embedded metadata only covers primary identifiers, so it is the source of aws input properties.
+type AwsSchema struct {
+	Resources map[string]struct {
+		InputProperties map[string]json.RawMessage `json:"inputProperties"`
+	} `json:"resources"`
+}
+
+// LoadAwsSchema reads an aws provider schema from path.
+func LoadAwsSchema(path string) (*AwsSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading aws schema: %w", err)
+	}
+	var schema AwsSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("parsing aws schema %s: %w", path, err)
+	}
+	if len(schema.Resources) == 0 {
+		return nil, fmt.Errorf("aws schema %s has no resources", path)
+	}
+	return &schema, nil
+}
+
+// HasResource reports whether the schema has the resource.
+func (s *AwsSchema) HasResource(resourceToken tokens.Type) bool {
+	_, ok := s.Resources[string(resourceToken)]
+	return ok
+}
 
+// IsInput reports whether prop is an input property of the resource.
+func (s *AwsSchema) IsInput(resourceToken tokens.Type, prop string) bool {
+	_, ok := s.Resources[string(resourceToken)].InputProperties[prop]
+	return ok
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestAwsClassicMetadataSourceSeparator(t *testing.T) {
@@ -34,6 +35,19 @@ func TestAwsClassicMetadataPrimaryIdentifierFromSchema(t *testing.T) {
 	props, ok := src.PrimaryIdentifier(tokens.Type("aws:iam/policy:Policy"))
 	assert.True(t, ok)
 	assert.Equal(t, []resource.PropertyKey{"arn"}, props)
+
+	for token, principal := range map[tokens.Type]resource.PropertyKey{
+		"aws:iam/rolePolicyAttachment:RolePolicyAttachment":   "role",
+		"aws:iam/userPolicyAttachment:UserPolicyAttachment":   "user",
+		"aws:iam/groupPolicyAttachment:GroupPolicyAttachment": "group",
+	} {
+		props, ok := src.PrimaryIdentifier(token)
+		assert.True(t, ok, token)
+		assert.Equal(t, []resource.PropertyKey{principal, "policyArn"}, props, token)
+		resourceType, ok := src.ResourceType(token)
+		assert.True(t, ok, token)
+		assert.Equal(t, common.ResourceType("AWS::IAM::Policy"), resourceType, token)
+	}
 }
 
 func TestAwsClassicMetadataPrefersSpecificMappings(t *testing.T) {
@@ -43,3 +57,65 @@ func TestAwsClassicMetadataPrefersSpecificMappings(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, common.ResourceType("AWS::ApiGatewayV2::Stage"), resourceType)
 }
+
+func TestAwsClassicMetadataResourceToken(t *testing.T) {
+	src := NewAwsMetadataSource()
+
+	t.Run("picks the same token on every call", func(t *testing.T) {
+		for range 20 {
+			tok, ok := src.ResourceToken("AWS::IAM::Policy")
+			assert.True(t, ok)
+			assert.Equal(t, tokens.Type("aws:iam/policy:Policy"), tok)
+		}
+	})
+
+	t.Run("alternatives import as the preferred token only", func(t *testing.T) {
+		assert.Equal(t, []tokens.Type{"aws:fsx/lustreFileSystem:LustreFileSystem"}, src.ResourceTokens("AWS::FSx::FileSystem"))
+	})
+
+	t.Run("split resources import as every part", func(t *testing.T) {
+		tok, ok := src.ResourceToken("AWS::S3::Bucket")
+		assert.True(t, ok)
+		assert.Equal(t, tokens.Type("aws:s3/bucketV2:BucketV2"), tok)
+		assert.Equal(t, []tokens.Type{
+			"aws:s3/bucketV2:BucketV2",
+			"aws:s3/bucketVersioningV2:BucketVersioningV2",
+		}, src.ResourceTokens("AWS::S3::Bucket"), "optional parts aren't imported with the bucket")
+		resourceType, ok := src.ResourceType("aws:s3/bucketLifecycleConfigurationV2:BucketLifecycleConfigurationV2")
+		assert.True(t, ok)
+		assert.Equal(t, common.ResourceType("AWS::S3::Bucket"), resourceType)
+		props, ok := src.PrimaryIdentifier("aws:s3/bucketVersioningV2:BucketVersioningV2")
+		assert.True(t, ok)
+		assert.Equal(t, []resource.PropertyKey{"bucket"}, props)
+	})
+
+	t.Run("unknown types", func(t *testing.T) {
+		_, ok := src.ResourceToken("AWS::Nope::Thing")
+		assert.False(t, ok)
+		assert.Empty(t, src.ResourceTokens("AWS::Nope::Thing"))
+	})
+}
+
+func TestAwsClassicMetadataResolvesTiesDeterministically(t *testing.T) {
+	// Each token is mapped from several CloudFormation types with the same specificity.
+	expected := map[tokens.Type]common.ResourceType{
+		"aws:route53/record:Record":                      "AWS::Route53::RecordSet",
+		"aws:kinesisanalyticsv2/application:Application": "AWS::KinesisAnalyticsV2::ApplicationCloudWatchLoggingOption",
+		"aws:mediaconvert/queue:Queue":                   "AWS::MediaConvert::Queue",
+		"aws:rds/proxyTarget:ProxyTarget":                "AWS::RDS::DBProxyTargetGroup",
+		"aws:servicecatalog/constraint:Constraint":       "AWS::ServiceCatalog::LaunchRoleConstraint",
+	}
+	// Map iteration order changes between loads, so repeated loads catch order dependence.
+	for range 20 {
+		src := loadAwsClassicMetadata(primaryIdentifiersBytes)
+		for tok, resourceType := range expected {
+			got, ok := src.ResourceType(tok)
+			require.True(t, ok, tok)
+			assert.Equal(t, resourceType, got, tok)
+			assert.Contains(t, src.resourceTokens[resourceType], tok, resourceType)
+		}
+		tok, ok := src.ResourceToken("AWS::Route53::RecordSet")
+		require.True(t, ok)
+		assert.Equal(t, tokens.Type("aws:route53/record:Record"), tok)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	_ "embed"
 	"encoding/json"
 	"fmt"
+	"slices"
 
 	"github.com/pulumi/pulumi-aws-native/provider/pkg/metadata"
 	"github.com/pulumi/pulumi-aws-native/provider/pkg/naming"
@@ -49,6 +50,7 @@ type awsNativeMetadataSource struct {
 	primaryIdentifierOverrides map[string][]string
 	idPropertyStrategies       map[string]map[string]IdPropertyStrategy
 	listHandlerSchemas         map[common.ResourceType][]string
+	idTemplates                map[common.ResourceType]string
 }
 
 // Convert a Pulumi resource token into the matching CF ResourceType.
@@ -71,6 +73,16 @@ func (src *awsNativeMetadataSource) ResourceToken(resourceType common.ResourceTy
 	return "", false
 }
 
+// ResourceTypes returns every CloudFormation type in the metadata, sorted.
+func (src *awsNativeMetadataSource) ResourceTypes() []common.ResourceType {
+	types := make([]common.ResourceType, 0, len(src.cloudApiMetadata.Resources))
+	for _, r := range src.cloudApiMetadata.Resources {
+		types = append(types, common.ResourceType(r.CfType))
+	}
+	slices.Sort(types)
+	return types
+}
+
 // Find which Pulumi properties are needed to construct a Primary Resource Identifier.
 //
 // See https://docs.aws.amazon.com/cloudcontrolapi/latest/userguide/resource-identifier.html
@@ -131,10 +143,19 @@ func (src *awsNativeMetadataSource) GetIdPropertyStrategy(resourceType common.Re
 		if strategy, ok := strategies[propertyName]; ok {
 			return strategy
 		}
+		if strategy, ok := strategies[anyProperty]; ok {
+			return strategy
+		}
 	}
 	return ""
 }
 
+// IDTemplate returns the user supplied template that renders the import ID for resourceType.
+func (src *awsNativeMetadataSource) IDTemplate(resourceType common.ResourceType) (string, bool) {
+	tmpl, ok := src.idTemplates[resourceType]
+	return tmpl, ok
+}
+
 func (src *awsNativeMetadataSource) ListHandlerRequiredProperties(resourceType common.ResourceType) []string {
 	if props, ok := src.listHandlerSchemas[resourceType]; ok {
 		return props
@@ -198,5 +219,6 @@ func init() {
 			},
 		},
 		listHandlerSchemas: listHandlerSchemas,
+		idTemplates:        map[common.ResourceType]string{},
 	}
 }
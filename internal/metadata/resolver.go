@@ -0,0 +1,270 @@
+package metadata
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi-aws-native/provider/pkg/metadata"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	"gopkg.in/yaml.v3"
+)
+
+// anyProperty is the idPropertyStrategies key for a strategy that applies to every identifier
+// property of a resource type.
+const anyProperty = "*"
+
+var (
+	cfnTypePattern     = regexp.MustCompile(`^[A-Za-z0-9]+::[A-Za-z0-9]+::[A-Za-z0-9]+$`)
+	placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+)
+
+// ResolverConfig holds user supplied overrides for how import IDs are resolved. It is read from a
+// YAML or JSON file (see --resolver-config) and merged over the embedded metadata with
+// [ApplyResolverConfig].
+//
+//	resources:
+//	  AWS::IAM::Role:
+//	    idTemplate: "arn:{partition}:iam::{account}:role/{physicalId}"
+//	  AWS::ApiGateway::Stage:
+//	    primaryIdentifier: [restApiId, stageName]
+//	    separator: "/"
+//	  AWS::Logs::LogGroup:
+//	    strategy: Lookup
+type ResolverConfig struct {
+	// Resources maps a CloudFormation resource type to its overrides.
+	Resources map[string]ResourceOverride `json:"resources" yaml:"resources"`
+}
+
+// ResourceOverride describes the overrides for a single CloudFormation resource type.
+type ResourceOverride struct {
+	// Strategy applies to every identifier property of the type (aws-native only).
+	Strategy IdPropertyStrategy `json:"strategy,omitempty" yaml:"strategy,omitempty"`
+	// PropertyStrategies sets the strategy for individual identifier properties (aws-native only).
+	PropertyStrategies map[string]IdPropertyStrategy `json:"propertyStrategies,omitempty" yaml:"propertyStrategies,omitempty"`
+	// PrimaryIdentifier replaces the properties the import ID is built from.
+	PrimaryIdentifier []string `json:"primaryIdentifier,omitempty" yaml:"primaryIdentifier,omitempty"`
+	// Separator joins the parts of a composite aws classic import ID. aws-native composite IDs are
+	// returned by Cloud Control as-is.
+	Separator string `json:"separator,omitempty" yaml:"separator,omitempty"`
+	// IDTemplate renders the import ID directly, e.g. `arn:aws:iam::{account}:role/{physicalId}`.
+	// Placeholders are `account`, `region`, `partition`, `physicalId`, `logicalId`, `stackName`
+	// or the name of a resource property.
+	IDTemplate string `json:"idTemplate,omitempty" yaml:"idTemplate,omitempty"`
+	// PulumiTypes maps additional aws classic tokens to this type, like the built in manual mappings.
+	// PrimaryIdentifier and Separator then only apply to these tokens.
+	PulumiTypes []string `json:"pulumiTypes,omitempty" yaml:"pulumiTypes,omitempty"`
+}
+
+// LoadResolverConfig reads a resolver config from a YAML or JSON file. Unknown fields are
+// rejected so typos don't silently do nothing.
+func LoadResolverConfig(path string) (*ResolverConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading resolver config: %w", err)
+	}
+	defer f.Close()
+
+	var cfg ResolverConfig
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parsing resolver config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// ApplyResolverConfig merges cfg over the embedded metadata used by [NewAwsMetadataSource] and
+// [NewCCApiMetadataSource]. It must be called before any lookups run. Nothing is changed when the
+// config is invalid.
+func ApplyResolverConfig(cfg *ResolverConfig) error {
+	if cfg == nil {
+		return nil
+	}
+	native := awsNativeMetadata.clone()
+	classic := awsClassicMetadata.clone()
+
+	cfnTypes := make([]string, 0, len(cfg.Resources))
+	for cfnType := range cfg.Resources {
+		cfnTypes = append(cfnTypes, cfnType)
+	}
+	sort.Strings(cfnTypes)
+	for _, cfnType := range cfnTypes {
+		if err := applyResourceOverride(native, classic, cfnType, cfg.Resources[cfnType]); err != nil {
+			return fmt.Errorf("resolver config for %s: %w", cfnType, err)
+		}
+	}
+
+	awsNativeMetadata = native
+	awsClassicMetadata = classic
+	return nil
+}
+
+func applyResourceOverride(native *awsNativeMetadataSource, classic *awsClassicMetadataSource, cfnType string, o ResourceOverride) error {
+	if !cfnTypePattern.MatchString(cfnType) {
+		return fmt.Errorf("expected a CloudFormation resource type like AWS::Service::Resource")
+	}
+	resourceType := common.ResourceType(cfnType)
+
+	strategies := map[string]IdPropertyStrategy{}
+	if o.Strategy != "" {
+		strategies[anyProperty] = o.Strategy
+	}
+	for prop, strategy := range o.PropertyStrategies {
+		strategies[strings.ToLower(prop)] = strategy
+	}
+	for _, strategy := range strategies {
+		if err := validateStrategy(strategy); err != nil {
+			return err
+		}
+	}
+	if o.IDTemplate != "" {
+		if err := validateIDTemplate(o.IDTemplate); err != nil {
+			return err
+		}
+	}
+
+	// Primary identifiers and separators are keyed by Pulumi token.
+	var classicTokens, nativeTokens []string
+	for _, tok := range o.PulumiTypes {
+		if !strings.HasPrefix(tok, "aws:") {
+			return fmt.Errorf("pulumiTypes only supports aws classic tokens; got %q", tok)
+		}
+		classicTokens = append(classicTokens, tok)
+	}
+	if len(classicTokens) == 0 {
+		classicTokens = classic.tokensFor(resourceType)
+		nativeTokens = native.tokensFor(resourceType)
+	}
+	if len(o.PrimaryIdentifier) > 0 && len(classicTokens)+len(nativeTokens) == 0 {
+		return fmt.Errorf("no Pulumi resource maps to this type; add pulumiTypes to declare one")
+	}
+	if o.Separator != "" && len(classicTokens) == 0 {
+		return fmt.Errorf("separator only applies to aws classic resources and none maps to this type")
+	}
+	if len(o.PulumiTypes) > 0 && len(o.PrimaryIdentifier) == 0 {
+		return fmt.Errorf("pulumiTypes requires primaryIdentifier")
+	}
+
+	for _, tok := range o.PulumiTypes {
+		classic.cloudApiMetadata.Resources[tok] = metadata.CloudAPIResource{CfType: cfnType}
+	}
+	// Declared types are preferred over the embedded ones, in the order they are declared.
+	for _, tok := range slices.Backward(o.PulumiTypes) {
+		classic.preferToken(resourceType, tokens.Type(tok))
+	}
+	for _, tok := range classicTokens {
+		if len(o.PrimaryIdentifier) > 0 {
+			r := classic.cloudApiMetadata.Resources[tok]
+			r.PrimaryIdentifier = append([]string{}, o.PrimaryIdentifier...)
+			classic.cloudApiMetadata.Resources[tok] = r
+		}
+		if o.Separator != "" {
+			classic.separator[tok] = o.Separator
+		}
+	}
+	for _, tok := range nativeTokens {
+		if len(o.PrimaryIdentifier) > 0 {
+			native.primaryIdentifierOverrides[tok] = append([]string{}, o.PrimaryIdentifier...)
+		}
+	}
+
+	if len(strategies) > 0 {
+		merged := maps.Clone(native.idPropertyStrategies[cfnType])
+		if merged == nil {
+			merged = map[string]IdPropertyStrategy{}
+		}
+		maps.Copy(merged, strategies)
+		native.idPropertyStrategies[cfnType] = merged
+	}
+	if o.IDTemplate != "" {
+		native.idTemplates[resourceType] = o.IDTemplate
+		classic.idTemplates[resourceType] = o.IDTemplate
+	}
+	return nil
+}
+
+func validateStrategy(strategy IdPropertyStrategy) error {
+	switch strategy {
+	case StrategyPhysicalID, StrategyLookup, StrategyCustom:
+		return nil
+	}
+	return fmt.Errorf("unknown strategy %q; expected %s, %s or %s", strategy, StrategyPhysicalID, StrategyLookup, StrategyCustom)
+}
+
+func validateIDTemplate(template string) error {
+	rest := placeholderPattern.ReplaceAllStringFunc(template, func(string) string { return "" })
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("idTemplate %q has unbalanced braces", template)
+	}
+	for _, m := range placeholderPattern.FindAllStringSubmatch(template, -1) {
+		if strings.TrimSpace(m[1]) == "" {
+			return fmt.Errorf("idTemplate %q has an empty placeholder", template)
+		}
+	}
+	return nil
+}
+
+// ExpandIDTemplate replaces every `{name}` placeholder in template using lookup. It errors on the
+// first placeholder lookup can't resolve.
+func ExpandIDTemplate(template string, lookup func(name string) (string, bool)) (string, error) {
+	var missing []string
+	out := placeholderPattern.ReplaceAllStringFunc(template, func(m string) string {
+		name := strings.TrimSpace(m[1 : len(m)-1])
+		val, ok := lookup(name)
+		if !ok {
+			missing = append(missing, name)
+			return m
+		}
+		return val
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("no value for placeholder %q in id template %q", missing[0], template)
+	}
+	return out, nil
+}
+
+func (src *awsNativeMetadataSource) clone() *awsNativeMetadataSource {
+	c := *src
+	c.primaryIdentifierOverrides = maps.Clone(src.primaryIdentifierOverrides)
+	c.idPropertyStrategies = maps.Clone(src.idPropertyStrategies)
+	c.idTemplates = maps.Clone(src.idTemplates)
+	return &c
+}
+
+func (src *awsNativeMetadataSource) tokensFor(resourceType common.ResourceType) []string {
+	var toks []string
+	for tok, r := range src.cloudApiMetadata.Resources {
+		if r.CfType == string(resourceType) {
+			toks = append(toks, tok)
+		}
+	}
+	sort.Strings(toks)
+	return toks
+}
+
+func (src *awsClassicMetadataSource) clone() *awsClassicMetadataSource {
+	c := *src
+	c.separator = maps.Clone(src.separator)
+	c.cloudApiMetadata.Resources = maps.Clone(src.cloudApiMetadata.Resources)
+	c.idTemplates = maps.Clone(src.idTemplates)
+	c.resourceTokens = maps.Clone(src.resourceTokens)
+	c.splitTokens = maps.Clone(src.splitTokens)
+	return &c
+}
+
+func (src *awsClassicMetadataSource) tokensFor(resourceType common.ResourceType) []string {
+	var toks []string
+	for tok, r := range src.cloudApiMetadata.Resources {
+		if r.CfType == string(resourceType) {
+			toks = append(toks, tok)
+		}
+	}
+	sort.Strings(toks)
+	return toks
+}
@@ -0,0 +1,171 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+)
+
+// restoreMetadata puts the embedded metadata back once the test finishes.
+func restoreMetadata(t *testing.T) {
+	native, classic := awsNativeMetadata, awsClassicMetadata
+	t.Cleanup(func() {
+		awsNativeMetadata, awsClassicMetadata = native, classic
+	})
+}
+
+func writeConfig(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+	return path
+}
+
+func TestLoadResolverConfig(t *testing.T) {
+	t.Run("yaml", func(t *testing.T) {
+		path := writeConfig(t, "resolver.yaml", `
+resources:
+  AWS::IAM::Role:
+    idTemplate: "arn:aws:iam::{account}:role/{physicalId}"
+  AWS::Logs::LogGroup:
+    strategy: Lookup
+    propertyStrategies:
+      Arn: PhysicalID
+`)
+		cfg, err := LoadResolverConfig(path)
+		require.NoError(t, err)
+		assert.Equal(t, "arn:aws:iam::{account}:role/{physicalId}", cfg.Resources["AWS::IAM::Role"].IDTemplate)
+		assert.Equal(t, StrategyLookup, cfg.Resources["AWS::Logs::LogGroup"].Strategy)
+		assert.Equal(t, StrategyPhysicalID, cfg.Resources["AWS::Logs::LogGroup"].PropertyStrategies["Arn"])
+	})
+
+	t.Run("json", func(t *testing.T) {
+		path := writeConfig(t, "resolver.json", `{"resources": {"AWS::ApiGateway::Stage": {"primaryIdentifier": ["restApiId", "stageName"], "separator": ":"}}}`)
+		cfg, err := LoadResolverConfig(path)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"restApiId", "stageName"}, cfg.Resources["AWS::ApiGateway::Stage"].PrimaryIdentifier)
+		assert.Equal(t, ":", cfg.Resources["AWS::ApiGateway::Stage"].Separator)
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		path := writeConfig(t, "resolver.yaml", "resources:\n  AWS::IAM::Role:\n    idTemplat: x\n")
+		_, err := LoadResolverConfig(path)
+		assert.ErrorContains(t, err, "idTemplat")
+	})
+}
+
+func TestApplyResolverConfig(t *testing.T) {
+	t.Run("merges overrides over embedded metadata", func(t *testing.T) {
+		restoreMetadata(t)
+		err := ApplyResolverConfig(&ResolverConfig{Resources: map[string]ResourceOverride{
+			"AWS::IAM::Role": {IDTemplate: "arn:aws:iam::{account}:role/{physicalId}"},
+			"AWS::Logs::LogGroup": {
+				Strategy:           StrategyLookup,
+				PropertyStrategies: map[string]IdPropertyStrategy{"Arn": StrategyPhysicalID},
+			},
+			"AWS::ApiGatewayV2::Stage": {PrimaryIdentifier: []string{"name", "apiId"}, Separator: ":"},
+			"AWS::ApiGateway::Stage":   {PrimaryIdentifier: []string{"stageName", "restApiId"}},
+			"AWS::IAM::ManagedPolicy": {
+				PulumiTypes:       []string{"aws:iam/policyAttachment:PolicyAttachment"},
+				PrimaryIdentifier: []string{"name"},
+			},
+		}})
+		require.NoError(t, err)
+
+		native, classic := NewCCApiMetadataSource(), NewAwsMetadataSource()
+		tmpl, ok := native.IDTemplate("AWS::IAM::Role")
+		assert.True(t, ok)
+		assert.Equal(t, "arn:aws:iam::{account}:role/{physicalId}", tmpl)
+		_, ok = classic.IDTemplate("AWS::IAM::Role")
+		assert.True(t, ok)
+
+		assert.Equal(t, StrategyPhysicalID, native.GetIdPropertyStrategy("AWS::Logs::LogGroup", "arn"))
+		assert.Equal(t, StrategyLookup, native.GetIdPropertyStrategy("AWS::Logs::LogGroup", "loggroupname"))
+		// Embedded strategies for other types are kept.
+		assert.Equal(t, StrategyCustom, native.GetIdPropertyStrategy("AWS::Events::Rule", "arn"))
+
+		props, ok := classic.PrimaryIdentifier(tokens.Type("aws:apigatewayv2/stage:Stage"))
+		assert.True(t, ok)
+		assert.Equal(t, []resource.PropertyKey{"name", "apiId"}, props)
+		assert.Equal(t, ":", classic.Separator(tokens.Type("aws:apigatewayv2/stage:Stage")))
+		props, ok = native.PrimaryIdentifier(tokens.Type("aws-native:apigateway:Stage"))
+		assert.True(t, ok)
+		assert.Equal(t, []resource.PropertyKey{"stageName", "restApiId"}, props)
+
+		resourceType, ok := classic.ResourceType(tokens.Type("aws:iam/policyAttachment:PolicyAttachment"))
+		assert.True(t, ok)
+		assert.Equal(t, common.ResourceType("AWS::IAM::ManagedPolicy"), resourceType)
+	})
+
+	t.Run("declared pulumi types are preferred", func(t *testing.T) {
+		restoreMetadata(t)
+		before := NewAwsMetadataSource()
+		err := ApplyResolverConfig(&ResolverConfig{Resources: map[string]ResourceOverride{
+			"AWS::S3::Bucket": {PulumiTypes: []string{"aws:s3/bucket:Bucket"}, PrimaryIdentifier: []string{"bucket"}},
+		}})
+		require.NoError(t, err)
+		classic := NewAwsMetadataSource()
+		tok, ok := classic.ResourceToken("AWS::S3::Bucket")
+		assert.True(t, ok)
+		assert.Equal(t, tokens.Type("aws:s3/bucket:Bucket"), tok)
+		assert.Equal(t, []tokens.Type{"aws:s3/bucket:Bucket"}, classic.ResourceTokens("AWS::S3::Bucket"))
+		assert.Len(t, before.ResourceTokens("AWS::S3::Bucket"), 2, "the embedded metadata is untouched")
+	})
+
+	t.Run("invalid config leaves metadata untouched", func(t *testing.T) {
+		restoreMetadata(t)
+		before := NewCCApiMetadataSource()
+		err := ApplyResolverConfig(&ResolverConfig{Resources: map[string]ResourceOverride{
+			"AWS::IAM::Role":      {IDTemplate: "arn:aws:iam::{account}:role/{physicalId}"},
+			"AWS::Logs::LogGroup": {Strategy: "Guess"},
+		}})
+		assert.ErrorContains(t, err, `resolver config for AWS::Logs::LogGroup: unknown strategy "Guess"`)
+		assert.Same(t, before, NewCCApiMetadataSource())
+		_, ok := NewCCApiMetadataSource().IDTemplate("AWS::IAM::Role")
+		assert.False(t, ok)
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		restoreMetadata(t)
+		cases := map[string]struct {
+			cfnType  string
+			override ResourceOverride
+			err      string
+		}{
+			"bad type":         {"IAM::Role", ResourceOverride{Strategy: StrategyLookup}, "expected a CloudFormation resource type"},
+			"unbalanced":       {"AWS::IAM::Role", ResourceOverride{IDTemplate: "arn:{account"}, "unbalanced braces"},
+			"empty":            {"AWS::IAM::Role", ResourceOverride{IDTemplate: "arn:{}"}, "empty placeholder"},
+			"unknown type":     {"AWS::Nope::Thing", ResourceOverride{PrimaryIdentifier: []string{"id"}}, "add pulumiTypes"},
+			"native token":     {"AWS::IAM::Role", ResourceOverride{PulumiTypes: []string{"aws-native:iam:Role"}, PrimaryIdentifier: []string{"roleName"}}, "aws classic tokens"},
+			"types without id": {"AWS::IAM::Role", ResourceOverride{PulumiTypes: []string{"aws:iam/role:Role"}}, "requires primaryIdentifier"},
+			"native separator": {"AWS::ApiGateway::Stage", ResourceOverride{Separator: ":"}, "only applies to aws classic"},
+		}
+		for name, tc := range cases {
+			t.Run(name, func(t *testing.T) {
+				err := ApplyResolverConfig(&ResolverConfig{Resources: map[string]ResourceOverride{tc.cfnType: tc.override}})
+				assert.ErrorContains(t, err, tc.err)
+			})
+		}
+	})
+}
+
+func TestExpandIDTemplate(t *testing.T) {
+	vars := map[string]string{"account": "123456789012", "physicalId": "my-role"}
+	lookup := func(name string) (string, bool) {
+		val, ok := vars[name]
+		return val, ok
+	}
+
+	id, err := ExpandIDTemplate("arn:aws:iam::{account}:role/{ physicalId }", lookup)
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/my-role", id)
+
+	_, err = ExpandIDTemplate("{account}/{region}", lookup)
+	assert.ErrorContains(t, err, `no value for placeholder "region"`)
+}
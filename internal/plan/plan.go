@@ -0,0 +1,248 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/imports"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/metadata"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+)
+
+// importFileStack and importFileProject are used to build URNs for resources read from an import
+// file, which only records a type and name.
+const (
+	importFileStack   = "import-file"
+	importFileProject = "import-file"
+)
+
+// Resource is a registered Pulumi resource that should be resolved to an import ID.
+type Resource struct {
+	URN    resource.URN
+	Inputs resource.PropertyMap
+}
+
+// Entry records how a single resource was (or wasn't) resolved.
+type Entry struct {
+	URN       resource.URN
+	LogicalID lookups.StackResourceKey
+	PrimaryID common.PrimaryResourceID
+	Strategy  lookups.ResolutionStrategy
+	Error     string
+}
+
+// Resolve runs the same logical and primary ID lookups the provider interceptors use, without
+// starting any providers or touching a Pulumi stack. ID overrides on l are honored. Resources the
+// interceptors create instead of importing get the [lookups.ResolutionCreate] strategy. Non-AWS
+// resources are ignored.
+func Resolve(ctx context.Context, l *lookups.Lookups, resources []Resource) ([]Entry, error) {
+	ccapi, err := lookups.NewCCApiLookups(ctx, l.CCAPIClient, l.CfnStackResources, l.Region, l.Account, l.EventsClient, l.CCAPICache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API Client for CCAPI: %w", err)
+	}
+	aws := lookups.NewAwsLookups(l.CfnStackResources, l.Region, l.Account)
+
+	entries := make([]Entry, 0, len(resources))
+	for _, res := range resources {
+		token := string(res.URN.Type())
+		switch {
+		case token == lookups.CustomResourceEmulatorToken:
+			entries = append(entries, resolveCustomResource(ctx, l, res))
+		case strings.HasPrefix(token, "aws-native:"):
+			entries = append(entries, resolveNative(ctx, ccapi, l, res))
+		case strings.HasPrefix(token, "aws:"):
+			entries = append(entries, resolveClassic(ctx, aws, l, res))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].URN < entries[j].URN
+	})
+	return entries, nil
+}
+
+// resolveClassic resolves an aws resource, classifying the bootstrap asset storage and the
+// resources that are created instead of imported the way the aws interceptor does.
+func resolveClassic(ctx context.Context, r lookups.Resolver, l *lookups.Lookups, res Resource) Entry {
+	if _, ok := lookups.CreateInstead(res.URN.Type(), l.Bootstrap, nil); ok {
+		return Entry{URN: res.URN, Strategy: lookups.ResolutionCreate}
+	}
+	if asset, ok := l.Bootstrap.ForToken(res.URN.Type()); ok {
+		return Entry{URN: res.URN, LogicalID: asset.Key, PrimaryID: asset.ID, Strategy: lookups.ResolutionBootstrap}
+	}
+	if lookups.IsPolicyAttachment(res.URN.Type()) {
+		if key, err := r.FindLogicalResourceID(res.URN); err == nil && !lookups.PolicyImported(ctx, r, l.IDOverrides, key) {
+			return Entry{URN: res.URN, LogicalID: key, Strategy: lookups.ResolutionCreate}
+		}
+	}
+	return resolveEntry(ctx, r, l, res, res.Inputs.Mappable())
+}
+
+func resolveNative(ctx context.Context, r lookups.Resolver, l *lookups.Lookups, res Resource) Entry {
+	props, err := metadata.NewCCApiMetadataSource().CfnProperties(string(res.URN.Type()), res.Inputs)
+	if err != nil {
+		return Entry{URN: res.URN, Error: err.Error()}
+	}
+	return resolveEntry(ctx, r, l, res, props)
+}
+
+// resolveCustomResource resolves a CDK custom resource, whose CloudFormation type is one of its inputs.
+func resolveCustomResource(ctx context.Context, l *lookups.Lookups, res Resource) Entry {
+	resourceType := res.Inputs["resourceType"]
+	if !resourceType.IsString() || resourceType.StringValue() == "" {
+		return Entry{URN: res.URN, Error: "custom resource has no resourceType"}
+	}
+	r := l.CustomResourceResolver(common.ResourceType(resourceType.StringValue()))
+	return resolveEntry(ctx, r, l, res, nil)
+}
+
+func resolveEntry(ctx context.Context, r lookups.Resolver, l *lookups.Lookups, res Resource, props map[string]any) Entry {
+	entry := Entry{URN: res.URN}
+	key, id, strategy, err := lookups.ResolveImportID(ctx, r, l.IDOverrides, res.URN, props)
+	entry.LogicalID = key
+	if _, ok := lookups.CreateInstead(res.URN.Type(), l.Bootstrap, err); ok {
+		entry.Strategy = lookups.ResolutionCreate
+		return entry
+	}
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.PrimaryID = id
+	entry.Strategy = strategy
+	return entry
+}
+
+// Failures counts entries that could not be resolved.
+func Failures(entries []Entry) int {
+	failed := 0
+	for _, e := range entries {
+		if e.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// WriteTable renders entries as an aligned, human-readable table.
+func WriteTable(w io.Writer, entries []Entry) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "URN\tLOGICAL ID\tPRIMARY ID\tSTRATEGY\tERROR")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			e.URN,
+			orDash(e.LogicalID.String()),
+			orDash(string(e.PrimaryID)),
+			orDash(string(e.Strategy)),
+			orDash(firstLine(e.Error)),
+		)
+	}
+	return tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		return s[:i]
+	}
+	return s
+}
+
+// ResourcesFromImportFile converts import file entries into resources to resolve. Component
+// entries are skipped since they have no ID, and no inputs are available so lookups rely on
+// CloudFormation data alone.
+func ResourcesFromImportFile(file *imports.File) []Resource {
+	if file == nil {
+		return nil
+	}
+	resources := make([]Resource, 0, len(file.Resources))
+	for _, r := range file.Resources {
+		if r.Component || r.Type == "" {
+			continue
+		}
+		name := r.Name
+		if name == "" {
+			name = r.LogicalName
+		}
+		if name == "" {
+			continue
+		}
+		resources = append(resources, Resource{
+			URN:    resource.NewURN(importFileStack, importFileProject, "", tokens.Type(r.Type), name),
+			Inputs: resource.PropertyMap{},
+		})
+	}
+	return resources
+}
+
+// ResourcesFromPreview runs `pulumi preview` against the selected stack of the program in workDir
+// and returns every custom resource the program registers, along with its inputs.
+func ResourcesFromPreview(ctx context.Context, workDir string, envVars map[string]string) ([]Resource, error) {
+	ws, err := auto.NewLocalWorkspace(ctx, auto.WorkDir(workDir), auto.EnvVars(envVars))
+	if err != nil {
+		return nil, err
+	}
+	summary, err := ws.Stack(ctx)
+	if err != nil || summary == nil {
+		return nil, fmt.Errorf("%w: make sure to select a stack with `pulumi stack select`", err)
+	}
+	stack, err := auto.SelectStackLocalSource(ctx, summary.Name, workDir, auto.EnvVars(envVars))
+	if err != nil {
+		return nil, err
+	}
+
+	eventCh := make(chan events.EngineEvent)
+	var resources []Resource
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		seen := map[resource.URN]struct{}{}
+		for evt := range eventCh {
+			pre := evt.ResourcePreEvent
+			if pre == nil || pre.Metadata.New == nil || !pre.Metadata.New.Custom {
+				continue
+			}
+			urn, err := resource.ParseURN(pre.Metadata.URN)
+			if err != nil {
+				continue
+			}
+			if _, ok := seen[urn]; ok {
+				continue
+			}
+			seen[urn] = struct{}{}
+			resources = append(resources, Resource{
+				URN:    urn,
+				Inputs: resource.NewPropertyMapFromMap(pre.Metadata.New.Inputs),
+			})
+		}
+	}()
+
+	_, err = stack.Preview(ctx,
+		optpreview.EventStreams(eventCh),
+		optpreview.ProgressStreams(io.Discard),
+		optpreview.ErrorProgressStreams(io.Discard),
+		optpreview.SuppressProgress(),
+	)
+	wg.Wait()
+	if err != nil {
+		return nil, fmt.Errorf("pulumi preview failed: %w", err)
+	}
+	return resources, nil
+}
@@ -0,0 +1,143 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/imports"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func TestResolve(t *testing.T) {
+	l := &lookups.Lookups{
+		Region:  "us-west-2",
+		Account: "123456789012",
+		CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+			{StackName: "Stack", LogicalID: "QueuePolicy"}: {
+				ResourceType: "AWS::SQS::QueuePolicy",
+				LogicalID:    "QueuePolicy",
+				PhysicalID:   "policy-physical-id",
+				StackName:    "Stack",
+			},
+			{StackName: "Stack", LogicalID: "SeedData1234"}: {
+				ResourceType: "Custom::SeedData",
+				LogicalID:    "SeedData1234",
+				PhysicalID:   "Stack-Seed-1A2B3C",
+				StackName:    "Stack",
+			},
+		},
+	}
+	queueURL := "https://sqs.us-west-2.amazonaws.com/123456789012/my-queue"
+	resources := []Resource{
+		{URN: resource.URN("urn:pulumi:dev::proj::aws:sqs/queuePolicy:QueuePolicy::Missing")},
+		{
+			URN: resource.URN("urn:pulumi:dev::proj::aws:sqs/queuePolicy:QueuePolicy::QueuePolicy"),
+			Inputs: resource.PropertyMap{
+				"queueUrl": resource.NewStringProperty(queueURL),
+			},
+		},
+		{URN: resource.URN("urn:pulumi:dev::proj::random:index/randomString:RandomString::QueuePolicy")},
+		{
+			URN: resource.URN("urn:pulumi:dev::proj::aws-native:cloudformation:CustomResourceEmulator::SeedData"),
+			Inputs: resource.PropertyMap{
+				"resourceType": resource.NewStringProperty("Custom::SeedData"),
+			},
+		},
+	}
+
+	entries, err := Resolve(context.Background(), l, resources)
+	require.NoError(t, err)
+	require.Len(t, entries, 3, "non-AWS resources are ignored")
+
+	assert.Equal(t, lookups.StackResourceKey{StackName: "Stack", LogicalID: "SeedData1234"}, entries[0].LogicalID, "custom resources are matched by their resourceType input")
+	assert.Equal(t, common.PrimaryResourceID("Stack-Seed-1A2B3C"), entries[0].PrimaryID)
+	assert.Equal(t, lookups.ResolutionPhysicalID, entries[0].Strategy)
+
+	assert.Equal(t, resource.URN("urn:pulumi:dev::proj::aws:sqs/queuePolicy:QueuePolicy::Missing"), entries[1].URN)
+	assert.NotEmpty(t, entries[1].Error)
+
+	assert.Equal(t, resource.URN("urn:pulumi:dev::proj::aws:sqs/queuePolicy:QueuePolicy::QueuePolicy"), entries[2].URN)
+	assert.Equal(t, lookups.StackResourceKey{StackName: "Stack", LogicalID: "QueuePolicy"}, entries[2].LogicalID)
+	assert.Equal(t, common.PrimaryResourceID(queueURL), entries[2].PrimaryID)
+	assert.Equal(t, lookups.ResolutionProperty, entries[2].Strategy)
+	assert.Empty(t, entries[2].Error)
+
+	assert.Equal(t, 1, Failures(entries))
+}
+
+func TestResolveCreatesInstead(t *testing.T) {
+	l := &lookups.Lookups{
+		Region:  "us-west-2",
+		Account: "123456789012",
+		CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+			{StackName: "Stack", LogicalID: "Policy"}: {
+				ResourceType: "AWS::IAM::Policy",
+				LogicalID:    "Policy",
+				PhysicalID:   "Stack-Polic-1ABC",
+				StackName:    "Stack",
+			},
+		},
+	}
+	resources := []Resource{
+		{URN: resource.URN("urn:pulumi:dev::proj::aws:s3/bucketV2:BucketV2::staging-bucket")},
+		{URN: resource.URN("urn:pulumi:dev::proj::aws:ecr/repository:Repository::container-assets")},
+		{URN: resource.URN("urn:pulumi:dev::proj::aws:iam/policy:Policy::Policy")},
+		{URN: resource.URN("urn:pulumi:dev::proj::aws:iam/rolePolicyAttachment:RolePolicyAttachment::Policy-MyRole")},
+	}
+
+	entries, err := Resolve(context.Background(), l, resources)
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+	for _, e := range entries {
+		assert.Equal(t, lookups.ResolutionCreate, e.Strategy, e.URN)
+		assert.Empty(t, e.Error, e.URN)
+	}
+	assert.Equal(t, 0, Failures(entries), "resources that are created instead aren't failures")
+}
+
+func TestWriteTable(t *testing.T) {
+	entries := []Entry{
+		{
+			URN:       resource.URN("urn:pulumi:dev::proj::aws:s3/bucket:Bucket::Bucket"),
+			LogicalID: lookups.StackResourceKey{StackName: "Stack", LogicalID: "Bucket"},
+			PrimaryID: "my-bucket",
+			Strategy:  lookups.ResolutionPhysicalID,
+		},
+		{
+			URN:   resource.URN("urn:pulumi:dev::proj::aws:s3/bucket:Bucket::Missing"),
+			Error: "No matching CF resources\nmore detail",
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteTable(&buf, entries))
+	out := buf.String()
+	assert.Contains(t, out, "URN")
+	assert.Contains(t, out, "Stack/Bucket")
+	assert.Contains(t, out, "my-bucket")
+	assert.Contains(t, out, "PhysicalID")
+	assert.Contains(t, out, "No matching CF resources")
+	assert.NotContains(t, out, "more detail")
+}
+
+func TestResourcesFromImportFile(t *testing.T) {
+	file := &imports.File{
+		Resources: []imports.Resource{
+			{Type: "cdk:index:Stack", Name: "Stack", Component: true},
+			{Type: "aws:s3/bucket:Bucket", Name: "Bucket", ID: "my-bucket"},
+			{Type: "aws:sqs/queue:Queue", LogicalName: "Queue"},
+		},
+	}
+
+	resources := ResourcesFromImportFile(file)
+	require.Len(t, resources, 2)
+	assert.Equal(t, "Bucket", resources[0].URN.Name())
+	assert.Equal(t, "aws:s3/bucket:Bucket", string(resources[0].URN.Type()))
+	assert.Equal(t, "Queue", resources[1].URN.Name())
+}
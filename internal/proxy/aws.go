@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"sync"
 
 	"log/slog"
 
 	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
@@ -15,11 +17,51 @@ import (
 )
 
 type awsInterceptor struct {
-	*lookups.Lookups
+	instances  *providerInstances
 	mode       RunMode
 	skipCreate bool
 	collector  *CaptureCollector
+	report     *ReportRecorder
 	logger     *slog.Logger
+	// createdPolicies holds the ARNs of managed policies created in place of inline policies, whose
+	// attachments have to be created as well
+	createdPolicies sync.Map
+	// bootstrapImports maps each bootstrap asset resource imported so far to the URN that imported
+	// it, so shared bootstrap storage ends up in state only once
+	bootstrapImports sync.Map
+}
+
+// createInstead creates a resource that can't be imported, unless the run mode forbids it.
+func (i *awsInterceptor) createInstead(
+	ctx context.Context,
+	logger *slog.Logger,
+	in *pulumirpc.CreateRequest,
+	client pulumirpc.ResourceProviderClient,
+	urn resource.URN,
+	reason string,
+) (*pulumirpc.CreateResponse, error) {
+	resourceType := string(urn.Type())
+	if i.mode == Verify {
+		logger.Info(reason+"; skipping verification", "resourceType", resourceType)
+		i.report.mark(string(urn), OutcomeSkipped)
+		return verifyStub(in, ""), nil
+	}
+	if i.skipCreate {
+		return i.stubSkippedCreate(resourceType, urn, in)
+	}
+	if i.mode == CaptureImports {
+		if i.collector != nil {
+			i.collector.Skip(SkippedCapture{
+				Type:        resourceType,
+				LogicalName: string(urn.Name()),
+				Reason:      "resource type not supported for capture",
+			})
+		}
+		return nil, fmt.Errorf("resource type %s is not supported in capture mode", resourceType)
+	}
+	logger.Info(reason+"; creating instead", "resourceType", resourceType)
+	i.report.mark(string(urn), OutcomeCreated)
+	return client.Create(ctx, in)
 }
 
 func (i *awsInterceptor) create(
@@ -37,37 +79,69 @@ func (i *awsInterceptor) create(
 	}
 	resourceType := string(urn.Type())
 
-	// These resources are only mapped to classic resources in the synthesizer so these
-	// resources won't be imported
-	switch resourceType {
-	case "aws:s3/bucketObjectv2:BucketObjectv2",
-		"aws:s3/bucketV2:BucketV2",
-		"aws:s3/bucketLifecycleConfigurationV2:BucketLifecycleConfigurationV2",
-		"aws:s3/bucketServerSideEncryptionConfigurationV2:BucketServerSideEncryptionConfigurationV2",
-		"aws:s3/bucketPolicy:BucketPolicy",
-		"aws:s3/bucketVersioningV2:BucketVersioningV2",
-		"aws:ecr/repository:Repository",
-		"aws:ecr/lifecyclePolicy:LifecyclePolicy",
-		"aws:iam/policy:Policy",
-		"aws:iam/rolePolicyAttachment:RolePolicyAttachment":
-		if i.skipCreate {
-			return i.stubSkippedCreate(resourceType, urn, in)
+	bootstrap := i.bootstrapAssets(ctx)
+	if reason, ok := lookups.CreateInstead(urn.Type(), bootstrap, nil); ok {
+		return i.createInstead(ctx, logger, in, client, urn, reason)
+	}
+	if asset, ok := bootstrap.ForToken(urn.Type()); ok {
+		claimedBy, claimed := i.bootstrapImports.LoadOrStore(resourceType+"::"+string(asset.ID), urn)
+		if !claimed {
+			return i.importBootstrapAsset(ctx, logger, in, client, urn, asset)
+		}
+		logger.Warn("Bootstrap asset storage is already imported by another resource", "urn", string(urn), "importedBy", claimedBy, "id", string(asset.ID))
+		return i.createInstead(ctx, logger, in, client, urn, "Bootstrap asset storage is already imported")
+	}
+	l, err := i.instances.lookups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c := lookups.NewAwsLookups(l.CfnStackResources, l.Region, l.Account)
+	label := fmt.Sprintf("%s.Create(%s)", "aws-proxy", urn)
+	inputs, err := i.unmarshalInputs(in, label)
+	if err != nil {
+		return nil, err
+	}
+	if lookups.IsPolicyAttachment(urn.Type()) {
+		// A policy that was skipped or stubbed rather than created has no ARN, so its attachment
+		// can't be imported either.
+		policyArn := stringInput(inputs, "policyArn")
+		if _, created := i.createdPolicies.Load(policyArn); created || policyArn == "" {
+			return i.createInstead(ctx, logger, in, client, urn, lookups.CreateReasonPolicyAttachment)
 		}
-		if i.mode == CaptureImports {
-			if i.collector != nil {
-				i.collector.Skip(SkippedCapture{
-					Type:        resourceType,
-					LogicalName: string(urn.Name()),
-					Reason:      "resource type not supported for capture",
-				})
+	}
+	logical, prim, strategy, err := l.Checkpoint.ResolveImportID(ctx, c, l.IDOverrides, urn, inputs.Mappable())
+	if reason, ok := lookups.CreateInstead(urn.Type(), bootstrap, err); ok {
+		// pulumi-cdk models the inline policies as a managed policy (pulumi/pulumi-cdk#293), so the
+		// policy has to be created; the inline policies it replaces are left on their principals.
+		logger.Warn("Policy is deployed as inline policies; creating a managed policy instead. Remove the inline policies once migrated",
+			"urn", string(urn), "logicalID", logical.String())
+		resp, err := i.createInstead(ctx, logger, in, client, urn, reason)
+		if err == nil {
+			if arn := resp.GetProperties().GetFields()["arn"].GetStringValue(); arn != "" {
+				i.createdPolicies.Store(arn, struct{}{})
 			}
-			return nil, fmt.Errorf("resource type %s is not supported in capture mode", resourceType)
 		}
-		logger.Info("Resource type is not supported for import; creating instead", "resourceType", resourceType)
-		return client.Create(ctx, in)
+		return resp, err
 	}
-	c := lookups.NewAwsLookups(i.CfnStackResources, i.Region, i.Account)
-	label := fmt.Sprintf("%s.Create(%s)", "aws-proxy", urn)
+	if err != nil {
+		return nil, err
+	}
+	i.report.resolved(string(urn), logical, string(l.CfnStackResources[logical].ResourceType), strategy, string(prim))
+	if i.mode == Verify {
+		return verifyResource(ctx, logger, i.report, in, client, string(prim), nil)
+	}
+
+	resp, err := i.read(ctx, logger, in, client, urn, inputs, string(logical.LogicalID), prim, label)
+	if err == nil && resp == nil {
+		return nil, fmt.Errorf("Don't have an ID!: %s %s %s", resourceType, string(prim), string(urn))
+	}
+	if err == nil {
+		l.Checkpoint.Imported(urn)
+	}
+	return resp, err
+}
+
+func (i *awsInterceptor) unmarshalInputs(in *pulumirpc.CreateRequest, label string) (resource.PropertyMap, error) {
 	inputs, err := plugin.UnmarshalProperties(in.GetProperties(), plugin.MarshalOptions{
 		Label:        fmt.Sprintf("%s.properties", label),
 		KeepUnknowns: true,
@@ -77,15 +151,23 @@ func (i *awsInterceptor) create(
 	if err != nil {
 		return nil, errors.Wrapf(err, "malformed resource inputs")
 	}
-	logical, err := c.FindLogicalResourceID(urn)
-	if err != nil {
-		return nil, err
-	}
-	prim, err := c.FindPrimaryResourceID(ctx, urn.Type(), logical, inputs.Mappable())
-	if err != nil {
-		return nil, err
-	}
+	return inputs, nil
+}
 
+// read imports the resource with the given ID, reporting how it differs from the program inputs.
+// The response is nil if the resource doesn't exist.
+func (i *awsInterceptor) read(
+	ctx context.Context,
+	logger *slog.Logger,
+	in *pulumirpc.CreateRequest,
+	client pulumirpc.ResourceProviderClient,
+	urn resource.URN,
+	inputs resource.PropertyMap,
+	logicalName string,
+	prim common.PrimaryResourceID,
+	label string,
+) (*pulumirpc.CreateResponse, error) {
+	resourceType := string(urn.Type())
 	logger.Debug("Importing resource", "resourceType", resourceType, "id", string(prim), "urn", string(urn))
 	rresp, err := client.Read(ctx, &pulumirpc.ReadRequest{
 		Id:  string(prim),
@@ -95,7 +177,18 @@ func (i *awsInterceptor) create(
 		return nil, fmt.Errorf("Import failed: %w", err)
 	}
 	if rresp.Id == "" {
-		return nil, fmt.Errorf("Don't have an ID!: %s %s %s", resourceType, string(prim), string(urn))
+		return nil, nil
+	}
+	outputs, err := plugin.UnmarshalProperties(rresp.GetProperties(), plugin.MarshalOptions{
+		Label:        fmt.Sprintf("%s.outputs", label),
+		KeepUnknowns: true,
+		RejectAssets: true,
+		KeepSecrets:  true,
+	})
+	if err != nil {
+		logger.Debug("Could not compare imported resource to program inputs", "urn", string(urn), "error", err)
+	} else {
+		reportDrift(logger, i.report, string(urn), computeDrift(inputs, outputs, nil))
 	}
 
 	if i.mode == CaptureImports && i.collector != nil {
@@ -103,7 +196,7 @@ func (i *awsInterceptor) create(
 		i.collector.Append(Capture{
 			Type:        resourceType,
 			Name:        string(urn.Name()),
-			LogicalName: string(logical),
+			LogicalName: logicalName,
 			ID:          string(prim),
 			Properties:  properties,
 		})
@@ -115,12 +208,80 @@ func (i *awsInterceptor) create(
 	}, nil
 }
 
+// bootstrapAssets returns the bootstrap asset storage of the provider's environment, or nil if the
+// bootstrap stack wasn't loaded.
+func (i *awsInterceptor) bootstrapAssets(ctx context.Context) *lookups.BootstrapAssets {
+	l, err := i.instances.lookups(ctx)
+	if err != nil || l == nil {
+		return nil
+	}
+	return l.Bootstrap
+}
+
+// importBootstrapAsset imports an asset bucket or repository resource against the asset storage of
+// the CDK bootstrap stack. The storage is shared by every CDK app in the account, so a resource the
+// bootstrap stack doesn't have (e.g. a lifecycle configuration) is skipped rather than created on it.
+func (i *awsInterceptor) importBootstrapAsset(
+	ctx context.Context,
+	logger *slog.Logger,
+	in *pulumirpc.CreateRequest,
+	client pulumirpc.ResourceProviderClient,
+	urn resource.URN,
+	asset lookups.BootstrapAsset,
+) (*pulumirpc.CreateResponse, error) {
+	label := fmt.Sprintf("%s.Create(%s)", "aws-proxy", urn)
+	inputs, err := i.unmarshalInputs(in, label)
+	if err != nil {
+		return nil, err
+	}
+	i.report.resolved(string(urn), asset.Key, string(asset.ResourceType), lookups.ResolutionBootstrap, string(asset.ID))
+	if i.mode == Verify {
+		return verifyResource(ctx, logger, i.report, in, client, string(asset.ID), nil)
+	}
+	resp, err := i.read(ctx, logger, in, client, urn, inputs, string(asset.Key.LogicalID), asset.ID, label)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil {
+		return i.skipBootstrapAsset(logger, in, urn, asset)
+	}
+	logger.Warn("Imported shared CDK bootstrap storage; set retainOnDelete and protect on this resource in the program, "+
+		"or destroying or replacing it will affect every CDK app in the account",
+		"urn", string(urn), "id", string(asset.ID), "bootstrapStack", string(asset.Key.StackName))
+	return resp, nil
+}
+
+// skipBootstrapAsset stubs out an asset resource the bootstrap stack doesn't have, since creating it
+// would change storage that other CDK apps rely on.
+func (i *awsInterceptor) skipBootstrapAsset(
+	logger *slog.Logger,
+	in *pulumirpc.CreateRequest,
+	urn resource.URN,
+	asset lookups.BootstrapAsset,
+) (*pulumirpc.CreateResponse, error) {
+	reason := fmt.Sprintf("bootstrap %s %s has no existing %s", asset.ResourceType, asset.ID, urn.Type().Name())
+	logger.Warn("Not creating asset resource on shared CDK bootstrap storage", "urn", string(urn), "reason", reason)
+	i.report.mark(string(urn), OutcomeSkipped)
+	if i.collector != nil {
+		i.collector.Skip(SkippedCapture{
+			Type:        string(urn.Type()),
+			LogicalName: string(urn.Name()),
+			Reason:      reason + "; it isn't created on shared bootstrap storage",
+		})
+	}
+	return &pulumirpc.CreateResponse{
+		Id:         fmt.Sprintf("skip-%s", string(urn.Name())),
+		Properties: in.GetProperties(),
+	}, nil
+}
+
 func (i *awsInterceptor) stubSkippedCreate(resourceType string, urn resource.URN, req *pulumirpc.CreateRequest) (*pulumirpc.CreateResponse, error) {
 	logger := i.logger
 	if logger == nil {
 		logger = slog.Default()
 	}
 	logger.Info("Skipping creation due to skip-create flag", "logicalName", string(urn.Name()), "resourceType", resourceType)
+	i.report.mark(string(urn), OutcomeSkipped)
 	if i.collector != nil {
 		i.collector.Skip(SkippedCapture{
 			Type:        resourceType,
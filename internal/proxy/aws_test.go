@@ -2,9 +2,17 @@ package proxy
 
 import (
 	"context"
+	"io"
+	"log/slog"
+	"reflect"
 	"testing"
 
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
 	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
 )
 
 func TestAWSInterceptorSkipCreate(t *testing.T) {
@@ -36,3 +44,246 @@ func TestAWSInterceptorSkipCreate(t *testing.T) {
 		t.Fatalf("unexpected reason: %s", summary.Skipped[0].Reason)
 	}
 }
+
+type readRecordingClient struct {
+	pulumirpc.ResourceProviderClient
+	readIDs []string
+}
+
+func (c *readRecordingClient) Read(_ context.Context, in *pulumirpc.ReadRequest, _ ...grpc.CallOption) (*pulumirpc.ReadResponse, error) {
+	c.readIDs = append(c.readIDs, in.GetId())
+	return &pulumirpc.ReadResponse{Id: in.GetId()}, nil
+}
+
+func TestAWSInterceptorUsesIDOverride(t *testing.T) {
+	t.Parallel()
+
+	overrides, err := lookups.NewIDOverrides(map[string]string{"Stack/JobsPolicy": "https://sqs.us-west-2.amazonaws.com/123456789012/jobs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	interceptor := &awsInterceptor{
+		instances: singleEnvironment(&lookups.Lookups{
+			Region:  "us-west-2",
+			Account: "123456789012",
+			CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+				{StackName: "Stack", LogicalID: "JobsPolicy"}: {
+					ResourceType: "AWS::SQS::QueuePolicy",
+					PhysicalID:   "wrong-id",
+					LogicalID:    "JobsPolicy",
+					StackName:    "Stack",
+				},
+			},
+			IDOverrides: overrides,
+		}),
+		mode:   RunPulumi,
+		report: NewReportRecorder(),
+	}
+	client := &readRecordingClient{}
+
+	req := &pulumirpc.CreateRequest{
+		Urn: "urn:pulumi:test::proj::aws:sqs/queuePolicy:QueuePolicy::JobsPolicy",
+	}
+	resp, err := interceptor.create(context.Background(), req, client)
+	if err != nil {
+		t.Fatalf("expected no error: %v", err)
+	}
+	want := "https://sqs.us-west-2.amazonaws.com/123456789012/jobs"
+	if resp.Id != want || len(client.readIDs) != 1 || client.readIDs[0] != want {
+		t.Fatalf("expected import of %q, got response %q and reads %v", want, resp.Id, client.readIDs)
+	}
+	report := interceptor.report.Build([]string{"Stack"}, "succeeded")
+	if len(report.Resources) != 1 || report.Resources[0].Strategy != lookups.ResolutionOverride {
+		t.Fatalf("expected override strategy in report, got %#v", report.Resources)
+	}
+}
+
+// policyClient creates managed policies with a fixed ARN and imports anything it is asked to read.
+type policyClient struct {
+	readRecordingClient
+	created []string
+}
+
+func (c *policyClient) Create(_ context.Context, in *pulumirpc.CreateRequest, _ ...grpc.CallOption) (*pulumirpc.CreateResponse, error) {
+	c.created = append(c.created, in.GetUrn())
+	props, err := structpb.NewStruct(map[string]any{"arn": "arn:aws:iam::123456789012:policy/Created"})
+	if err != nil {
+		return nil, err
+	}
+	return &pulumirpc.CreateResponse{Id: "created", Properties: props}, nil
+}
+
+func TestAWSInterceptorIAMPolicies(t *testing.T) {
+	t.Parallel()
+
+	interceptor := &awsInterceptor{
+		instances: singleEnvironment(&lookups.Lookups{
+			Region:  "us-west-2",
+			Account: "123456789012",
+			CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+				{StackName: "Stack", LogicalID: "InlinePolicy"}: {
+					ResourceType: "AWS::IAM::Policy",
+					PhysicalID:   "Stack-Inlin-1ABC",
+					LogicalID:    "InlinePolicy",
+					StackName:    "Stack",
+				},
+				{StackName: "Stack", LogicalID: "NamedPolicy"}: {
+					ResourceType: "AWS::IAM::Policy",
+					PhysicalID:   "Stack-Named-2DEF",
+					LogicalID:    "NamedPolicy",
+					StackName:    "Stack",
+				},
+			},
+		}),
+		mode:   RunPulumi,
+		report: NewReportRecorder(),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	client := &policyClient{}
+	create := func(urn string, props map[string]any) *pulumirpc.CreateResponse {
+		t.Helper()
+		s, err := structpb.NewStruct(props)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := interceptor.create(context.Background(), &pulumirpc.CreateRequest{Urn: urn, Properties: s}, client)
+		if err != nil {
+			t.Fatalf("%s: %v", urn, err)
+		}
+		return resp
+	}
+
+	create("urn:pulumi:test::proj::aws:iam/policy:Policy::InlinePolicy", map[string]any{"policy": "{}"})
+	create("urn:pulumi:test::proj::aws:iam/rolePolicyAttachment:RolePolicyAttachment::InlinePolicy-Role",
+		map[string]any{"role": "Role", "policyArn": "arn:aws:iam::123456789012:policy/Created"})
+	if len(client.created) != 2 || len(client.readIDs) != 0 {
+		t.Fatalf("expected the inline policy and its attachment to be created, got creates %v and reads %v", client.created, client.readIDs)
+	}
+
+	// The policy name doesn't make it a managed policy: CloudFormation still deploys it inline.
+	create("urn:pulumi:test::proj::aws:iam/policy:Policy::NamedPolicy", map[string]any{"name": "shared"})
+	if len(client.created) != 3 || len(client.readIDs) != 0 {
+		t.Fatalf("expected the named policy to be created, got creates %v and reads %v", client.created, client.readIDs)
+	}
+
+	create("urn:pulumi:test::proj::aws:iam/rolePolicy:RolePolicy::NamedPolicy-Role",
+		map[string]any{"role": "Role", "name": "shared", "policy": "{}"})
+	want := []string{"Role:shared"}
+	if !reflect.DeepEqual(client.readIDs, want) || len(client.created) != 3 {
+		t.Fatalf("expected the inline policy to be imported as %v, got reads %v and creates %v", want, client.readIDs, client.created)
+	}
+}
+
+func TestAWSInterceptorIAMPoliciesSkipCreate(t *testing.T) {
+	t.Parallel()
+
+	interceptor := &awsInterceptor{
+		instances: singleEnvironment(&lookups.Lookups{
+			Region:  "us-west-2",
+			Account: "123456789012",
+			CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+				{StackName: "Stack", LogicalID: "InlinePolicy"}: {
+					ResourceType: "AWS::IAM::Policy",
+					PhysicalID:   "Stack-Inlin-1ABC",
+					LogicalID:    "InlinePolicy",
+					StackName:    "Stack",
+				},
+			},
+		}),
+		mode:       RunPulumi,
+		skipCreate: true,
+		report:     NewReportRecorder(),
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	client := &policyClient{}
+	create := func(urn string, props map[string]any) *pulumirpc.CreateResponse {
+		t.Helper()
+		s, err := structpb.NewStruct(props)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := interceptor.create(context.Background(), &pulumirpc.CreateRequest{Urn: urn, Properties: s}, client)
+		if err != nil {
+			t.Fatalf("%s: %v", urn, err)
+		}
+		return resp
+	}
+
+	policy := create("urn:pulumi:test::proj::aws:iam/policy:Policy::InlinePolicy", map[string]any{"policy": "{}"})
+	if _, ok := policy.GetProperties().GetFields()["arn"]; ok {
+		t.Fatalf("expected the skipped policy to have no arn, got %v", policy.GetProperties())
+	}
+	// The stubbed policy has no ARN to pass on to its attachment.
+	attachment := create("urn:pulumi:test::proj::aws:iam/rolePolicyAttachment:RolePolicyAttachment::InlinePolicy-Role",
+		map[string]any{"role": "Role"})
+	if len(client.readIDs) != 0 || len(client.created) != 0 {
+		t.Fatalf("expected the policy and its attachment to be skipped, got reads %v and creates %v", client.readIDs, client.created)
+	}
+	if attachment.GetId() != "skip-InlinePolicy-Role" {
+		t.Fatalf("expected a skipped attachment stub, got %q", attachment.GetId())
+	}
+}
+
+// bootstrapClient only finds the resource types in existing when reading.
+type bootstrapClient struct {
+	pulumirpc.ResourceProviderClient
+	existing map[string]bool
+	reads    []string
+	created  []string
+}
+
+func (c *bootstrapClient) Read(_ context.Context, in *pulumirpc.ReadRequest, _ ...grpc.CallOption) (*pulumirpc.ReadResponse, error) {
+	urn := resource.URN(in.GetUrn())
+	c.reads = append(c.reads, string(urn.Name()))
+	if !c.existing[string(urn.Type())] {
+		return &pulumirpc.ReadResponse{}, nil
+	}
+	return &pulumirpc.ReadResponse{Id: in.GetId()}, nil
+}
+
+func (c *bootstrapClient) Create(_ context.Context, in *pulumirpc.CreateRequest, _ ...grpc.CallOption) (*pulumirpc.CreateResponse, error) {
+	c.created = append(c.created, string(resource.URN(in.GetUrn()).Name()))
+	return &pulumirpc.CreateResponse{Id: "created"}, nil
+}
+
+func TestAWSInterceptorImportsBootstrapAssets(t *testing.T) {
+	t.Parallel()
+
+	interceptor := &awsInterceptor{
+		instances: singleEnvironment(&lookups.Lookups{
+			Bootstrap: &lookups.BootstrapAssets{Bucket: lookups.BootstrapAsset{
+				Key:          lookups.StackResourceKey{StackName: "CDKToolkit", LogicalID: "StagingBucket"},
+				ResourceType: "AWS::S3::Bucket",
+				ID:           "cdk-assets",
+			}},
+		}),
+		mode:   RunPulumi,
+		report: NewReportRecorder(),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	client := &bootstrapClient{existing: map[string]bool{"aws:s3/bucketV2:BucketV2": true}}
+	create := interceptor.report.wrapCreate(interceptor.create)
+	for _, urn := range []string{
+		"urn:pulumi:test::proj::aws:s3/bucketV2:BucketV2::staging",
+		"urn:pulumi:test::proj::aws:s3/bucketV2:BucketV2::other-staging",
+		"urn:pulumi:test::proj::aws:s3/bucketLifecycleConfigurationV2:BucketLifecycleConfigurationV2::lifecycle",
+		"urn:pulumi:test::proj::aws:ecr/repository:Repository::repo",
+	} {
+		if _, err := create(context.Background(), &pulumirpc.CreateRequest{Urn: urn}, client); err != nil {
+			t.Fatalf("%s: %v", urn, err)
+		}
+	}
+
+	if !reflect.DeepEqual(client.reads, []string{"staging", "lifecycle"}) {
+		t.Fatalf("expected the bootstrap bucket to be read once per resource type, got %v", client.reads)
+	}
+	// The bootstrap bucket has no lifecycle configuration, and creating one would change storage
+	// shared by every CDK app in the account.
+	if !reflect.DeepEqual(client.created, []string{"other-staging", "repo"}) {
+		t.Fatalf("unexpected creates: %v", client.created)
+	}
+	report := interceptor.report.Build([]string{"Stack"}, "succeeded")
+	if report.Summary.Imported != 1 || report.Summary.Created != 2 || report.Summary.Skipped != 1 {
+		t.Fatalf("unexpected summary: %#v", report.Summary)
+	}
+}
@@ -16,9 +16,10 @@ import (
 )
 
 type awsCCApiInterceptor struct {
-	*lookups.Lookups
+	instances *providerInstances
 	mode      RunMode
 	collector *CaptureCollector
+	report    *ReportRecorder
 	logger    *slog.Logger
 }
 
@@ -31,7 +32,11 @@ func (i *awsCCApiInterceptor) create(
 	if logger == nil {
 		logger = slog.Default() // Consider if a panic/error is more appropriate if logger is expected to be non-nil.
 	}
-	c, err := lookups.NewCCApiLookups(ctx, i.CCAPIClient, i.CfnStackResources, i.Region, i.Account, i.EventsClient)
+	l, err := i.instances.lookups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c, err := lookups.NewCCApiLookups(ctx, l.CCAPIClient, l.CfnStackResources, l.Region, l.Account, l.EventsClient, l.CCAPICache)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create API Client for CCAPI: %w", err)
 	}
@@ -42,15 +47,8 @@ func (i *awsCCApiInterceptor) create(
 	label := fmt.Sprintf("%s.Create(%s)", "aws-native-proxy", urn)
 	resourceToken := string(urn.Type())
 
-	if resourceToken == "aws-native:cloudformation:CustomResourceEmulator" {
-		if i.collector != nil {
-			i.collector.Skip(SkippedCapture{
-				Type:        resourceToken,
-				LogicalName: string(urn.Name()),
-				Reason:      "resource type not supported for capture",
-			})
-		}
-		return nil, errors.New("Custom Resources cannot be imported")
+	if resourceToken == lookups.CustomResourceEmulatorToken {
+		return i.importCustomResource(ctx, logger, l, in, urn, label)
 	}
 
 	inputs, err := plugin.UnmarshalProperties(in.GetProperties(), plugin.MarshalOptions{
@@ -69,14 +67,18 @@ func (i *awsCCApiInterceptor) create(
 		return nil, err
 	}
 
-	// find the corresponding CloudFormation resource
-	logical, err := c.FindLogicalResourceID(urn)
+	// find the corresponding CloudFormation resource, unless the user supplied the ID
+	logical, prim, strategy, err := l.Checkpoint.ResolveImportID(ctx, c, l.IDOverrides, urn, props)
 	if err != nil {
 		return nil, err
 	}
-	prim, err := c.FindPrimaryResourceID(ctx, urn.Type(), logical, props)
-	if err != nil {
-		return nil, err
+	i.report.resolved(string(urn), logical, string(l.CfnStackResources[logical].ResourceType), strategy, string(prim))
+	if i.mode == Verify {
+		spec, err := awsNativeMetadata.Resource(resourceToken)
+		if err != nil {
+			return nil, err
+		}
+		return verifyResource(ctx, logger, i.report, in, client, string(prim), spec.WriteOnly)
 	}
 	logger.Debug("Importing resource", "resourceType", urn.Type().String(), "id", string(prim), "urn", string(urn))
 	if i.mode == CaptureImports && i.collector != nil {
@@ -84,7 +86,7 @@ func (i *awsCCApiInterceptor) create(
 		i.collector.Append(Capture{
 			Type:        string(urn.Type()),
 			Name:        string(urn.Name()),
-			LogicalName: string(logical),
+			LogicalName: string(logical.LogicalID),
 			ID:          string(prim),
 			Properties:  properties,
 		})
@@ -96,6 +98,9 @@ func (i *awsCCApiInterceptor) create(
 	if err != nil {
 		return nil, fmt.Errorf("Import failed: %w (props: %v)", err, props)
 	}
+	if rresp.GetId() != "" {
+		l.Checkpoint.Imported(urn)
+	}
 
 	spec, err := awsNativeMetadata.Resource(resourceToken)
 	if err != nil {
@@ -107,6 +112,10 @@ func (i *awsCCApiInterceptor) create(
 		RejectAssets: true,
 		KeepSecrets:  true,
 	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "malformed resource outputs")
+	}
+	reportDrift(logger, i.report, string(urn), computeDrift(inputs, outputs, spec.WriteOnly))
 	rawOutputs := outputs.Mappable()
 	// Write-only properties are not returned in the outputs, so we assume they should have the same value we sent from the inputs.
 	if len(spec.WriteOnly) > 0 {
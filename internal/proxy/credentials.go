@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+// providerCredentialConfig renders creds as the config of the aws and aws-native providers.
+func providerCredentialConfig(creds lookups.Credentials) (auto.ConfigMap, error) {
+	cfg := auto.ConfigMap{}
+	if creds.Profile != "" {
+		cfg[aws+":profile"] = auto.ConfigValue{Value: creds.Profile}
+		cfg[awsCCApi+":profile"] = auto.ConfigValue{Value: creds.Profile}
+	}
+	if creds.RoleARN == "" {
+		return cfg, nil
+	}
+	awsRole := map[string]any{"roleArn": creds.RoleARN, "sessionName": lookups.RoleSessionName}
+	nativeRole := map[string]any{"roleArn": creds.RoleARN, "sessionName": lookups.RoleSessionName}
+	if creds.ExternalID != "" {
+		awsRole["externalId"] = creds.ExternalID
+		nativeRole["externalId"] = creds.ExternalID
+	}
+	if creds.SessionDuration > 0 {
+		awsRole["duration"] = creds.SessionDuration.String()
+		nativeRole["durationSeconds"] = int(creds.SessionDuration.Seconds())
+	}
+	awsValue, err := json.Marshal([]any{awsRole})
+	if err != nil {
+		return nil, err
+	}
+	nativeValue, err := json.Marshal(nativeRole)
+	if err != nil {
+		return nil, err
+	}
+	cfg[aws+":assumeRoles"] = auto.ConfigValue{Value: string(awsValue)}
+	cfg[awsCCApi+":assumeRole"] = auto.ConfigValue{Value: string(nativeValue)}
+	return cfg, nil
+}
+
+// setProviderCredentials makes the providers of a capture or verify stack use the importer's
+// credentials, overriding any copied stack config.
+func setProviderCredentials(ctx context.Context, logger *slog.Logger, stack auto.Stack, creds lookups.Credentials) error {
+	cfg, err := providerCredentialConfig(creds)
+	if err != nil || len(cfg) == 0 {
+		return err
+	}
+	if err := stack.SetAllConfig(ctx, cfg); err != nil {
+		return fmt.Errorf("failed to set provider credentials: %w", err)
+	}
+	logger.Info("Configured providers with the importer's credentials", "profile", creds.Profile, "roleArn", creds.RoleARN)
+	return nil
+}
+
+// providerCredentials reads the region and credentials a provider is configured with from stack
+// config. Only the first role of `aws:assumeRoles` is used.
+func providerCredentials(pkg string, cfg auto.ConfigMap) (lookups.Environment, lookups.Credentials, error) {
+	env := lookups.Environment{Region: cfg[pkg+":region"].Value}
+	creds := lookups.Credentials{Profile: cfg[pkg+":profile"].Value}
+	var role map[string]any
+	switch pkg {
+	case aws:
+		if v := cfg[aws+":assumeRoles"].Value; v != "" {
+			var roles []map[string]any
+			if err := json.Unmarshal([]byte(v), &roles); err != nil {
+				return env, creds, fmt.Errorf("invalid %s:assumeRoles config: %w", aws, err)
+			}
+			if len(roles) > 0 {
+				role = roles[0]
+			}
+		}
+	case awsCCApi:
+		if v := cfg[awsCCApi+":assumeRole"].Value; v != "" {
+			if err := json.Unmarshal([]byte(v), &role); err != nil {
+				return env, creds, fmt.Errorf("invalid %s:assumeRole config: %w", awsCCApi, err)
+			}
+		}
+	}
+	creds.RoleARN, _ = role["roleArn"].(string)
+	creds.ExternalID, _ = role["externalId"].(string)
+	return env, creds, nil
+}
+
+// checkProviderAccounts fails when the aws or aws-native provider config in cfg resolves to another
+// account than the stacks of the provider's region are read from. Providers whose account can't be
+// resolved are only logged.
+func checkProviderAccounts(
+	ctx context.Context,
+	logger *slog.Logger,
+	cfg auto.ConfigMap,
+	envs *lookups.Environments,
+	resolve func(context.Context, lookups.Environment, lookups.Credentials) (string, error),
+) error {
+	for _, pkg := range []string{aws, awsCCApi} {
+		env, creds, err := providerCredentials(pkg, cfg)
+		if err != nil {
+			logger.Warn("Could not read provider credentials from stack config", "provider", pkg, "error", err)
+			continue
+		}
+		want, err := envs.Select(env.Region, creds.Profile)
+		if err != nil {
+			logger.Warn("Could not match provider to the environment of a stack", "provider", pkg, "error", err)
+			continue
+		}
+		got, err := resolve(ctx, env, creds)
+		if err != nil {
+			logger.Warn("Could not resolve the account of the provider credentials", "provider", pkg, "error", err)
+			continue
+		}
+		if got != want.Account {
+			return fmt.Errorf("The %s provider resolves to account %s, but the stacks are read from account %s; use the same credentials for both (e.g. with --profile or --role-arn)",
+				pkg, got, want.Account)
+		}
+		logger.Debug("Provider credentials resolve to the stacks' account", "provider", pkg, "account", got)
+	}
+	return nil
+}
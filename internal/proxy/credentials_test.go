@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+)
+
+func TestProviderCredentialConfig(t *testing.T) {
+	t.Parallel()
+
+	creds := lookups.Credentials{
+		Profile:         "migration",
+		RoleARN:         "arn:aws:iam::123456789012:role/importer",
+		ExternalID:      "ext-1",
+		SessionDuration: time.Hour,
+	}
+	cfg, err := providerCredentialConfig(creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg["aws:assumeRoles"].Value; got != `[{"duration":"1h0m0s","externalId":"ext-1","roleArn":"arn:aws:iam::123456789012:role/importer","sessionName":"pulumi-cdk-importer"}]` {
+		t.Fatalf("unexpected aws:assumeRoles: %s", got)
+	}
+	if got := cfg["aws-native:assumeRole"].Value; got != `{"durationSeconds":3600,"externalId":"ext-1","roleArn":"arn:aws:iam::123456789012:role/importer","sessionName":"pulumi-cdk-importer"}` {
+		t.Fatalf("unexpected aws-native:assumeRole: %s", got)
+	}
+
+	for _, pkg := range []string{aws, awsCCApi} {
+		_, got, err := providerCredentials(pkg, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := creds
+		want.SessionDuration = 0
+		if got != want {
+			t.Fatalf("%s: credentials don't round-trip: %#v", pkg, got)
+		}
+	}
+
+	cfg, err = providerCredentialConfig(lookups.Credentials{})
+	if err != nil || len(cfg) != 0 {
+		t.Fatalf("expected no provider config without credentials, got %v, %v", cfg, err)
+	}
+}
+
+func TestCheckProviderAccounts(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	envs := lookups.NewEnvironments(lookups.NewLookups("us-west-2", "123456789012", nil, nil, nil))
+	accounts := map[string]string{"": "123456789012", "other": "210987654321"}
+	resolve := func(_ context.Context, _ lookups.Environment, creds lookups.Credentials) (string, error) {
+		return accounts[creds.Profile], nil
+	}
+
+	if err := checkProviderAccounts(context.Background(), logger, auto.ConfigMap{}, envs, resolve); err != nil {
+		t.Fatalf("expected matching accounts to pass, got %v", err)
+	}
+
+	err := checkProviderAccounts(context.Background(), logger, auto.ConfigMap{
+		"aws:profile": auto.ConfigValue{Value: "other"},
+	}, envs, resolve)
+	if err == nil || !strings.Contains(err.Error(), "The aws provider resolves to account 210987654321") {
+		t.Fatalf("expected an account mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/pkg/errors"
+	nResources "github.com/pulumi/pulumi-aws-native/provider/pkg/resources"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+)
+
+// importCustomResource imports a CDK custom resource without invoking its handler. The emulator's
+// Read can't import (custom resources keep no state of their own), so the state is built from the
+// deployed CloudFormation resource instead. CloudFormation doesn't store the handler's response
+// data, so `data` only has the attributes the stack exposes as outputs.
+func (i *awsCCApiInterceptor) importCustomResource(
+	ctx context.Context,
+	logger *slog.Logger,
+	l *lookups.Lookups,
+	in *pulumirpc.CreateRequest,
+	urn resource.URN,
+	label string,
+) (*pulumirpc.CreateResponse, error) {
+	if i.mode == CaptureImports && i.collector != nil {
+		i.collector.Skip(SkippedCapture{
+			Type:        string(urn.Type()),
+			LogicalName: string(urn.Name()),
+			Reason:      "custom resources can't be imported from an import file",
+		})
+	}
+
+	inputs, err := plugin.UnmarshalProperties(in.GetProperties(), plugin.MarshalOptions{
+		Label:        fmt.Sprintf("%s.properties", label),
+		KeepUnknowns: true,
+		RejectAssets: true,
+		KeepSecrets:  true,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "malformed resource inputs")
+	}
+	resourceType := stringInput(inputs, "resourceType")
+	if resourceType == "" {
+		return nil, fmt.Errorf("Custom resource %s has no resourceType", urn.Name())
+	}
+
+	resolver := l.CustomResourceResolver(common.ResourceType(resourceType))
+	logical, prim, strategy, err := l.Checkpoint.ResolveImportID(ctx, resolver, l.IDOverrides, urn, nil)
+	if err != nil {
+		return nil, err
+	}
+	i.report.resolved(string(urn), logical, resourceType, strategy, string(prim))
+
+	stackID := stringInput(inputs, "stackId")
+	if logical.LogicalID != "" {
+		deployed, err := l.DescribeCustomResource(ctx, logical)
+		if err != nil {
+			return nil, err
+		}
+		if stackID == "" {
+			stackID = deployed.StackID
+		}
+	}
+	if i.mode == Verify {
+		i.report.verified(string(urn), nil, nil)
+		return verifyStub(in, string(prim)), nil
+	}
+
+	data := map[string]any{}
+	if logical.LogicalID == "" {
+		logger.Warn("Imported custom resource without its response data; attributes read from it will be empty until it is updated",
+			"urn", string(urn), "id", string(prim), "resourceType", resourceType)
+	} else if recovered, missing, err := l.CustomResourceData(ctx, logical); err != nil {
+		logger.Warn("Could not recover the response data of custom resource; attributes read from it will be empty until it is updated",
+			"urn", string(urn), "id", string(prim), "resourceType", resourceType, "error", err)
+	} else {
+		data = recovered
+		if len(missing) > 0 {
+			logger.Warn("Custom resource attributes aren't stack outputs, so they can't be recovered and will be empty until the resource is updated",
+				"urn", string(urn), "id", string(prim), "resourceType", resourceType, "attributes", missing)
+		}
+	}
+
+	state := nResources.CfnCustomResourceState{
+		PhysicalResourceID: string(prim),
+		Data:               data,
+		StackID:            stackID,
+		ServiceToken:       stringInput(inputs, "serviceToken"),
+		Bucket:             stringInput(inputs, "bucketName"),
+		ResourceType:       resourceType,
+	}
+	checkpoint, err := plugin.MarshalProperties(nResources.CheckpointPropertyMap(inputs, state.ToPropertyMap()), plugin.MarshalOptions{
+		Label:        fmt.Sprintf("%s.outputs", label),
+		KeepUnknowns: true,
+		KeepSecrets:  true,
+		RejectAssets: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.Checkpoint.Imported(urn)
+	return &pulumirpc.CreateResponse{
+		Id:         string(prim),
+		Properties: checkpoint,
+	}, nil
+}
+
+// stringInput returns the string value of a known input, or "" if it is missing or not a string.
+func stringInput(inputs resource.PropertyMap, key resource.PropertyKey) string {
+	v, _, known := unwrapPropertyValue(inputs[key])
+	if !known || !v.IsString() {
+		return ""
+	}
+	return v.StringValue()
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfntypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+)
+
+// seedTemplate outputs the TableName attribute of the custom resource, but only passes its Secret
+// attribute to another resource.
+const seedTemplate = `{
+  "Resources": {
+    "SeedData1234": {"Type": "Custom::SeedData"},
+    "Consumer": {"Type": "AWS::SSM::Parameter", "Properties": {"Value": {"Fn::GetAtt": ["SeedData1234", "Secret"]}}}
+  },
+  "Outputs": {
+    "SeedTable": {"Value": {"Fn::GetAtt": "SeedData1234.TableName"}}
+  }
+}`
+
+// describeCloudFormation answers DescribeStackResource for a single deployed custom resource.
+type describeCloudFormation struct {
+	lookups.CloudFormationAPI
+	status cfntypes.ResourceStatus
+}
+
+func (c describeCloudFormation) DescribeStackResource(_ context.Context, params *cloudformation.DescribeStackResourceInput, _ ...func(*cloudformation.Options)) (*cloudformation.DescribeStackResourceOutput, error) {
+	return &cloudformation.DescribeStackResourceOutput{StackResourceDetail: &cfntypes.StackResourceDetail{
+		LogicalResourceId:  params.LogicalResourceId,
+		PhysicalResourceId: awssdk.String("Stack-Seed-1A2B3C"),
+		StackId:            awssdk.String("arn:aws:cloudformation:us-west-2:123456789012:stack/Stack/guid"),
+		ResourceStatus:     c.status,
+	}}, nil
+}
+
+func (c describeCloudFormation) GetTemplate(context.Context, *cloudformation.GetTemplateInput, ...func(*cloudformation.Options)) (*cloudformation.GetTemplateOutput, error) {
+	return &cloudformation.GetTemplateOutput{TemplateBody: awssdk.String(seedTemplate)}, nil
+}
+
+func (c describeCloudFormation) DescribeStacks(context.Context, *cloudformation.DescribeStacksInput, ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	return &cloudformation.DescribeStacksOutput{Stacks: []cfntypes.Stack{{Outputs: []cfntypes.Output{
+		{OutputKey: awssdk.String("SeedTable"), OutputValue: awssdk.String("jobs-table")},
+	}}}}, nil
+}
+
+func customResourceInterceptor(mode RunMode, status cfntypes.ResourceStatus) *awsCCApiInterceptor {
+	return &awsCCApiInterceptor{
+		instances: singleEnvironment(&lookups.Lookups{
+			Region:    "us-west-2",
+			Account:   "123456789012",
+			CfnClient: describeCloudFormation{status: status},
+			CfnStackResources: map[lookups.StackResourceKey]lookups.CfnStackResource{
+				{StackName: "Stack", LogicalID: "SeedData1234"}: {
+					ResourceType: "Custom::SeedData",
+					PhysicalID:   "Stack-Seed-1A2B3C",
+					LogicalID:    "SeedData1234",
+					StackName:    "Stack",
+				},
+			},
+			CCAPICache: lookups.NewResourceCache(),
+		}),
+		mode:   mode,
+		report: NewReportRecorder(),
+		logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func customResourceRequest(t *testing.T) *pulumirpc.CreateRequest {
+	t.Helper()
+	props, err := plugin.MarshalProperties(resource.PropertyMap{
+		"resourceType":    resource.NewStringProperty("Custom::SeedData"),
+		"serviceToken":    resource.NewStringProperty("arn:aws:lambda:us-west-2:123456789012:function:seed"),
+		"bucketName":      resource.NewStringProperty("pulumi-cdk-responses"),
+		"bucketKeyPrefix": resource.NewStringProperty("seed"),
+		"stackId":         resource.NewStringProperty("dev"),
+		"customResourceProperties": resource.NewObjectProperty(resource.PropertyMap{
+			"table": resource.NewStringProperty("jobs"),
+		}),
+	}, plugin.MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pulumirpc.CreateRequest{
+		Urn:        "urn:pulumi:dev::proj::aws-native:cloudformation:CustomResourceEmulator::SeedData",
+		Properties: props,
+	}
+}
+
+func TestCCApiInterceptorImportsCustomResource(t *testing.T) {
+	t.Parallel()
+
+	interceptor := customResourceInterceptor(RunPulumi, cfntypes.ResourceStatusCreateComplete)
+	var logs bytes.Buffer
+	interceptor.logger = slog.New(slog.NewTextHandler(&logs, nil))
+	// A nil client panics if the interceptor tries to invoke the emulator.
+	resp, err := interceptor.create(context.Background(), customResourceRequest(t), nil)
+	if err != nil {
+		t.Fatalf("expected no error: %v", err)
+	}
+	if resp.Id != "Stack-Seed-1A2B3C" {
+		t.Fatalf("expected the physical ID as import ID, got %q", resp.Id)
+	}
+	state, err := plugin.UnmarshalProperties(resp.Properties, plugin.MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[resource.PropertyKey]string{
+		"physicalResourceId": "Stack-Seed-1A2B3C",
+		"stackId":            "dev",
+		"serviceToken":       "arn:aws:lambda:us-west-2:123456789012:function:seed",
+		"bucket":             "pulumi-cdk-responses",
+		"resourceType":       "Custom::SeedData",
+	} {
+		if got := state[key]; !got.IsString() || got.StringValue() != want {
+			t.Fatalf("expected %s=%q in state, got %v", key, want, got)
+		}
+	}
+	if data := state["data"]; !data.IsObject() || !reflect.DeepEqual(data.ObjectValue().Mappable(), map[string]any{"TableName": "jobs-table"}) {
+		t.Fatalf("expected the output attribute to be recovered, got %v", data)
+	}
+	if !strings.Contains(logs.String(), "attributes=[Secret]") {
+		t.Fatalf("expected a warning about the unrecovered attribute, got %q", logs.String())
+	}
+	if _, ok := state["__inputs"]; !ok {
+		t.Fatalf("expected inputs to be checkpointed, got %v", state)
+	}
+	report := interceptor.report.Build([]string{"Stack"}, "succeeded")
+	if len(report.Resources) != 1 || report.Resources[0].LogicalID != "SeedData1234" || report.Resources[0].Strategy != lookups.ResolutionPhysicalID {
+		t.Fatalf("unexpected report: %#v", report.Resources)
+	}
+}
+
+func TestCCApiInterceptorRejectsFailedCustomResource(t *testing.T) {
+	t.Parallel()
+
+	interceptor := customResourceInterceptor(RunPulumi, cfntypes.ResourceStatusCreateFailed)
+	if _, err := interceptor.create(context.Background(), customResourceRequest(t), nil); err == nil {
+		t.Fatal("expected a custom resource that failed to create to be rejected")
+	}
+}
@@ -2,16 +2,175 @@ package proxy
 
 import (
 	"context"
+	"fmt"
+	"log/slog"
 
+	"github.com/pkg/errors"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
 	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
 )
 
-type dockerInterceptor struct{}
+type dockerInterceptor struct {
+	envs       *lookups.Environments
+	mode       RunMode
+	skipCreate bool
+	collector  *CaptureCollector
+	report     *ReportRecorder
+	logger     *slog.Logger
+}
 
 func (i *dockerInterceptor) create(
 	ctx context.Context,
 	in *pulumirpc.CreateRequest,
 	client pulumirpc.ResourceProviderClient,
 ) (*pulumirpc.CreateResponse, error) {
+	urn, err := resource.ParseURN(in.GetUrn())
+	if err != nil {
+		return nil, err
+	}
+	if i.mode == Verify {
+		// Images are built and pushed rather than imported, so there is nothing to verify.
+		i.logger.Info("Docker images are built, not imported; skipping verification", "name", string(urn.Name()))
+		i.report.mark(string(urn), OutcomeSkipped)
+		return verifyStub(in, ""), nil
+	}
+
+	label := fmt.Sprintf("%s.Create(%s)", "docker-build-proxy", urn)
+	inputs, err := plugin.UnmarshalProperties(in.GetProperties(), plugin.MarshalOptions{
+		Label:        fmt.Sprintf("%s.properties", label),
+		KeepUnknowns: true,
+		KeepSecrets:  true,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "malformed resource inputs")
+	}
+	resp, err := i.existingImage(ctx, urn, inputs, label)
+	if err != nil || resp != nil {
+		return resp, err
+	}
+
+	if i.skipCreate {
+		i.logger.Info("Skipping image build due to skip-create flag", "name", string(urn.Name()))
+		i.report.mark(string(urn), OutcomeSkipped)
+		if i.collector != nil {
+			i.collector.Skip(SkippedCapture{
+				Type:        string(urn.Type()),
+				LogicalName: string(urn.Name()),
+				Reason:      "image not found in ECR and build skipped via -skip-create",
+			})
+		}
+		return &pulumirpc.CreateResponse{
+			Id:         fmt.Sprintf("skip-%s", string(urn.Name())),
+			Properties: in.GetProperties(),
+		}, nil
+	}
+	i.report.mark(in.GetUrn(), OutcomeCreated)
 	return client.Create(ctx, in)
 }
+
+// existingImage returns the state of an image whose ECR tag was already pushed by `cdk deploy`, so
+// the image doesn't have to be built again. The response is nil if no pushed image is found.
+func (i *dockerInterceptor) existingImage(
+	ctx context.Context,
+	urn resource.URN,
+	inputs resource.PropertyMap,
+	label string,
+) (*pulumirpc.CreateResponse, error) {
+	if push := inputs["push"]; !push.IsBool() || !push.BoolValue() {
+		return nil, nil
+	}
+	for _, tag := range imageTags(inputs) {
+		l := i.environment(tag)
+		if l == nil {
+			i.logger.Debug("No stack environment matches image registry", "name", string(urn.Name()), "tag", tag.String())
+			continue
+		}
+		candidates := []lookups.ImageTag{tag}
+		// The pulumi-cdk repository may not be the one `cdk deploy` pushed to, but asset hashes are
+		// the same, so look for the tag in the bootstrap repository too.
+		if repo, ok := l.Bootstrap.ForToken("aws:ecr/repository:Repository"); ok && string(repo.ID) != tag.Repository {
+			bootstrapTag := tag
+			bootstrapTag.Repository = string(repo.ID)
+			candidates = append(candidates, bootstrapTag)
+		}
+		for _, candidate := range candidates {
+			digest, err := l.FindImageDigest(ctx, candidate)
+			if errors.Is(err, lookups.ErrImageNotFound) {
+				i.logger.Debug("Image not found in ECR", "name", string(urn.Name()), "tag", candidate.String())
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return i.imageState(urn, inputs, candidate, digest, label)
+		}
+	}
+	return nil, nil
+}
+
+// imageState renders the outputs of the docker-build image for a pushed image digest.
+func (i *dockerInterceptor) imageState(
+	urn resource.URN,
+	inputs resource.PropertyMap,
+	tag lookups.ImageTag,
+	digest string,
+	label string,
+) (*pulumirpc.CreateResponse, error) {
+	ref := tag.Ref(digest)
+	outputs := inputs.Copy()
+	outputs["digest"] = resource.NewStringProperty(digest)
+	outputs["ref"] = resource.NewStringProperty(ref)
+	properties, err := plugin.MarshalProperties(outputs, plugin.MarshalOptions{
+		Label:        fmt.Sprintf("%s.outputs", label),
+		KeepUnknowns: true,
+		KeepSecrets:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	i.logger.Info("Using existing image instead of building it", "name", string(urn.Name()), "ref", ref)
+	if i.mode == CaptureImports && i.collector != nil {
+		i.collector.Skip(SkippedCapture{
+			Type:        string(urn.Type()),
+			LogicalName: string(urn.Name()),
+			Reason:      "images are not imported; the existing image " + ref + " is referenced instead",
+		})
+	}
+	return &pulumirpc.CreateResponse{Id: ref, Properties: properties}, nil
+}
+
+// environment returns the lookups of the stack environment an ECR image belongs to.
+func (i *dockerInterceptor) environment(tag lookups.ImageTag) *lookups.Lookups {
+	if i.envs == nil {
+		return nil
+	}
+	for _, l := range i.envs.All() {
+		if l.Account == tag.Account && l.Region == tag.Region {
+			return l
+		}
+	}
+	return nil
+}
+
+// imageTags returns the ECR tags of a docker-build image.
+func imageTags(inputs resource.PropertyMap) []lookups.ImageTag {
+	tags := inputs["tags"]
+	if tags.IsSecret() {
+		tags = tags.SecretValue().Element
+	}
+	if !tags.IsArray() {
+		return nil
+	}
+	var parsed []lookups.ImageTag
+	for _, v := range tags.ArrayValue() {
+		if !v.IsString() {
+			continue
+		}
+		if tag, ok := lookups.ParseImageTag(v.StringValue()); ok {
+			parsed = append(parsed, tag)
+		}
+	}
+	return parsed
+}
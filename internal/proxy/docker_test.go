@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrtypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/smithy-go"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+)
+
+// imageECR holds the digests of the images pushed to the bootstrap repository.
+type imageECR struct {
+	digests map[string]string
+}
+
+func (c imageECR) DescribeImages(_ context.Context, params *ecr.DescribeImagesInput, _ ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	digest, ok := c.digests[*params.RepositoryName+":"+*params.ImageIds[0].ImageTag]
+	if !ok {
+		return nil, &smithy.GenericAPIError{Code: "RepositoryNotFoundException", Message: "not found"}
+	}
+	return &ecr.DescribeImagesOutput{ImageDetails: []ecrtypes.ImageDetail{{ImageDigest: awssdk.String(digest)}}}, nil
+}
+
+type buildClient struct {
+	pulumirpc.ResourceProviderClient
+	created int
+}
+
+func (c *buildClient) Create(_ context.Context, in *pulumirpc.CreateRequest, _ ...grpc.CallOption) (*pulumirpc.CreateResponse, error) {
+	c.created++
+	return &pulumirpc.CreateResponse{Id: "built", Properties: in.GetProperties()}, nil
+}
+
+func dockerImageInterceptor(mode RunMode, skipCreate bool) *dockerInterceptor {
+	return &dockerInterceptor{
+		envs: lookups.NewEnvironments(&lookups.Lookups{
+			Region:    "us-west-2",
+			Account:   "123456789012",
+			ECRClient: imageECR{digests: map[string]string{"cdk-hnb659fds-container-assets:abc123": "sha256:f00"}},
+			Bootstrap: &lookups.BootstrapAssets{
+				Repository: lookups.BootstrapAsset{ResourceType: "AWS::ECR::Repository", ID: "cdk-hnb659fds-container-assets"},
+			},
+		}),
+		mode:       mode,
+		skipCreate: skipCreate,
+		collector:  NewCaptureCollector(),
+		report:     NewReportRecorder(),
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+func dockerImageRequest(t *testing.T, tag string) *pulumirpc.CreateRequest {
+	t.Helper()
+	props, err := plugin.MarshalProperties(resource.PropertyMap{
+		"push": resource.NewBoolProperty(true),
+		"tags": resource.NewArrayProperty([]resource.PropertyValue{
+			resource.NewStringProperty("123456789012.dkr.ecr.us-west-2.amazonaws.com/stack-assets:" + tag),
+		}),
+	}, plugin.MarshalOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &pulumirpc.CreateRequest{
+		Urn:        "urn:pulumi:test::proj::docker-build:index:Image::asset",
+		Properties: props,
+	}
+}
+
+func TestDockerInterceptorUsesPushedImage(t *testing.T) {
+	t.Parallel()
+
+	interceptor := dockerImageInterceptor(RunPulumi, false)
+	client := &buildClient{}
+	resp, err := interceptor.create(context.Background(), dockerImageRequest(t, "abc123"), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.created != 0 {
+		t.Fatalf("expected the pushed image to be used instead of building")
+	}
+	ref := "123456789012.dkr.ecr.us-west-2.amazonaws.com/cdk-hnb659fds-container-assets@sha256:f00"
+	if resp.GetId() != ref {
+		t.Fatalf("unexpected ID %q", resp.GetId())
+	}
+	fields := resp.GetProperties().GetFields()
+	if fields["digest"].GetStringValue() != "sha256:f00" || fields["ref"].GetStringValue() != ref {
+		t.Fatalf("unexpected outputs: %v", fields)
+	}
+}
+
+func TestDockerInterceptorMissingImage(t *testing.T) {
+	t.Parallel()
+
+	interceptor := dockerImageInterceptor(RunPulumi, true)
+	client := &buildClient{}
+	resp, err := interceptor.create(context.Background(), dockerImageRequest(t, "missing"), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.created != 0 || resp.GetId() != "skip-asset" {
+		t.Fatalf("expected a stub with skip-create, got %q (%d builds)", resp.GetId(), client.created)
+	}
+	if summary := interceptor.collector.Summary(); len(summary.Skipped) != 1 {
+		t.Fatalf("expected 1 skipped entry, got %d", len(summary.Skipped))
+	}
+
+	interceptor = dockerImageInterceptor(RunPulumi, false)
+	resp, err = interceptor.create(context.Background(), dockerImageRequest(t, "missing"), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.created != 1 || resp.GetId() != "built" {
+		t.Fatalf("expected the image to be built, got %q (%d builds)", resp.GetId(), client.created)
+	}
+}
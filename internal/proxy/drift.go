@@ -0,0 +1,171 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource/plugin"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const redactedSecret = "[secret]"
+
+// PropertyDrift describes an input property whose live value differs from what the program sets.
+// The next deployment will change the property back to the program's value.
+type PropertyDrift struct {
+	// Path to the property, e.g. `tags.team` or `rules[0].expiration`.
+	Path string `json:"path"`
+	// Input is the value set by the program.
+	Input any `json:"input"`
+	// Live is the value read from AWS; nil when the property is absent.
+	Live any `json:"live"`
+}
+
+// computeDrift compares the program inputs to the live outputs returned by Read. Only properties
+// set by the program are compared: computed outputs, unknown inputs and write-only properties
+// (which Read never returns) are ignored, and nested objects only compare the keys the program
+// sets. Secret values are redacted.
+func computeDrift(inputs, live resource.PropertyMap, writeOnly []string) []PropertyDrift {
+	skip := map[resource.PropertyKey]bool{}
+	for _, prop := range writeOnly {
+		skip[resource.PropertyKey(prop)] = true
+	}
+	var drift []PropertyDrift
+	for _, key := range inputs.StableKeys() {
+		if skip[key] {
+			continue
+		}
+		diffPropertyValue(string(key), inputs[key], live[key], &drift)
+	}
+	return drift
+}
+
+func diffPropertyValue(path string, input, live resource.PropertyValue, drift *[]PropertyDrift) {
+	input, inputSecret, known := unwrapPropertyValue(input)
+	if !known || input.IsNull() {
+		return
+	}
+	live, liveSecret, known := unwrapPropertyValue(live)
+	if !known {
+		return
+	}
+	secret := inputSecret || liveSecret
+
+	switch {
+	case live.IsNull():
+	case input.IsObject() && live.IsObject():
+		liveObj := live.ObjectValue()
+		for _, key := range input.ObjectValue().StableKeys() {
+			diffPropertyValue(path+"."+string(key), input.ObjectValue()[key], liveObj[key], drift)
+		}
+		return
+	case input.IsArray() && live.IsArray() && len(input.ArrayValue()) == len(live.ArrayValue()):
+		liveArr := live.ArrayValue()
+		for i, elem := range input.ArrayValue() {
+			diffPropertyValue(fmt.Sprintf("%s[%d]", path, i), elem, liveArr[i], drift)
+		}
+		return
+	case input.IsString() && live.IsString() && equalJSONStrings(input.StringValue(), live.StringValue()):
+		return
+	case input.DeepEquals(live):
+		return
+	}
+	*drift = append(*drift, PropertyDrift{
+		Path:  path,
+		Input: driftValue(input, secret),
+		Live:  driftValue(live, secret),
+	})
+}
+
+// unwrapPropertyValue strips secret and output wrappers, reporting whether the value was secret and
+// whether it is fully known.
+func unwrapPropertyValue(v resource.PropertyValue) (resource.PropertyValue, bool, bool) {
+	secret := false
+	for {
+		switch {
+		case v.IsSecret():
+			secret = true
+			v = v.SecretValue().Element
+		case v.IsOutput():
+			out := v.OutputValue()
+			if !out.Known {
+				return v, secret, false
+			}
+			secret = secret || out.Secret
+			v = out.Element
+		default:
+			return v, secret, !v.ContainsUnknowns()
+		}
+	}
+}
+
+// equalJSONStrings reports whether two strings hold the same JSON document, so that policy
+// documents that only differ in formatting or key order aren't reported.
+func equalJSONStrings(a, b string) bool {
+	if a == b {
+		return true
+	}
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if !strings.HasPrefix(a, "{") && !strings.HasPrefix(a, "[") {
+		return false
+	}
+	var av, bv any
+	if json.Unmarshal([]byte(a), &av) != nil || json.Unmarshal([]byte(b), &bv) != nil {
+		return false
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+func driftValue(v resource.PropertyValue, secret bool) any {
+	if v.IsNull() {
+		return nil
+	}
+	if secret {
+		return redactedSecret
+	}
+	return v.Mappable()
+}
+
+// driftPaths returns the sorted property paths in drift.
+func driftPaths(drift []PropertyDrift) []string {
+	paths := make([]string, 0, len(drift))
+	for _, d := range drift {
+		paths = append(paths, d.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// readDrift computes the drift between the marshalled program inputs and Read outputs.
+func readDrift(label string, inputs, outputs *structpb.Struct, writeOnly []string) ([]PropertyDrift, error) {
+	opts := plugin.MarshalOptions{
+		Label:        label,
+		KeepUnknowns: true,
+		RejectAssets: true,
+		KeepSecrets:  true,
+	}
+	in, err := plugin.UnmarshalProperties(inputs, opts)
+	if err != nil {
+		return nil, err
+	}
+	out, err := plugin.UnmarshalProperties(outputs, opts)
+	if err != nil {
+		return nil, err
+	}
+	return computeDrift(in, out, writeOnly), nil
+}
+
+// reportDrift logs and records how an imported resource differs from the program inputs.
+func reportDrift(logger *slog.Logger, report *ReportRecorder, urn string, drift []PropertyDrift) {
+	if len(drift) == 0 {
+		return
+	}
+	report.drifted(urn, drift)
+	logger.Info("Imported resource differs from program inputs; the next deployment will modify it",
+		"urn", urn, "properties", driftPaths(drift))
+}
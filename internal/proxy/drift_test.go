@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func TestComputeDrift(t *testing.T) {
+	t.Parallel()
+
+	inputs := resource.PropertyMap{
+		"queueName":         resource.NewStringProperty("jobs"),
+		"visibilityTimeout": resource.NewNumberProperty(30),
+		"tags": resource.NewObjectProperty(resource.PropertyMap{
+			"team": resource.NewStringProperty("platform"),
+			"env":  resource.NewStringProperty("prod"),
+		}),
+		"policy":      resource.NewStringProperty(`{"Version": "2012-10-17", "Statement": []}`),
+		"subnets":     resource.NewArrayProperty([]resource.PropertyValue{resource.NewStringProperty("a"), resource.NewStringProperty("b")}),
+		"kmsKey":      resource.MakeSecret(resource.NewStringProperty("key-1")),
+		"pending":     resource.MakeComputed(resource.NewStringProperty("")),
+		"password":    resource.NewStringProperty("hunter2"),
+		"description": resource.NewNullProperty(),
+		"retention":   resource.NewNumberProperty(7),
+	}
+	live := resource.PropertyMap{
+		"queueName":         resource.NewStringProperty("jobs"),
+		"visibilityTimeout": resource.NewNumberProperty(60),
+		"tags": resource.NewObjectProperty(resource.PropertyMap{
+			"team":    resource.NewStringProperty("platform"),
+			"env":     resource.NewStringProperty("staging"),
+			"aws:cdk": resource.NewStringProperty("extra live tags are ignored"),
+		}),
+		"policy":      resource.NewStringProperty(`{"Statement":[],"Version":"2012-10-17"}`),
+		"subnets":     resource.NewArrayProperty([]resource.PropertyValue{resource.NewStringProperty("a")}),
+		"kmsKey":      resource.MakeSecret(resource.NewStringProperty("key-2")),
+		"pending":     resource.NewStringProperty("whatever"),
+		"arn":         resource.NewStringProperty("computed outputs are ignored"),
+		"description": resource.NewStringProperty("set outside the program"),
+	}
+
+	drift := computeDrift(inputs, live, []string{"password"})
+	want := []PropertyDrift{
+		{Path: "kmsKey", Input: redactedSecret, Live: redactedSecret},
+		{Path: "retention", Input: float64(7), Live: nil},
+		{Path: "subnets", Input: []any{"a", "b"}, Live: []any{"a"}},
+		{Path: "tags.env", Input: "prod", Live: "staging"},
+		{Path: "visibilityTimeout", Input: float64(30), Live: float64(60)},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Fatalf("unexpected drift:\n got: %#v\nwant: %#v", drift, want)
+	}
+}
+
+func TestComputeDriftArrayElements(t *testing.T) {
+	t.Parallel()
+
+	rule := func(days float64) resource.PropertyValue {
+		return resource.NewObjectProperty(resource.PropertyMap{"expirationDays": resource.NewNumberProperty(days)})
+	}
+	inputs := resource.PropertyMap{"rules": resource.NewArrayProperty([]resource.PropertyValue{rule(30), rule(90)})}
+	live := resource.PropertyMap{"rules": resource.NewArrayProperty([]resource.PropertyValue{rule(30), rule(365)})}
+
+	drift := computeDrift(inputs, live, nil)
+	if len(drift) != 1 || drift[0].Path != "rules[1].expirationDays" {
+		t.Fatalf("unexpected drift: %#v", drift)
+	}
+}
+
+func TestReportCountsDrift(t *testing.T) {
+	t.Parallel()
+
+	r := NewReportRecorder()
+	r.drifted("urn:pulumi:test::proj::aws:sqs/queue:Queue::jobs", []PropertyDrift{{Path: "tags.env", Input: "prod", Live: "staging"}})
+	r.mark("urn:pulumi:test::proj::aws:sqs/queue:Queue::jobs", OutcomeImported)
+	r.mark("urn:pulumi:test::proj::aws:sqs/queue:Queue::clean", OutcomeImported)
+
+	built := r.Build(nil, "success")
+	if built.Summary != (ReportSummary{Imported: 2, Drifted: 1}) {
+		t.Fatalf("unexpected summary: %#v", built.Summary)
+	}
+}
@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/pulumi/providertest/providers"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// providerInstances tracks the provider instances the engine runs against a proxied provider: the
+// default provider and every explicit provider resource. The engine dials a connection per
+// instance, so instances are told apart by the peer address of their calls.
+//
+// Each instance remembers the region and profile it was configured with to pick the Lookups of
+// that environment. A provider process only holds a single configuration, so when the selected
+// stacks span several environments every instance after the first gets a provider process of its
+// own.
+type providerInstances struct {
+	// pkg is the provider's config namespace, e.g. `aws` in `aws:config:region`
+	pkg  string
+	envs *lookups.Environments
+	// start launches a dedicated downstream provider; nil when all instances share one
+	start  func() (pulumirpc.ResourceProviderClient, error)
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	instances map[string]*providerInstance
+}
+
+type providerInstance struct {
+	region  string
+	profile string
+	// client is the dedicated downstream provider, nil for the shared one
+	client pulumirpc.ResourceProviderClient
+}
+
+func newProviderInstances(pkg string, envs *lookups.Environments, logger *slog.Logger) *providerInstances {
+	return &providerInstances{pkg: pkg, envs: envs, logger: logger, instances: map[string]*providerInstance{}}
+}
+
+// dedicatedProviders makes every provider instance after the first run against its own downstream
+// provider started by factory.
+func (p *providerInstances) dedicatedProviders(providerCtx context.Context, factory providers.ProviderFactory, pt providers.PulumiTest) {
+	p.start = func() (pulumirpc.ResourceProviderClient, error) {
+		port, err := factory(providerCtx, pt)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := dialProvider(port)
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			<-providerCtx.Done()
+			_ = conn.Close()
+		}()
+		return pulumirpc.NewResourceProviderClient(conn), nil
+	}
+}
+
+func peerKey(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// instance returns the provider instance that made the call in ctx, registering it on its first call.
+func (p *providerInstances) instance(ctx context.Context) (*providerInstance, error) {
+	key := peerKey(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if inst, ok := p.instances[key]; ok {
+		return inst, nil
+	}
+	inst := &providerInstance{}
+	if p.start != nil && len(p.instances) > 0 {
+		client, err := p.start()
+		if err != nil {
+			return nil, fmt.Errorf("failed to start %s provider for another provider instance: %w", p.pkg, err)
+		}
+		inst.client = client
+	}
+	p.instances[key] = inst
+	return inst, nil
+}
+
+// client returns the downstream provider serving the provider instance that made the call in ctx.
+func (p *providerInstances) client(ctx context.Context, shared pulumirpc.ResourceProviderClient) (pulumirpc.ResourceProviderClient, error) {
+	if p == nil {
+		return shared, nil
+	}
+	inst, err := p.instance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if inst.client != nil {
+		return inst.client, nil
+	}
+	return shared, nil
+}
+
+// configure records the region and profile of the provider instance before passing the request on.
+func (p *providerInstances) configure(ctx context.Context, in *pulumirpc.ConfigureRequest, client pulumirpc.ResourceProviderClient) (*pulumirpc.ConfigureResponse, error) {
+	inst, err := p.instance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	region, profile := p.configValue(in, "region"), p.configValue(in, "profile")
+	p.mu.Lock()
+	inst.region, inst.profile = region, profile
+	p.mu.Unlock()
+	p.logger.Debug("Provider instance configured", "provider", p.pkg, "region", region, "profile", profile)
+	return client.Configure(ctx, in)
+}
+
+func (p *providerInstances) configValue(in *pulumirpc.ConfigureRequest, key string) string {
+	if v := in.GetArgs().GetFields()[key].GetStringValue(); v != "" {
+		return v
+	}
+	return in.GetVariables()[p.pkg+":config:"+key]
+}
+
+// lookups returns the Lookups of the environment the provider instance that made the call in ctx
+// is configured for.
+func (p *providerInstances) lookups(ctx context.Context) (*lookups.Lookups, error) {
+	if p == nil {
+		return nil, nil
+	}
+	inst, err := p.instance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	region, profile := inst.region, inst.profile
+	p.mu.Unlock()
+	return p.envs.Select(region, profile)
+}
+
+// intercept routes every call through the downstream provider of the calling provider instance and
+// records provider configuration, then applies the interceptors in base.
+func (p *providerInstances) intercept(base providers.ProviderInterceptors) providers.ProviderInterceptors {
+	out := providers.ProviderInterceptors{
+		Attach:        route(p, base.Attach, pulumirpc.ResourceProviderClient.Attach),
+		Call:          route(p, base.Call, pulumirpc.ResourceProviderClient.Call),
+		Cancel:        route(p, base.Cancel, pulumirpc.ResourceProviderClient.Cancel),
+		Check:         route(p, base.Check, pulumirpc.ResourceProviderClient.Check),
+		CheckConfig:   route(p, base.CheckConfig, pulumirpc.ResourceProviderClient.CheckConfig),
+		Configure:     route(p, base.Configure, pulumirpc.ResourceProviderClient.Configure),
+		Construct:     route(p, base.Construct, pulumirpc.ResourceProviderClient.Construct),
+		Create:        route(p, base.Create, pulumirpc.ResourceProviderClient.Create),
+		Delete:        route(p, base.Delete, pulumirpc.ResourceProviderClient.Delete),
+		Diff:          route(p, base.Diff, pulumirpc.ResourceProviderClient.Diff),
+		DiffConfig:    route(p, base.DiffConfig, pulumirpc.ResourceProviderClient.DiffConfig),
+		GetMapping:    route(p, base.GetMapping, pulumirpc.ResourceProviderClient.GetMapping),
+		GetMappings:   route(p, base.GetMappings, pulumirpc.ResourceProviderClient.GetMappings),
+		GetPluginInfo: route(p, base.GetPluginInfo, pulumirpc.ResourceProviderClient.GetPluginInfo),
+		GetSchema:     route(p, base.GetSchema, pulumirpc.ResourceProviderClient.GetSchema),
+		Invoke:        route(p, base.Invoke, pulumirpc.ResourceProviderClient.Invoke),
+		Read:          route(p, base.Read, pulumirpc.ResourceProviderClient.Read),
+		Update:        route(p, base.Update, pulumirpc.ResourceProviderClient.Update),
+	}
+	if base.Configure == nil {
+		out.Configure = route(p, p.configure, pulumirpc.ResourceProviderClient.Configure)
+	}
+	return out
+}
+
+// route sends a call to the downstream provider of the calling provider instance, through the
+// interceptor if there is one.
+func route[Req, Resp any](
+	p *providerInstances,
+	interceptor func(context.Context, Req, pulumirpc.ResourceProviderClient) (Resp, error),
+	call func(pulumirpc.ResourceProviderClient, context.Context, Req, ...grpc.CallOption) (Resp, error),
+) func(context.Context, Req, pulumirpc.ResourceProviderClient) (Resp, error) {
+	return func(ctx context.Context, in Req, shared pulumirpc.ResourceProviderClient) (Resp, error) {
+		client, err := p.client(ctx, shared)
+		if err != nil {
+			var zero Resp
+			return zero, err
+		}
+		if interceptor != nil {
+			return interceptor(ctx, in, client)
+		}
+		return call(client, ctx, in)
+	}
+}
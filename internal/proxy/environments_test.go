@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/pulumi/providertest/providers"
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// singleEnvironment serves every provider instance from l.
+func singleEnvironment(l *lookups.Lookups) *providerInstances {
+	return newProviderInstances(aws, lookups.NewEnvironments(l), slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// namedClient is a downstream provider that only counts Configure calls.
+type namedClient struct {
+	pulumirpc.ResourceProviderClient
+	name       string
+	configured int
+}
+
+func (c *namedClient) Configure(context.Context, *pulumirpc.ConfigureRequest, ...grpc.CallOption) (*pulumirpc.ConfigureResponse, error) {
+	c.configured++
+	return &pulumirpc.ConfigureResponse{}, nil
+}
+
+func peerContext(port int) context.Context {
+	return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}})
+}
+
+func TestProviderInstancesSelectEnvironment(t *testing.T) {
+	t.Parallel()
+
+	certs := lookups.NewLookups("us-east-1", "123456789012", nil, nil, nil)
+	app := lookups.NewLookups("eu-west-1", "123456789012", nil, nil, nil)
+	instances := newProviderInstances(aws, lookups.NewEnvironments(certs, app), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	var started []*namedClient
+	instances.start = func() (pulumirpc.ResourceProviderClient, error) {
+		c := &namedClient{name: "dedicated"}
+		started = append(started, c)
+		return c, nil
+	}
+
+	type selection struct {
+		client string
+		region string
+	}
+	var got []selection
+	intercepted := instances.intercept(providers.ProviderInterceptors{Create: func(ctx context.Context, _ *pulumirpc.CreateRequest, client pulumirpc.ResourceProviderClient) (*pulumirpc.CreateResponse, error) {
+		l, err := instances.lookups(ctx)
+		if err != nil {
+			return nil, err
+		}
+		got = append(got, selection{client: client.(*namedClient).name, region: l.Region})
+		return &pulumirpc.CreateResponse{}, nil
+	}})
+
+	shared := &namedClient{name: "shared"}
+	defaultProvider, explicitProvider := peerContext(5001), peerContext(5002)
+	if _, err := intercepted.Configure(defaultProvider, &pulumirpc.ConfigureRequest{
+		Variables: map[string]string{"aws:config:region": "us-east-1"},
+	}, shared); err != nil {
+		t.Fatal(err)
+	}
+	args, err := structpb.NewStruct(map[string]any{"region": "eu-west-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := intercepted.Configure(explicitProvider, &pulumirpc.ConfigureRequest{Args: args}, shared); err != nil {
+		t.Fatal(err)
+	}
+	for _, ctx := range []context.Context{explicitProvider, defaultProvider} {
+		if _, err := intercepted.Create(ctx, &pulumirpc.CreateRequest{}, shared); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []selection{{client: "dedicated", region: "eu-west-1"}, {client: "shared", region: "us-east-1"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("unexpected selections: %#v", got)
+	}
+	if len(started) != 1 || started[0].configured != 1 || shared.configured != 1 {
+		t.Fatalf("expected each provider instance to configure its own provider, got %d started and %d shared configures", len(started), shared.configured)
+	}
+
+	if _, err := intercepted.Configure(peerContext(5003), &pulumirpc.ConfigureRequest{
+		Variables: map[string]string{"aws:config:region": "ap-southeast-2"},
+	}, shared); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := intercepted.Create(peerContext(5003), &pulumirpc.CreateRequest{}, shared); err == nil {
+		t.Fatal("expected a provider in a region without stacks to fail")
+	}
+}
@@ -22,13 +22,17 @@ type upEventTracker struct {
 	failures      []string
 
 	failureKeys map[string]struct{}
+	// failedURNs maps each URN whose create failed to the error reported for it.
+	failedURNs map[string]string
 }
 
 type registeredResource struct {
-	URN    string
-	Type   string
-	Name   string
-	Custom bool
+	URN      string
+	Type     string
+	Name     string
+	Custom   bool
+	Parent   string
+	Provider string
 }
 
 func newUpEventTracker() *upEventTracker {
@@ -36,6 +40,7 @@ func newUpEventTracker() *upEventTracker {
 		registeredURNs: make(map[string]registeredResource),
 		diagnostics:    make(map[string][]string),
 		failureKeys:    make(map[string]struct{}),
+		failedURNs:     make(map[string]string),
 	}
 }
 
@@ -66,6 +71,8 @@ func (t *upEventTracker) handle(event events.EngineEvent) {
 				}
 				if pre.Metadata.New != nil {
 					reg.Custom = pre.Metadata.New.Custom
+					reg.Parent = pre.Metadata.New.Parent
+					reg.Provider = pre.Metadata.New.Provider
 				}
 				t.registeredURNs[urn] = reg
 			} else {
@@ -89,6 +96,9 @@ func (t *upEventTracker) handle(event events.EngineEvent) {
 				t.failureKeys[key] = struct{}{}
 			}
 			msg, urnSpecific := t.takeDiagnostic(urn)
+			if urn != "" {
+				t.failedURNs[urn] = msg
+			}
 			if urnSpecific && urn != "" && msg != "" {
 				t.failures = append(t.failures, fmt.Sprintf("%s: %s", urn, msg))
 			} else if msg != "" {
@@ -203,6 +213,18 @@ func (t *upEventTracker) failureKeySet() map[string]struct{} {
 	return out
 }
 
+// failedResources returns the URNs whose create failed, mapped to their error messages.
+func (t *upEventTracker) failedResources() map[string]string {
+	if t == nil {
+		return nil
+	}
+	out := make(map[string]string, len(t.failedURNs))
+	for urn, msg := range t.failedURNs {
+		out[urn] = msg
+	}
+	return out
+}
+
 func failureKeyFromURN(urn string) string {
 	parsed, err := resource.ParseURN(urn)
 	if err != nil {
@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrInterrupted is returned when the run was interrupted. The import file and report are still
+// written with what completed before the interruption.
+var ErrInterrupted = errors.New("run interrupted")
+
+// KillSwitch stops a run immediately when a graceful shutdown takes too long, e.g. on a second
+// Ctrl-C. It is nil-safe.
+type KillSwitch struct {
+	mu        sync.Mutex
+	triggered bool
+	kills     []func()
+}
+
+// Register adds kill to the functions run by Trigger, running it right away if the switch was
+// already triggered.
+func (k *KillSwitch) Register(kill func()) {
+	if k == nil {
+		return
+	}
+	k.mu.Lock()
+	triggered := k.triggered
+	if !triggered {
+		k.kills = append(k.kills, kill)
+	}
+	k.mu.Unlock()
+	if triggered {
+		kill()
+	}
+}
+
+// Trigger runs every registered kill function once.
+func (k *KillSwitch) Trigger() {
+	if k == nil {
+		return
+	}
+	k.mu.Lock()
+	kills := k.kills
+	k.kills = nil
+	k.triggered = true
+	k.mu.Unlock()
+	for _, kill := range kills {
+		kill()
+	}
+}
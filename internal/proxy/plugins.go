@@ -0,0 +1,98 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pulumi/providertest/providers"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// pluginLocator finds provider binaries installed on disk, so the network is only used to install
+// a provider that isn't there.
+type pluginLocator struct {
+	// pluginDir is the --plugin-dir directory, holding either plugin directories like
+	// `resource-aws-v7.14.0` or bare `pulumi-resource-aws` binaries
+	pluginDir string
+	// cacheDir is the Pulumi plugin cache, `$PULUMI_HOME/plugins`
+	cacheDir string
+	// install downloads a provider into the plugin cache
+	install func(name, version string) (string, error)
+}
+
+// newPluginLocator creates a locator for pluginDir and the plugin cache of PULUMI_HOME.
+func newPluginLocator(pluginDir string) pluginLocator {
+	cacheDir, err := workspace.GetPluginDir()
+	if err != nil {
+		cacheDir = ""
+	}
+	return pluginLocator{pluginDir: pluginDir, cacheDir: cacheDir, install: providers.DownloadPluginBinary}
+}
+
+func pluginBinaryName(name string) string {
+	return "pulumi-resource-" + name
+}
+
+// locate returns the binary of provider name at version and whether it was already on disk. A bare
+// binary in the plugin directory has no version in its path and must be verified once started.
+func (l pluginLocator) locate(name, version string) (string, bool, error) {
+	versionDir := fmt.Sprintf("resource-%s-v%s", name, version)
+	if l.pluginDir != "" {
+		if path := filepath.Join(l.pluginDir, versionDir, pluginBinaryName(name)); isExecutable(path) {
+			return path, true, nil
+		}
+		if path := filepath.Join(l.pluginDir, pluginBinaryName(name)); isExecutable(path) {
+			return path, true, nil
+		}
+	}
+	if l.cacheDir != "" {
+		// A `.partial` marker means the plugin is still being installed, or its install failed.
+		_, err := os.Stat(filepath.Join(l.cacheDir, versionDir+".partial"))
+		if path := filepath.Join(l.cacheDir, versionDir, pluginBinaryName(name)); os.IsNotExist(err) && isExecutable(path) {
+			return path, true, nil
+		}
+	}
+	if l.install == nil {
+		return "", false, fmt.Errorf("provider %s v%s is not installed in %s", name, version, l.pluginDir)
+	}
+	path, err := l.install(name, version)
+	return path, false, err
+}
+
+func isExecutable(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && !stat.IsDir() && stat.Mode()&0o111 != 0
+}
+
+// dialProvider connects to a provider serving on port.
+func dialProvider(port providers.Port) (*grpc.ClientConn, error) {
+	return grpc.NewClient(
+		fmt.Sprintf("127.0.0.1:%d", port),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(1024*1024*400)))
+}
+
+// verifyPluginVersion checks that the provider serving on port reports version. Providers that don't
+// report a version are accepted.
+func verifyPluginVersion(ctx context.Context, port providers.Port, path, version string) error {
+	conn, err := dialProvider(port)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	info, err := pulumirpc.NewResourceProviderClient(conn).GetPluginInfo(ctx, &emptypb.Empty{})
+	if err != nil {
+		return fmt.Errorf("failed to get the version of provider %s: %w", path, err)
+	}
+	if got := strings.TrimPrefix(info.GetVersion(), "v"); got != "" && got != strings.TrimPrefix(version, "v") {
+		return fmt.Errorf("provider %s is version %s, expected %s", path, got, version)
+	}
+	return nil
+}
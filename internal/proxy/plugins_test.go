@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/providertest/providers"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+func writePluginBinary(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPluginLocatorLocate(t *testing.T) {
+	t.Parallel()
+
+	noInstall := func(name, version string) (string, error) {
+		return "", errors.New("unexpected install")
+	}
+
+	t.Run("versioned plugin dir", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		want := filepath.Join(dir, "resource-aws-v7.14.0", "pulumi-resource-aws")
+		writePluginBinary(t, want)
+		writePluginBinary(t, filepath.Join(dir, "pulumi-resource-aws"))
+
+		got, local, err := pluginLocator{pluginDir: dir, install: noInstall}.locate(aws, "7.14.0")
+		if err != nil || !local || got != want {
+			t.Fatalf("expected %s, got %s (local=%v), %v", want, got, local, err)
+		}
+	})
+
+	t.Run("bare binary in plugin dir", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		want := filepath.Join(dir, "pulumi-resource-aws-native")
+		writePluginBinary(t, want)
+
+		got, local, err := pluginLocator{pluginDir: dir, install: noInstall}.locate(awsCCApi, "1.40.0")
+		if err != nil || !local || got != want {
+			t.Fatalf("expected %s, got %s (local=%v), %v", want, got, local, err)
+		}
+	})
+
+	t.Run("plugin cache", func(t *testing.T) {
+		t.Parallel()
+		cache := t.TempDir()
+		want := filepath.Join(cache, "resource-docker-build-v0.0.15", "pulumi-resource-docker-build")
+		writePluginBinary(t, want)
+
+		got, local, err := pluginLocator{pluginDir: t.TempDir(), cacheDir: cache, install: noInstall}.locate(docker, "0.0.15")
+		if err != nil || !local || got != want {
+			t.Fatalf("expected %s, got %s (local=%v), %v", want, got, local, err)
+		}
+	})
+
+	t.Run("partial install in cache is downloaded", func(t *testing.T) {
+		t.Parallel()
+		cache := t.TempDir()
+		writePluginBinary(t, filepath.Join(cache, "resource-aws-v7.14.0", "pulumi-resource-aws"))
+		if err := os.WriteFile(filepath.Join(cache, "resource-aws-v7.14.0.partial"), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		installed := false
+		install := func(name, version string) (string, error) {
+			installed = true
+			return filepath.Join(cache, "resource-"+name+"-v"+version), nil
+		}
+		_, local, err := pluginLocator{cacheDir: cache, install: install}.locate(aws, "7.14.0")
+		if err != nil || local || !installed {
+			t.Fatalf("expected a download, got local=%v installed=%v, %v", local, installed, err)
+		}
+	})
+
+	t.Run("missing without install", func(t *testing.T) {
+		t.Parallel()
+		_, _, err := pluginLocator{pluginDir: t.TempDir()}.locate(aws, "7.14.0")
+		if err == nil || !strings.Contains(err.Error(), "provider aws v7.14.0 is not installed") {
+			t.Fatalf("expected a missing provider error, got %v", err)
+		}
+	})
+}
+
+type versionedProvider struct {
+	pulumirpc.UnimplementedResourceProviderServer
+	version string
+}
+
+func (p versionedProvider) GetPluginInfo(context.Context, *emptypb.Empty) (*pulumirpc.PluginInfo, error) {
+	return &pulumirpc.PluginInfo{Version: p.version}, nil
+}
+
+func serveVersionedProvider(t *testing.T, version string) providers.Port {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	pulumirpc.RegisterResourceProviderServer(server, versionedProvider{version: version})
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+	return providers.Port(lis.Addr().(*net.TCPAddr).Port)
+}
+
+func TestVerifyPluginVersion(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	port := serveVersionedProvider(t, "v7.14.0")
+	if err := verifyPluginVersion(ctx, port, "pulumi-resource-aws", "7.14.0"); err != nil {
+		t.Fatalf("expected matching version to pass, got %v", err)
+	}
+	err := verifyPluginVersion(ctx, port, "pulumi-resource-aws", "7.12.0")
+	if err == nil || !strings.Contains(err.Error(), "provider pulumi-resource-aws is version 7.14.0, expected 7.12.0") {
+		t.Fatalf("expected a version mismatch, got %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package proxy
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachProcessGroup starts cmd in its own process group, so a Ctrl-C in the terminal doesn't reach
+// it and the importer decides when it stops.
+func detachProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
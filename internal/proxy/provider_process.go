@@ -39,6 +39,17 @@ func (p *providerProcessSet) add(proc providerProcess) {
 	p.processes = append(p.processes, proc)
 }
 
+// kill stops every provider immediately.
+func (p *providerProcessSet) kill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, proc := range p.processes {
+		if proc.cmd.Process != nil {
+			_ = proc.cmd.Process.Kill()
+		}
+	}
+}
+
 func (p *providerProcessSet) wait(ctx context.Context, logger *slog.Logger) {
 	p.mu.Lock()
 	processes := make([]providerProcess, len(p.processes))
@@ -66,9 +77,10 @@ func (p *providerProcessSet) wait(ctx context.Context, logger *slog.Logger) {
 }
 
 // newProviderFactory starts a provider binary with stdio piped and drained, and tracks it for cleanup.
-func newProviderFactory(name, version string, processes *providerProcessSet) providers.ProviderFactory {
+// Binaries found on disk by locator are checked to be the requested version.
+func newProviderFactory(name, version string, locator pluginLocator, processes *providerProcessSet) providers.ProviderFactory {
 	return func(ctx context.Context, pt providers.PulumiTest) (providers.Port, error) {
-		binaryPath, err := providers.DownloadPluginBinary(name, version)
+		binaryPath, local, err := locator.locate(name, version)
 		if err != nil {
 			return 0, err
 		}
@@ -79,6 +91,12 @@ func newProviderFactory(name, version string, processes *providerProcessSet) pro
 		}
 
 		processes.add(providerProcess{name: name, cmd: cmd})
+		if local {
+			if err := verifyPluginVersion(ctx, port, binaryPath, version); err != nil {
+				_ = cmd.Process.Kill()
+				return 0, err
+			}
+		}
 		return port, nil
 	}
 }
@@ -99,6 +117,7 @@ func startProviderProcess(ctx context.Context, binaryPath, name, cwd string) (pr
 
 	cmd := exec.CommandContext(ctx, pathToExec)
 	cmd.Dir = cwd
+	detachProcessGroup(cmd)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -46,6 +46,35 @@ func TestProviderProcessSetWaitCompletes(t *testing.T) {
 	}
 }
 
+func TestProviderProcessSetKillSwitch(t *testing.T) {
+	t.Parallel()
+
+	cmd := helperCommand(t, "sleep", "10s")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("failed to start helper: %v", err)
+	}
+
+	var processes providerProcessSet
+	processes.add(providerProcess{name: "helper", cmd: cmd})
+	kill := &KillSwitch{}
+	kill.Register(processes.kill)
+	kill.Trigger()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	processes.wait(ctx, discardLogger(t))
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("provider was not killed")
+	}
+
+	registered := false
+	kill.Register(func() { registered = true })
+	if !registered {
+		t.Fatalf("expected providers registered after the trigger to be killed right away")
+	}
+}
+
 func TestProviderProcessSetWaitRespectsContext(t *testing.T) {
 	t.Parallel()
 
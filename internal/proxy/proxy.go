@@ -28,7 +28,6 @@ import (
 	"time"
 
 	"github.com/pulumi/providertest/providers"
-	"github.com/pulumi/pulumi-tool-cdk-importer/internal/common"
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/imports"
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
 	"github.com/pulumi/pulumi/sdk/v3/go/auto"
@@ -43,7 +42,7 @@ const (
 	awsCCApi = "aws-native"
 	aws      = "aws"
 	docker   = "docker-build"
-	// TODO: create workflow to update this
+	// Provider versions used when the program doesn't pin one
 	awsVersion          = "7.14.0"
 	awsCCApiVersion     = "1.40.0"
 	dockerVersion       = "0.0.7"
@@ -59,6 +58,10 @@ const (
 	RunPulumi RunMode = iota
 	// CaptureImports will eventually capture primary IDs instead of mutating resources.
 	CaptureImports
+	// Verify resolves IDs and reads every resource and records how it differs from the program
+	// inputs. Creates succeed with the read state (or the program inputs) without creating anything,
+	// and the run uses a throwaway local backend, so no state is written.
+	Verify
 )
 
 // RunOptions surfaces CLI decisions (mode, import path) into the proxy layer.
@@ -77,6 +80,24 @@ type RunOptions struct {
 	// IncludeAllRegistered controls whether we should seed the import file with all resources observed
 	// during the run (via ResourcePreEvent), even if they never reach state (e.g., due to failures).
 	IncludeAllRegistered bool
+	// ImportFileReferences emits the parent and provider of each resource in the import file, with
+	// the name table they refer to, instead of importing everything with the default provider and
+	// no parent.
+	ImportFileReferences bool
+	// ReportFilePath, when set, receives a JSON report describing how each resource was handled.
+	ReportFilePath string
+	// Credentials are the importer's AWS credentials, also set on the providers of capture and
+	// verify stacks.
+	Credentials lookups.Credentials
+	// CheckProviderAccount fails the run when the provider config of the stack resolves to another
+	// AWS account than the CloudFormation stacks are read from.
+	CheckProviderAccount bool
+	// ProviderVersions override the provider versions detected from the program.
+	ProviderVersions ProviderVersions
+	// KillSwitch, when triggered, kills the providers without waiting for the operations in flight.
+	KillSwitch *KillSwitch
+	// PluginDir holds pre-installed provider binaries, searched before the Pulumi plugin cache.
+	PluginDir string
 }
 
 type pulumiTest struct {
@@ -90,10 +111,10 @@ func (pt pulumiTest) Source() string {
 type ProxiesConfig struct {
 	Region            string
 	Account           string
-	CfnStackResources map[common.LogicalResourceID]lookups.CfnStackResource
+	CfnStackResources map[lookups.StackResourceKey]lookups.CfnStackResource
 }
 
-func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *lookups.Lookups, workDir string, opts RunOptions) error {
+func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, envs *lookups.Environments, workDir string, opts RunOptions) error {
 	if opts.Mode == CaptureImports && opts.ImportFilePath == "" {
 		return fmt.Errorf("import file path is required when capturing imports")
 	}
@@ -104,10 +125,20 @@ func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *l
 	if opts.Mode == CaptureImports && collector == nil {
 		collector = NewCaptureCollector()
 	}
-	ctx, cancel := context.WithCancel(ctx)
+	// The recorder always runs so verify mode and the drift summary can use it; the report file is
+	// only written when requested.
+	report := NewReportRecorder()
+	// Interrupting the run (canceling ctx) only stops `pulumi up`: the engine finishes the operations
+	// in flight, so the providers keep running and the import file and report are still written.
+	upCtx := ctx
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
 	defer cancel()
+	versions, err := resolveProviderVersions(ctx, logger, workDir, nil, opts.ProviderVersions)
+	if err != nil {
+		return err
+	}
 	logger.Info("Starting up providers...")
-	envVars, stopProviders, err := startProxiedProviders(ctx, logger, lookups, pulumiTest{source: workDir}, opts, collector)
+	envVars, stopProviders, err := startProxiedProviders(ctx, logger, envs, pulumiTest{source: workDir}, opts, versions, collector, report)
 	if err != nil {
 		return err
 	}
@@ -139,6 +170,19 @@ func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *l
 	if len(opts.StackNames) > 0 {
 		primaryStack = opts.StackNames[0]
 	}
+	var eventTracker *upEventTracker
+	defer func() {
+		if opts.ReportFilePath != "" {
+			for urn, msg := range eventTracker.failedResources() {
+				report.failed(urn, msg)
+			}
+			if err := WriteReport(opts.ReportFilePath, report.Build(opts.StackNames, status)); err != nil {
+				logger.Warn("Failed to write run report", "path", opts.ReportFilePath, "error", err)
+			} else {
+				logger.Info("Wrote run report", "path", opts.ReportFilePath)
+			}
+		}
+	}()
 	defer func() {
 		importPath := opts.ImportFilePath
 		importExists := false
@@ -160,9 +204,12 @@ func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *l
 		}
 		l.Info("Run complete")
 	}()
-	if opts.Mode == CaptureImports {
+	switch opts.Mode {
+	case CaptureImports:
 		stack, cleanup, err = prepareCaptureStack(ctx, logger, workDir, envVars, opts)
-	} else {
+	case Verify:
+		stack, cleanup, err = prepareVerifyStack(ctx, logger, workDir, envVars, opts)
+	default:
 		stack, err = prepareSelectedStack(ctx, workDir, envVars)
 	}
 	if err != nil {
@@ -180,6 +227,23 @@ func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *l
 		resourcesFailedToImport = 1
 		return fmt.Errorf("failed to set aws-native:autoNaming.autoTrim config: %w", err)
 	}
+	if opts.Mode != RunPulumi {
+		if err := setProviderCredentials(ctx, logger, stack, opts.Credentials); err != nil {
+			status = "failed"
+			resourcesFailedToImport = 1
+			return err
+		}
+	}
+	if opts.CheckProviderAccount {
+		cfg, err := stack.GetAllConfig(ctx)
+		if err != nil {
+			logger.Warn("Could not read stack config to check the provider account", "error", err)
+		} else if err := checkProviderAccounts(ctx, logger, cfg, envs, lookups.CallerAccount); err != nil {
+			status = "failed"
+			resourcesFailedToImport = 1
+			return err
+		}
+	}
 
 	progressWriter := io.Discard
 	errorWriter := io.Discard
@@ -195,7 +259,7 @@ func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *l
 	}
 
 	eventCh := make(chan events.EngineEvent)
-	eventTracker := newUpEventTracker()
+	eventTracker = newUpEventTracker()
 	var eventWG sync.WaitGroup
 	eventWG.Add(1)
 	operationFailedErr := errors.New("operation failed")
@@ -209,9 +273,17 @@ func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *l
 		eventTracker.consume(eventCh)
 	}()
 
+	if opts.Mode == Verify {
+		if err := checkVerifyCreates(upCtx, stack); err != nil {
+			status = "failed"
+			resourcesFailedToImport = 1
+			return err
+		}
+	}
+
 	logger.Info("Importing stack...")
 	upErr := error(nil)
-	_, upErr = stack.Up(ctx,
+	_, upErr = stack.Up(upCtx,
 		optup.ContinueOnError(),
 		optup.ProgressStreams(progressWriter),
 		optup.ErrorProgressStreams(errorWriter),
@@ -220,6 +292,12 @@ func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *l
 		optup.SuppressProgress(),
 	)
 	eventWG.Wait()
+	failedStatus := "failed"
+	if upCtx.Err() != nil {
+		logger.Warn("Run interrupted; writing partial results")
+		failedStatus = "interrupted"
+		operationFailedErr = ErrInterrupted
+	}
 	resourcesImported = eventTracker.created()
 	resourcesFailedToImport = eventTracker.failedCreates()
 
@@ -229,6 +307,35 @@ func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *l
 		}
 	}
 
+	if opts.Mode != Verify {
+		if drifted := report.Build(opts.StackNames, "").Summary.Drifted; drifted > 0 {
+			logger.Info("Imported resources differ from program inputs and will be modified by the next deployment", "resources", drifted)
+		}
+	}
+
+	if opts.Mode == Verify {
+		// Interceptors record every resource they verify; anything else the engine failed on is a
+		// genuine failure (e.g. a program error).
+		for urn, msg := range eventTracker.failedResources() {
+			report.failed(urn, msg)
+		}
+		verifyReport := report.Build(opts.StackNames, "")
+		resourcesImported = verifyReport.Summary.Verified
+		resourcesFailedToImport = verifyReport.Summary.Failed
+		if upErr != nil && len(verifyReport.Resources) == 0 {
+			logUpErrors()
+			status = failedStatus
+			ensureFailureCount()
+			return operationFailedErr
+		}
+		if err := finishVerify(logger, verifyReport); err != nil {
+			status = failedStatus
+			return err
+		}
+		status = "success"
+		return nil
+	}
+
 	if opts.ImportFilePath != "" {
 		state, exportErr := stack.Export(ctx)
 		if exportErr != nil {
@@ -241,12 +348,16 @@ func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *l
 			logger.Warn("pulumi up encountered errors, writing partial import file")
 		}
 
-		finalizeErr := finalizeImportFile(logger, collector, opts.ImportFilePath, state, upErr != nil, eventTracker, opts)
+		var target imports.Target
+		if opts.ImportFileReferences {
+			target = importTarget(ctx, logger, workDir, envVars, stack, state, opts)
+		}
+		finalizeErr := finalizeImportFile(logger, collector, opts.ImportFilePath, state, upErr != nil, eventTracker, opts, target)
 		if finalizeErr != nil {
 			logger.Error("Error writing import file", "error", finalizeErr)
 			// Return the finalize error if Up succeeded, otherwise return Up error
 			if upErr == nil {
-				status = "failed"
+				status = failedStatus
 				ensureFailureCount()
 				return finalizeErr
 			}
@@ -254,7 +365,7 @@ func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *l
 
 		// Return the original Up error if it occurred, so the command exits with error code
 		if upErr != nil {
-			status = "failed"
+			status = failedStatus
 			ensureFailureCount()
 			logUpErrors()
 			return operationFailedErr
@@ -263,7 +374,7 @@ func RunPulumiUpWithProxies(ctx context.Context, logger *slog.Logger, lookups *l
 
 	if upErr != nil {
 		logUpErrors()
-		status = "failed"
+		status = failedStatus
 		ensureFailureCount()
 		return operationFailedErr
 	}
@@ -396,7 +507,7 @@ func cloneEnv(env map[string]string) map[string]string {
 	return dup
 }
 
-func finalizeImportFile(logger *slog.Logger, collector *CaptureCollector, path string, deployment apitype.UntypedDeployment, isPartial bool, tracker *upEventTracker, opts RunOptions) error {
+func finalizeImportFile(logger *slog.Logger, collector *CaptureCollector, path string, deployment apitype.UntypedDeployment, isPartial bool, tracker *upEventTracker, opts RunOptions, target imports.Target) error {
 	if len(deployment.Deployment) == 0 {
 		logger.Info("Exported stack deployment is empty; capture file will only include intercepted resources")
 	} else {
@@ -446,12 +557,23 @@ func finalizeImportFile(logger *slog.Logger, collector *CaptureCollector, path s
 			logger.Info("Filtered import file down to failing resources", "filtered", filtered, "original", originalCount)
 		}
 	}
-	// This importer currently assumes default-provider/no-parent imports, so we intentionally do not emit
-	// nameTable/provider/parent references.
-	file.NameTable = nil
-	for i := range file.Resources {
-		file.Resources[i].Provider = ""
-		file.Resources[i].Parent = ""
+	if opts.ImportFileReferences {
+		if err := imports.ResolveReferences(file, deployment, registrationReferences(tracker.registrations()), target); err != nil {
+			return err
+		}
+		logger.Info("Resolved parent and provider references", "names", len(file.NameTable), "stack", target.Stack)
+		if err := imports.ValidateReferences(file, target); err != nil {
+			// Explicit providers and custom parents must exist before importing; the file is still
+			// written so they can be created first, e.g. with `pulumi up --target`.
+			logger.Warn("Import file refers to resources missing from the target stack", "stack", target.Stack, "error", err)
+		}
+	} else {
+		// Without references, resources import with the default provider and no parent.
+		file.NameTable = nil
+		for i := range file.Resources {
+			file.Resources[i].Provider = ""
+			file.Resources[i].Parent = ""
+		}
 	}
 	if err := imports.WriteFile(path, file); err != nil {
 		return err
@@ -478,6 +600,45 @@ func finalizeImportFile(logger *slog.Logger, collector *CaptureCollector, path s
 	return nil
 }
 
+// importTarget describes the stack the import file is meant for: the stack that ran, when importing
+// into the selected stack, or the stack selected in workDir when capturing into a separate stack.
+// Without a selected stack the references keep pointing at the capture stack.
+func importTarget(ctx context.Context, logger *slog.Logger, workDir string, envVars map[string]string, stack auto.Stack, state apitype.UntypedDeployment, opts RunOptions) imports.Target {
+	project, err := stack.Workspace().ProjectSettings(ctx)
+	if err != nil {
+		logger.Warn("Could not read project settings; import file references the capture stack", "error", err)
+		return imports.Target{}
+	}
+	if opts.Mode == RunPulumi {
+		return imports.Target{Stack: stackBaseName(stack.Name()), Project: string(project.Name), Deployment: state}
+	}
+	ws, err := auto.NewLocalWorkspace(ctx, auto.WorkDir(workDir), auto.EnvVars(envVars))
+	if err != nil {
+		logger.Warn("Could not open workspace; import file references the capture stack", "error", err)
+		return imports.Target{}
+	}
+	summary, err := ws.Stack(ctx)
+	if err != nil || summary == nil {
+		logger.Warn("No stack selected; import file references the capture stack", "stack", stack.Name())
+		return imports.Target{}
+	}
+	deployment, err := ws.ExportStack(ctx, summary.Name)
+	if err != nil {
+		logger.Warn("Could not export the selected stack; assuming it is empty", "stack", summary.Name, "error", err)
+		deployment = apitype.UntypedDeployment{}
+	}
+	return imports.Target{Stack: stackBaseName(summary.Name), Project: string(project.Name), Deployment: deployment}
+}
+
+// stackBaseName strips the organization and project from a fully qualified stack name, leaving the
+// name used in URNs.
+func stackBaseName(name string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
 func loadSkeleton(path string, tracker *upEventTracker, opts RunOptions) (*imports.File, error) {
 	var skeleton *imports.File
 	if path != "" {
@@ -506,6 +667,22 @@ func loadSkeleton(path string, tracker *upEventTracker, opts RunOptions) (*impor
 	return registered, nil
 }
 
+func registrationReferences(regs []registeredResource) []imports.ResourceReferences {
+	refs := make([]imports.ResourceReferences, 0, len(regs))
+	for _, reg := range regs {
+		if reg.URN == "" {
+			continue
+		}
+		refs = append(refs, imports.ResourceReferences{
+			URN:       reg.URN,
+			Parent:    reg.Parent,
+			Provider:  reg.Provider,
+			Component: !reg.Custom,
+		})
+	}
+	return refs
+}
+
 func buildSkeletonFromRegistrations(regs []registeredResource) *imports.File {
 	if len(regs) == 0 {
 		return nil
@@ -569,21 +746,31 @@ func sumResourceChanges(changes map[string]int) int {
 func startProxiedProviders(
 	ctx context.Context,
 	logger *slog.Logger,
-	lookups *lookups.Lookups,
+	envs *lookups.Environments,
 	pt providers.PulumiTest,
 	opts RunOptions,
+	versions ProviderVersions,
 	collector *CaptureCollector,
+	report *ReportRecorder,
 ) (map[string]string, func(), error) {
 	providerLogger := logger.With("subcomponent", "providers")
 	providerCtx, providerCancel := context.WithCancel(ctx)
 	processes := &providerProcessSet{}
-
-	ccapiBinary := newProviderFactory(awsCCApi, awsCCApiVersion, processes)
-	ccapiIntercept := providers.ProviderInterceptFactory(providerCtx, ccapiBinary, awsCCApiInterceptors(lookups, opts, collector, providerLogger))
-	awsBinary := newProviderFactory(aws, awsVersion, processes)
-	awsIntercept := providers.ProviderInterceptFactory(providerCtx, awsBinary, awsInterceptors(lookups, opts, collector, providerLogger))
-	dockerBinary := newProviderFactory(docker, dockerVersion, processes)
-	dockerIntercept := providers.ProviderInterceptFactory(providerCtx, dockerBinary, dockerInterceptors())
+	opts.KillSwitch.Register(processes.kill)
+	locator := newPluginLocator(opts.PluginDir)
+
+	ccapiBinary := newProviderFactory(awsCCApi, versions.AWSNative, locator, processes)
+	ccapiInstances := newProviderInstances(awsCCApi, envs, providerLogger)
+	awsBinary := newProviderFactory(aws, versions.AWS, locator, processes)
+	awsInstances := newProviderInstances(aws, envs, providerLogger)
+	if len(envs.All()) > 1 {
+		ccapiInstances.dedicatedProviders(providerCtx, ccapiBinary, pt)
+		awsInstances.dedicatedProviders(providerCtx, awsBinary, pt)
+	}
+	ccapiIntercept := providers.ProviderInterceptFactory(providerCtx, ccapiBinary, ccapiInstances.intercept(awsCCApiInterceptors(ccapiInstances, opts, collector, report, providerLogger)))
+	awsIntercept := providers.ProviderInterceptFactory(providerCtx, awsBinary, awsInstances.intercept(awsInterceptors(awsInstances, opts, collector, report, providerLogger)))
+	dockerBinary := newProviderFactory(docker, versions.DockerBuild, locator, processes)
+	dockerIntercept := providers.ProviderInterceptFactory(providerCtx, dockerBinary, dockerInterceptors(envs, opts, collector, report, providerLogger))
 
 	cleanup := func() {
 		providerCancel()
@@ -606,34 +793,43 @@ func startProxiedProviders(
 	}, cleanup, nil
 }
 
-func dockerInterceptors() providers.ProviderInterceptors {
-	i := &dockerInterceptor{}
+func dockerInterceptors(envs *lookups.Environments, opts RunOptions, collector *CaptureCollector, report *ReportRecorder, logger *slog.Logger) providers.ProviderInterceptors {
+	i := &dockerInterceptor{
+		envs:       envs,
+		mode:       opts.Mode,
+		skipCreate: opts.SkipCreate,
+		collector:  collector,
+		report:     report,
+		logger:     logger.With("provider", "docker-build"),
+	}
 	return providers.ProviderInterceptors{
-		Create: i.create,
+		Create: continueVerify(opts.Mode, report.wrapCreate(i.create)),
 	}
 }
 
-func awsInterceptors(lookups *lookups.Lookups, opts RunOptions, collector *CaptureCollector, logger *slog.Logger) providers.ProviderInterceptors {
+func awsInterceptors(instances *providerInstances, opts RunOptions, collector *CaptureCollector, report *ReportRecorder, logger *slog.Logger) providers.ProviderInterceptors {
 	i := &awsInterceptor{
-		Lookups:    lookups,
+		instances:  instances,
 		mode:       opts.Mode,
 		collector:  collector,
+		report:     report,
 		skipCreate: opts.SkipCreate,
 		logger:     logger.With("provider", "aws"),
 	}
 	return providers.ProviderInterceptors{
-		Create: i.create,
+		Create: continueVerify(opts.Mode, report.wrapCreate(i.create)),
 	}
 }
 
-func awsCCApiInterceptors(lookups *lookups.Lookups, opts RunOptions, collector *CaptureCollector, logger *slog.Logger) providers.ProviderInterceptors {
+func awsCCApiInterceptors(instances *providerInstances, opts RunOptions, collector *CaptureCollector, report *ReportRecorder, logger *slog.Logger) providers.ProviderInterceptors {
 	i := &awsCCApiInterceptor{
-		Lookups:   lookups,
+		instances: instances,
 		mode:      opts.Mode,
 		collector: collector,
+		report:    report,
 		logger:    logger.With("provider", "aws-native"),
 	}
 	return providers.ProviderInterceptors{
-		Create: i.create,
+		Create: continueVerify(opts.Mode, report.wrapCreate(i.create)),
 	}
 }
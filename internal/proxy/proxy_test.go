@@ -1,6 +1,7 @@
 package proxy
 
 import (
+	"encoding/json"
 	"io"
 	"log/slog"
 	"os"
@@ -10,6 +11,8 @@ import (
 	"github.com/pulumi/pulumi-tool-cdk-importer/internal/imports"
 	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -108,7 +111,7 @@ func TestFinalizeCaptureWithPartialResults(t *testing.T) {
 	})
 
 	// Test with empty deployment (partial result)
-	err := finalizeImportFile(logger, collector, importPath, apitype.UntypedDeployment{}, true, nil, RunOptions{})
+	err := finalizeImportFile(logger, collector, importPath, apitype.UntypedDeployment{}, true, nil, RunOptions{}, imports.Target{})
 	require.NoError(t, err)
 
 	// Verify file was written
@@ -117,7 +120,7 @@ func TestFinalizeCaptureWithPartialResults(t *testing.T) {
 
 	// Test with complete deployment
 	importPath2 := filepath.Join(tmpDir, "import2.json")
-	err = finalizeImportFile(logger, collector, importPath2, apitype.UntypedDeployment{}, false, nil, RunOptions{})
+	err = finalizeImportFile(logger, collector, importPath2, apitype.UntypedDeployment{}, false, nil, RunOptions{}, imports.Target{})
 	require.NoError(t, err)
 
 	// Verify file was written
@@ -136,7 +139,7 @@ func TestFinalizeCaptureLogsPartialStatus(t *testing.T) {
 	logger := slog.New(slog.NewTextHandler(&testWriter{output: &logOutput}, &slog.HandlerOptions{Level: slog.LevelInfo}))
 
 	collector := NewCaptureCollector()
-	err := finalizeImportFile(logger, collector, importPath, apitype.UntypedDeployment{}, true, nil, RunOptions{})
+	err := finalizeImportFile(logger, collector, importPath, apitype.UntypedDeployment{}, true, nil, RunOptions{}, imports.Target{})
 	require.NoError(t, err)
 
 	// Verify partial status is logged
@@ -174,7 +177,7 @@ func TestFinalizeCaptureFiltersFailures(t *testing.T) {
 
 	err := finalizeImportFile(logger, collector, importPath, apitype.UntypedDeployment{}, false, tracker, RunOptions{
 		FilterFailuresOnly: true,
-	})
+	}, imports.Target{})
 	require.NoError(t, err)
 
 	file, err := imports.ReadFile(importPath)
@@ -186,6 +189,64 @@ func TestFinalizeCaptureFiltersFailures(t *testing.T) {
 	}
 }
 
+func TestFinalizeImportFileResolvesReferences(t *testing.T) {
+	t.Parallel()
+
+	tmpDir := t.TempDir()
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	componentURN := "urn:pulumi:stack::project::cdk:index:Stack::app"
+	providerRef := "urn:pulumi:stack::project::pulumi:providers:aws::west::provider-id"
+
+	tracker := newUpEventTracker()
+	tracker.handle(events.EngineEvent{EngineEvent: apitype.EngineEvent{
+		ResourcePreEvent: &apitype.ResourcePreEvent{
+			Metadata: apitype.StepEventMetadata{
+				URN: "urn:pulumi:stack::project::cdk:index:Stack$aws:s3/bucket:Bucket::bucket",
+				Op:  apitype.OpCreate,
+				New: &apitype.StepEventStateMetadata{Custom: true, Parent: componentURN, Provider: providerRef},
+			},
+		},
+	}})
+	collector := NewCaptureCollector()
+	collector.Append(Capture{Type: "aws:s3/bucket:Bucket", Name: "bucket", ID: "bucket-123"})
+
+	// The capture stack is not the stack the file is imported into, which already has the provider.
+	targetProvider := "urn:pulumi:prod::project::pulumi:providers:aws::west"
+	targetState, err := json.Marshal(apitype.DeploymentV3{Resources: []apitype.ResourceV3{
+		{URN: resource.URN(targetProvider), Type: tokens.Type("pulumi:providers:aws"), Custom: true, ID: "provider-id"},
+	}})
+	require.NoError(t, err)
+	target := imports.Target{Stack: "prod", Project: "project", Deployment: apitype.UntypedDeployment{Deployment: targetState}}
+
+	withRefs := filepath.Join(tmpDir, "refs.json")
+	err = finalizeImportFile(logger, collector, withRefs, apitype.UntypedDeployment{}, false, tracker, RunOptions{
+		IncludeAllRegistered: true,
+		ImportFileReferences: true,
+	}, target)
+	require.NoError(t, err)
+	file, err := imports.ReadFile(withRefs)
+	require.NoError(t, err)
+	require.Len(t, file.Resources, 2)
+	assert.Equal(t, imports.Resource{Type: "cdk:index:Stack", Name: "app", Component: true}, file.Resources[0],
+		"the component parent isn't in the target stack, so the import creates it")
+	assert.Equal(t, "app", file.Resources[1].Parent)
+	assert.Equal(t, "west", file.Resources[1].Provider)
+	assert.Equal(t, map[string]string{"west": targetProvider}, file.NameTable)
+	require.NoError(t, imports.ValidateReferences(file, target))
+
+	withoutRefs := filepath.Join(tmpDir, "plain.json")
+	err = finalizeImportFile(logger, collector, withoutRefs, apitype.UntypedDeployment{}, false, tracker, RunOptions{
+		IncludeAllRegistered: true,
+	}, imports.Target{})
+	require.NoError(t, err)
+	file, err = imports.ReadFile(withoutRefs)
+	require.NoError(t, err)
+	require.Len(t, file.Resources, 1)
+	assert.Empty(t, file.Resources[0].Parent)
+	assert.Empty(t, file.Resources[0].Provider)
+	assert.Empty(t, file.NameTable)
+}
+
 func TestUpEventTrackerCountsCreatesAndFailures(t *testing.T) {
 	t.Parallel()
 
@@ -218,6 +279,7 @@ func TestUpEventTrackerCountsCreatesAndFailures(t *testing.T) {
 	assert.Equal(t, 1, tracker.created(), "should count successful creates")
 	assert.Equal(t, 1, tracker.failedCreates(), "should count failed creates")
 	assert.Equal(t, "urn:pulumi:stack::project::pkg:mod:Type::name: boom", tracker.failureSummary())
+	assert.Equal(t, map[string]string{"urn:pulumi:stack::project::pkg:mod:Type::name": "boom"}, tracker.failedResources())
 }
 
 func TestUpEventTrackerUsesGeneralDiagnostics(t *testing.T) {
@@ -0,0 +1,231 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+)
+
+// ReportOutcome describes what happened to a single resource during a run.
+type ReportOutcome string
+
+const (
+	// OutcomeImported means the resource was resolved and read into state.
+	OutcomeImported ReportOutcome = "imported"
+	// OutcomeCreated means the resource can't be imported and was created instead.
+	OutcomeCreated ReportOutcome = "created"
+	// OutcomeSkipped means the resource was stubbed out (e.g. via --skip-create).
+	OutcomeSkipped ReportOutcome = "skipped"
+	// OutcomeFailed means the resource could not be imported.
+	OutcomeFailed ReportOutcome = "failed"
+	// OutcomeVerified means verify mode found the resource; it would import cleanly if Diff is empty.
+	OutcomeVerified ReportOutcome = "verified"
+)
+
+// Report is the machine-readable summary of a run written via --report.
+type Report struct {
+	Stacks     []string      `json:"stacks"`
+	Status     string        `json:"status"`
+	StartedAt  time.Time     `json:"startedAt"`
+	FinishedAt time.Time     `json:"finishedAt"`
+	DurationMs int64         `json:"durationMs"`
+	Summary    ReportSummary `json:"summary"`
+	Resources  []ReportEntry `json:"resources"`
+}
+
+// ReportSummary counts resources by outcome.
+type ReportSummary struct {
+	Imported int `json:"imported"`
+	Created  int `json:"created"`
+	Skipped  int `json:"skipped"`
+	Failed   int `json:"failed"`
+	Verified int `json:"verified"`
+	// Drifted counts imported or verified resources whose live properties differ from the program
+	// inputs, i.e. that the next deployment will modify.
+	Drifted int `json:"drifted"`
+}
+
+// ReportEntry describes how a single resource was resolved and what happened to it.
+type ReportEntry struct {
+	URN        string                     `json:"urn"`
+	StackName  string                     `json:"stackName,omitempty"`
+	LogicalID  string                     `json:"logicalId,omitempty"`
+	CfnType    string                     `json:"cfnType,omitempty"`
+	Strategy   lookups.ResolutionStrategy `json:"strategy,omitempty"`
+	ID         string                     `json:"id,omitempty"`
+	Outcome    ReportOutcome              `json:"outcome"`
+	Error      string                     `json:"error,omitempty"`
+	Diff       []string                   `json:"diff,omitempty"`
+	Replaces   []string                   `json:"replaces,omitempty"`
+	Drift      []PropertyDrift            `json:"drift,omitempty"`
+	StartedAt  *time.Time                 `json:"startedAt,omitempty"`
+	DurationMs int64                      `json:"durationMs"`
+}
+
+// ReportRecorder safely aggregates per-resource results from the provider interceptors. All
+// methods are no-ops on a nil recorder so interceptors don't need to check whether a report was
+// requested.
+type ReportRecorder struct {
+	mu        sync.Mutex
+	startedAt time.Time
+	entries   map[string]*ReportEntry
+}
+
+// NewReportRecorder constructs an empty recorder whose run starts now.
+func NewReportRecorder() *ReportRecorder {
+	return &ReportRecorder{startedAt: time.Now(), entries: make(map[string]*ReportEntry)}
+}
+
+func (r *ReportRecorder) entry(urn string) *ReportEntry {
+	e, ok := r.entries[urn]
+	if !ok {
+		e = &ReportEntry{URN: urn}
+		r.entries[urn] = e
+	}
+	return e
+}
+
+// resolved records how an intercepted resource was mapped to its import ID.
+func (r *ReportRecorder) resolved(urn string, key lookups.StackResourceKey, cfnType string, strategy lookups.ResolutionStrategy, id string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.entry(urn)
+	e.StackName = string(key.StackName)
+	e.LogicalID = string(key.LogicalID)
+	e.CfnType = cfnType
+	e.Strategy = strategy
+	e.ID = id
+}
+
+// mark overrides the outcome an interceptor would otherwise get for a successful call.
+func (r *ReportRecorder) mark(urn string, outcome ReportOutcome) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry(urn).Outcome = outcome
+}
+
+// failed records a failure observed outside of an interceptor, e.g. from engine events. The failure
+// wins over an outcome an interceptor recorded earlier, since the resource didn't make it into
+// state, but an error the interceptor already recorded is kept as the more specific one.
+func (r *ReportRecorder) failed(urn, message string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.entry(urn)
+	if e.Outcome == OutcomeFailed && e.Error != "" {
+		return
+	}
+	e.Outcome = OutcomeFailed
+	e.Error = message
+}
+
+// verified records that verify mode read the resource and how it differs from the program inputs.
+func (r *ReportRecorder) verified(urn string, diff, replaces []string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.entry(urn)
+	e.Outcome = OutcomeVerified
+	e.Diff = diff
+	e.Replaces = replaces
+}
+
+// drifted records how the live properties of a resource differ from the program inputs.
+func (r *ReportRecorder) drifted(urn string, drift []PropertyDrift) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entry(urn).Drift = drift
+}
+
+type createFunc func(context.Context, *pulumirpc.CreateRequest, pulumirpc.ResourceProviderClient) (*pulumirpc.CreateResponse, error)
+
+// wrapCreate times an interceptor's create call and records its outcome.
+func (r *ReportRecorder) wrapCreate(create createFunc) createFunc {
+	if r == nil {
+		return create
+	}
+	return func(ctx context.Context, in *pulumirpc.CreateRequest, client pulumirpc.ResourceProviderClient) (*pulumirpc.CreateResponse, error) {
+		start := time.Now()
+		resp, err := create(ctx, in, client)
+		r.finish(in.GetUrn(), start, time.Since(start), err)
+		return resp, err
+	}
+}
+
+func (r *ReportRecorder) finish(urn string, start time.Time, elapsed time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := r.entry(urn)
+	e.StartedAt = &start
+	e.DurationMs = elapsed.Milliseconds()
+	switch {
+	case err != nil:
+		e.Outcome = OutcomeFailed
+		e.Error = err.Error()
+	case e.Outcome == "":
+		e.Outcome = OutcomeImported
+	}
+}
+
+// Build produces the report for a run that finished with the given status.
+func (r *ReportRecorder) Build(stacks []string, status string) Report {
+	report := Report{Stacks: append([]string{}, stacks...), Status: status, Resources: []ReportEntry{}}
+	if r == nil {
+		return report
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report.StartedAt = r.startedAt
+	report.FinishedAt = time.Now()
+	report.DurationMs = report.FinishedAt.Sub(r.startedAt).Milliseconds()
+	for _, e := range r.entries {
+		report.Resources = append(report.Resources, *e)
+		if len(e.Diff) > 0 || len(e.Drift) > 0 {
+			report.Summary.Drifted++
+		}
+		switch e.Outcome {
+		case OutcomeImported:
+			report.Summary.Imported++
+		case OutcomeCreated:
+			report.Summary.Created++
+		case OutcomeSkipped:
+			report.Summary.Skipped++
+		case OutcomeFailed:
+			report.Summary.Failed++
+		case OutcomeVerified:
+			report.Summary.Verified++
+		}
+	}
+	sort.Slice(report.Resources, func(i, j int) bool {
+		return report.Resources[i].URN < report.Resources[j].URN
+	})
+	return report
+}
+
+// WriteReport writes the report to path as indented JSON.
+func WriteReport(path string, report Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
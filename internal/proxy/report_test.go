@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pulumi/pulumi-tool-cdk-importer/internal/lookups"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+)
+
+func TestReportRecorderOutcomes(t *testing.T) {
+	t.Parallel()
+
+	report := NewReportRecorder()
+	imported := "urn:pulumi:test::proj::aws-native:s3:Bucket::bucket"
+	failed := "urn:pulumi:test::proj::aws-native:sqs:Queue::queue"
+	skipped := "urn:pulumi:test::proj::aws:s3/bucketPolicy:BucketPolicy::policy"
+	engineFailed := "urn:pulumi:test::proj::aws-native:sns:Topic::topic"
+
+	create := report.wrapCreate(func(_ context.Context, in *pulumirpc.CreateRequest, _ pulumirpc.ResourceProviderClient) (*pulumirpc.CreateResponse, error) {
+		switch in.GetUrn() {
+		case imported:
+			report.resolved(imported, lookups.StackResourceKey{StackName: "Stack", LogicalID: "Bucket"}, "AWS::S3::Bucket", lookups.ResolutionPhysicalID, "my-bucket")
+			return &pulumirpc.CreateResponse{Id: "my-bucket"}, nil
+		case skipped:
+			report.mark(skipped, OutcomeSkipped)
+			return &pulumirpc.CreateResponse{Id: "skip-policy"}, nil
+		default:
+			return nil, errors.New("no matching CF resource")
+		}
+	})
+	for _, urn := range []string{imported, failed, skipped} {
+		_, _ = create(context.Background(), &pulumirpc.CreateRequest{Urn: urn}, nil)
+	}
+	report.failed(failed, "engine message should not override interceptor error")
+	report.failed(engineFailed, "provider error")
+
+	built := report.Build([]string{"Stack"}, "failed")
+	if built.Summary != (ReportSummary{Imported: 1, Skipped: 1, Failed: 2}) {
+		t.Fatalf("unexpected summary: %+v", built.Summary)
+	}
+	if len(built.Resources) != 4 {
+		t.Fatalf("expected 4 resources, got %d", len(built.Resources))
+	}
+	byURN := map[string]ReportEntry{}
+	for _, e := range built.Resources {
+		byURN[e.URN] = e
+	}
+	if e := byURN[imported]; e.Outcome != OutcomeImported || e.ID != "my-bucket" || e.Strategy != lookups.ResolutionPhysicalID ||
+		e.CfnType != "AWS::S3::Bucket" || e.StackName != "Stack" || e.LogicalID != "Bucket" || e.StartedAt == nil {
+		t.Fatalf("unexpected imported entry: %+v", e)
+	}
+	if e := byURN[failed]; e.Outcome != OutcomeFailed || e.Error != "no matching CF resource" {
+		t.Fatalf("unexpected failed entry: %+v", e)
+	}
+	if e := byURN[engineFailed]; e.Outcome != OutcomeFailed || e.Error != "provider error" || e.StartedAt != nil {
+		t.Fatalf("unexpected engine failure entry: %+v", e)
+	}
+}
+
+func TestReportRecorderEngineFailureOverridesOutcome(t *testing.T) {
+	t.Parallel()
+
+	report := NewReportRecorder()
+	urn := "urn:pulumi:test::proj::aws-native:s3:Bucket::bucket"
+	create := report.wrapCreate(func(_ context.Context, in *pulumirpc.CreateRequest, _ pulumirpc.ResourceProviderClient) (*pulumirpc.CreateResponse, error) {
+		report.resolved(in.GetUrn(), lookups.StackResourceKey{StackName: "Stack", LogicalID: "Bucket"}, "AWS::S3::Bucket", lookups.ResolutionPhysicalID, "my-bucket")
+		return &pulumirpc.CreateResponse{Id: "my-bucket"}, nil
+	})
+	if _, err := create(context.Background(), &pulumirpc.CreateRequest{Urn: urn}, nil); err != nil {
+		t.Fatal(err)
+	}
+	report.failed(urn, "inputs to import do not match the existing resource")
+
+	built := report.Build([]string{"Stack"}, "failed")
+	if built.Summary != (ReportSummary{Failed: 1}) {
+		t.Fatalf("unexpected summary: %+v", built.Summary)
+	}
+	if e := built.Resources[0]; e.Outcome != OutcomeFailed || e.Error != "inputs to import do not match the existing resource" || e.ID != "my-bucket" {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	report := NewReportRecorder()
+	report.mark("urn:pulumi:test::proj::aws:s3/bucketPolicy:BucketPolicy::policy", OutcomeSkipped)
+	if err := WriteReport(path, report.Build([]string{"Stack"}, "success")); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("report is not valid JSON: %v", err)
+	}
+	if decoded["status"] != "success" {
+		t.Fatalf("unexpected status: %v", decoded["status"])
+	}
+	resources, ok := decoded["resources"].([]any)
+	if !ok || len(resources) != 1 {
+		t.Fatalf("expected one resource, got %v", decoded["resources"])
+	}
+	if outcome := resources[0].(map[string]any)["outcome"]; outcome != "skipped" {
+		t.Fatalf("unexpected outcome: %v", outcome)
+	}
+}
+
+func TestNilReportRecorderIsNoop(t *testing.T) {
+	t.Parallel()
+
+	var report *ReportRecorder
+	report.mark("urn", OutcomeSkipped)
+	report.failed("urn", "boom")
+	called := false
+	create := report.wrapCreate(func(context.Context, *pulumirpc.CreateRequest, pulumirpc.ResourceProviderClient) (*pulumirpc.CreateResponse, error) {
+		called = true
+		return &pulumirpc.CreateResponse{}, nil
+	})
+	if _, err := create(context.Background(), &pulumirpc.CreateRequest{}, nil); err != nil || !called {
+		t.Fatalf("expected wrapped create to be called, err=%v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optpreview"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+)
+
+// verifyResource reads the resource with the resolved import ID and records whether it exists and
+// how its live properties differ from the program inputs. The read state is returned as the created
+// resource, so resources that depend on it are registered and verified too; the verify stack is
+// thrown away after the run.
+func verifyResource(
+	ctx context.Context,
+	logger *slog.Logger,
+	report *ReportRecorder,
+	in *pulumirpc.CreateRequest,
+	client pulumirpc.ResourceProviderClient,
+	id string,
+	writeOnly []string,
+) (*pulumirpc.CreateResponse, error) {
+	urn := in.GetUrn()
+	rresp, err := client.Read(ctx, &pulumirpc.ReadRequest{
+		Id:  id,
+		Urn: urn,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Resource %q could not be read: %w", id, err)
+	}
+	if rresp.GetId() == "" {
+		return nil, fmt.Errorf("Resource %q does not exist", id)
+	}
+	if drift, err := readDrift(fmt.Sprintf("verify(%s)", urn), in.GetProperties(), rresp.GetProperties(), writeOnly); err != nil {
+		logger.Debug("Could not compare resource to program inputs", "urn", urn, "error", err)
+	} else {
+		report.drifted(urn, drift)
+	}
+
+	dresp, err := client.Diff(ctx, &pulumirpc.DiffRequest{
+		Id:        rresp.GetId(),
+		Urn:       urn,
+		Olds:      rresp.GetProperties(),
+		News:      in.GetProperties(),
+		OldInputs: rresp.GetInputs(),
+	})
+	if err != nil {
+		logger.Warn("Could not diff resource against program inputs", "urn", urn, "error", err)
+		report.verified(urn, nil, nil)
+		return verifiedState(in, rresp), nil
+	}
+	diff := diffKeys(dresp)
+	report.verified(urn, diff, sortedCopy(dresp.GetReplaces()))
+	logger.Debug("Verified resource", "urn", urn, "id", id, "diff", diff)
+	return verifiedState(in, rresp), nil
+}
+
+// verifiedState is the create response for a resource verify mode read, falling back to the program
+// inputs if the provider returned no properties.
+func verifiedState(in *pulumirpc.CreateRequest, rresp *pulumirpc.ReadResponse) *pulumirpc.CreateResponse {
+	if rresp.GetProperties() == nil {
+		return verifyStub(in, rresp.GetId())
+	}
+	return &pulumirpc.CreateResponse{Id: rresp.GetId(), Properties: rresp.GetProperties()}
+}
+
+// verifyStub stands in for a resource verify mode didn't read, using the program inputs as its
+// state. Without an id, the resource's name is used.
+func verifyStub(in *pulumirpc.CreateRequest, id string) *pulumirpc.CreateResponse {
+	if urn, err := resource.ParseURN(in.GetUrn()); id == "" && err == nil {
+		id = "verify-" + string(urn.Name())
+	}
+	return &pulumirpc.CreateResponse{Id: id, Properties: in.GetProperties()}
+}
+
+// continueVerify keeps verify mode going past resources that fail verification. The failure is
+// already in the report, so a stub is returned instead of the error: the engine skips everything
+// that depends on a failed create, and those resources would otherwise never be verified. Nothing is
+// ever created either way. Other modes, and interrupted runs, get create's result unchanged.
+func continueVerify(mode RunMode, create createFunc) createFunc {
+	if mode != Verify {
+		return create
+	}
+	return func(ctx context.Context, in *pulumirpc.CreateRequest, client pulumirpc.ResourceProviderClient) (*pulumirpc.CreateResponse, error) {
+		resp, err := create(ctx, in, client)
+		if err != nil && ctx.Err() == nil {
+			return verifyStub(in, ""), nil
+		}
+		return resp, err
+	}
+}
+
+// interceptedPackages are the providers started by startProxiedProviders. Only their creates are
+// stubbed in verify mode.
+var interceptedPackages = map[string]bool{
+	"aws":          true,
+	"aws-native":   true,
+	"docker-build": true,
+}
+
+// checkVerifyCreates previews the program and refuses to verify it if any resource would be created
+// by a provider that isn't intercepted: the engine would call that provider's Create for real, and
+// verify must never provision anything.
+func checkVerifyCreates(ctx context.Context, stack auto.Stack) error {
+	eventCh := make(chan events.EngineEvent)
+	done := make(chan []string)
+	go func() {
+		var unproxied []string
+		for evt := range eventCh {
+			if urn, ok := unproxiedCreate(evt); ok {
+				unproxied = append(unproxied, urn)
+			}
+		}
+		done <- unproxied
+	}()
+	_, err := stack.Preview(ctx, optpreview.EventStreams(eventCh), optpreview.SuppressProgress())
+	unproxied := <-done
+	if err != nil {
+		return fmt.Errorf("preview before verify failed: %w", err)
+	}
+	if len(unproxied) > 0 {
+		sort.Strings(unproxied)
+		return fmt.Errorf("verify would create resources whose providers are not intercepted: %s", strings.Join(unproxied, ", "))
+	}
+	return nil
+}
+
+// unproxiedCreate reports the URN of a custom resource a preview event would create through a
+// provider that isn't intercepted. Components and provider resources create nothing themselves.
+func unproxiedCreate(evt events.EngineEvent) (string, bool) {
+	pre := evt.ResourcePreEvent
+	if pre == nil || pre.Metadata.New == nil || !pre.Metadata.New.Custom {
+		return "", false
+	}
+	switch pre.Metadata.Op {
+	case apitype.OpCreate, apitype.OpReplace, apitype.OpCreateReplacement:
+	default:
+		return "", false
+	}
+	urn, err := resource.ParseURN(pre.Metadata.URN)
+	if err != nil {
+		return "", false
+	}
+	pkg := string(urn.Type().Package())
+	if interceptedPackages[pkg] || pkg == "pulumi" {
+		return "", false
+	}
+	return pre.Metadata.URN, true
+}
+
+// diffKeys returns the sorted top-level properties a provider diff reports as changed.
+func diffKeys(resp *pulumirpc.DiffResponse) []string {
+	keys := sortedCopy(resp.GetDiffs())
+	if len(keys) == 0 {
+		for k := range resp.GetDetailedDiff() {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+	return keys
+}
+
+func sortedCopy(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	out := append([]string(nil), values...)
+	sort.Strings(out)
+	return out
+}
+
+// finishVerify logs what verify mode found and turns it into the run result. Creates never fail in
+// verify mode, so the outcome comes from the report instead.
+func finishVerify(logger *slog.Logger, report Report) error {
+	for _, e := range report.Resources {
+		switch {
+		case e.Outcome == OutcomeFailed:
+			logger.Warn("Resource would not import", "urn", e.URN, "id", e.ID, "error", e.Error)
+		case e.Outcome == OutcomeVerified && (len(e.Diff) > 0 || len(e.Drift) > 0):
+			logger.Info("Resource differs from program inputs", "urn", e.URN, "id", e.ID, "diff", e.Diff, "drift", driftPaths(e.Drift), "replaces", e.Replaces)
+		}
+	}
+	logger.Info("Verification complete",
+		"verified", report.Summary.Verified,
+		"drifted", report.Summary.Drifted,
+		"skipped", report.Summary.Skipped,
+		"failed", report.Summary.Failed,
+	)
+	if report.Summary.Failed > 0 {
+		return fmt.Errorf("%d of %d resources failed verification", report.Summary.Failed, len(report.Resources))
+	}
+	return nil
+}
+
+// copySelectedStackConfig seeds the throwaway verify stack with the config of the stack selected
+// in workDir, so providers see the same region and settings the real import would. It is best
+// effort: without a selected stack, providers fall back to the environment.
+func copySelectedStackConfig(ctx context.Context, logger *slog.Logger, workDir string, envVars map[string]string, target auto.Stack) {
+	ws, err := auto.NewLocalWorkspace(ctx, auto.WorkDir(workDir), auto.EnvVars(envVars))
+	if err != nil {
+		logger.Warn("Could not open workspace to copy stack config", "error", err)
+		return
+	}
+	summary, err := ws.Stack(ctx)
+	if err != nil || summary == nil {
+		logger.Info("No stack selected; verifying without stack config")
+		return
+	}
+	cfg, err := ws.GetAllConfig(ctx, summary.Name)
+	if err != nil {
+		logger.Warn("Could not read stack config", "stack", summary.Name, "error", err)
+		return
+	}
+	if err := target.SetAllConfig(ctx, cfg); err != nil {
+		logger.Warn("Could not copy stack config", "stack", summary.Name, "error", err)
+		return
+	}
+	logger.Info("Copied stack config for verification", "stack", summary.Name, "keys", len(cfg))
+}
+
+// prepareVerifyStack creates a stack in a throwaway local backend that is removed after the run,
+// so verification never touches the selected stack's state.
+func prepareVerifyStack(ctx context.Context, logger *slog.Logger, workDir string, envVars map[string]string, opts RunOptions) (auto.Stack, func(), error) {
+	verifyOpts := opts
+	verifyOpts.LocalStackFile = ""
+	verifyOpts.KeepImportState = false
+	stack, cleanup, err := prepareCaptureStack(ctx, logger, workDir, envVars, verifyOpts)
+	if err != nil {
+		return stack, nil, err
+	}
+	copySelectedStackConfig(ctx, logger, workDir, envVars, stack)
+	return stack, cleanup, nil
+}
@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/events"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	pulumirpc "github.com/pulumi/pulumi/sdk/v3/proto/go"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// verifyClient pretends that only the IDs in existing are live and reports diffs for them.
+type verifyClient struct {
+	pulumirpc.ResourceProviderClient
+	existing map[string][]string
+	creates  int
+}
+
+func (c *verifyClient) Read(_ context.Context, in *pulumirpc.ReadRequest, _ ...grpc.CallOption) (*pulumirpc.ReadResponse, error) {
+	if _, ok := c.existing[in.GetId()]; !ok {
+		return &pulumirpc.ReadResponse{}, nil
+	}
+	return &pulumirpc.ReadResponse{Id: in.GetId()}, nil
+}
+
+func (c *verifyClient) Diff(_ context.Context, in *pulumirpc.DiffRequest, _ ...grpc.CallOption) (*pulumirpc.DiffResponse, error) {
+	return &pulumirpc.DiffResponse{Diffs: c.existing[in.GetId()]}, nil
+}
+
+func (c *verifyClient) Create(context.Context, *pulumirpc.CreateRequest, ...grpc.CallOption) (*pulumirpc.CreateResponse, error) {
+	c.creates++
+	return &pulumirpc.CreateResponse{}, nil
+}
+
+func TestVerifyResource(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	report := NewReportRecorder()
+	client := &verifyClient{existing: map[string][]string{
+		"clean":   nil,
+		"drifted": {"tags", "visibilityTimeout"},
+	}}
+	verify := continueVerify(Verify, report.wrapCreate(func(ctx context.Context, in *pulumirpc.CreateRequest, client pulumirpc.ResourceProviderClient) (*pulumirpc.CreateResponse, error) {
+		id := strings.TrimPrefix(in.GetUrn(), "urn:pulumi:test::proj::aws:sqs/queue:Queue::")
+		return verifyResource(ctx, logger, report, in, client, id, nil)
+	}))
+
+	wantIDs := map[string]string{"clean": "clean", "drifted": "drifted", "missing": "verify-missing"}
+	for _, name := range []string{"clean", "drifted", "missing"} {
+		urn := "urn:pulumi:test::proj::aws:sqs/queue:Queue::" + name
+		resp, err := verify(context.Background(), &pulumirpc.CreateRequest{Urn: urn}, client)
+		if err != nil || resp.GetId() != wantIDs[name] {
+			t.Fatalf("%s: expected verify mode to return the resource state, got %#v, %v", name, resp, err)
+		}
+	}
+	if client.creates != 0 {
+		t.Fatalf("expected no creates, got %d", client.creates)
+	}
+
+	built := report.Build([]string{"Stack"}, "")
+	want := ReportSummary{Verified: 2, Drifted: 1, Failed: 1}
+	if built.Summary != want {
+		t.Fatalf("unexpected summary: %#v", built.Summary)
+	}
+	outcomes := map[string]ReportEntry{}
+	for _, e := range built.Resources {
+		outcomes[e.URN[strings.LastIndex(e.URN, "::")+2:]] = e
+	}
+	if outcomes["clean"].Outcome != OutcomeVerified || outcomes["clean"].Error != "" {
+		t.Fatalf("unexpected clean entry: %#v", outcomes["clean"])
+	}
+	if !reflect.DeepEqual(outcomes["drifted"].Diff, []string{"tags", "visibilityTimeout"}) {
+		t.Fatalf("unexpected drift: %#v", outcomes["drifted"])
+	}
+	if outcomes["missing"].Outcome != OutcomeFailed || !strings.Contains(outcomes["missing"].Error, "does not exist") {
+		t.Fatalf("unexpected missing entry: %#v", outcomes["missing"])
+	}
+
+	if err := finishVerify(logger, built); err == nil || !strings.Contains(err.Error(), "1 of 3 resources failed verification") {
+		t.Fatalf("unexpected verify result: %v", err)
+	}
+}
+
+func TestVerifyModeSkipsUnsupportedTypes(t *testing.T) {
+	t.Parallel()
+
+	report := NewReportRecorder()
+	interceptor := &awsInterceptor{mode: Verify, report: report, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	client := &verifyClient{}
+	resp, err := report.wrapCreate(interceptor.create)(context.Background(), &pulumirpc.CreateRequest{
+		Urn: "urn:pulumi:test::proj::aws:s3/bucketPolicy:BucketPolicy::example",
+	}, client)
+	if err != nil || resp.GetId() != "verify-example" || client.creates != 0 {
+		t.Fatalf("expected a stub without creating, got %#v, %v (%d creates)", resp, err, client.creates)
+	}
+	built := report.Build(nil, "")
+	if built.Summary != (ReportSummary{Skipped: 1}) {
+		t.Fatalf("unexpected summary: %#v", built.Summary)
+	}
+}
+
+func TestVerifyModeVerifiesDependents(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	report := NewReportRecorder()
+	client := &verifyClient{existing: map[string][]string{"policy-for-verify-queue": nil}}
+	verify := continueVerify(Verify, report.wrapCreate(func(ctx context.Context, in *pulumirpc.CreateRequest, client pulumirpc.ResourceProviderClient) (*pulumirpc.CreateResponse, error) {
+		id := "queue"
+		if queue := in.GetProperties().GetFields()["queue"]; queue != nil {
+			id = "policy-for-" + queue.GetStringValue()
+		}
+		return verifyResource(ctx, logger, report, in, client, id, nil)
+	}))
+
+	// The queue doesn't exist, but the engine only registers its policy if the create succeeds.
+	parentURN := "urn:pulumi:test::proj::aws:sqs/queue:Queue::queue"
+	parent, err := verify(context.Background(), &pulumirpc.CreateRequest{Urn: parentURN}, client)
+	if err != nil {
+		t.Fatalf("expected the failed parent to be stubbed, got %v", err)
+	}
+	childURN := "urn:pulumi:test::proj::aws:sqs/queuePolicy:QueuePolicy::policy"
+	props, err := structpb.NewStruct(map[string]any{"queue": parent.GetId()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := verify(context.Background(), &pulumirpc.CreateRequest{Urn: childURN, Properties: props}, client); err != nil {
+		t.Fatalf("unexpected child error: %v", err)
+	}
+
+	outcomes := map[string]ReportOutcome{}
+	for _, e := range report.Build(nil, "").Resources {
+		outcomes[e.URN] = e.Outcome
+	}
+	want := map[string]ReportOutcome{parentURN: OutcomeFailed, childURN: OutcomeVerified}
+	if !reflect.DeepEqual(outcomes, want) {
+		t.Fatalf("expected both resources in the report, got %v", outcomes)
+	}
+}
+
+func TestContinueVerifyKeepsErrorsOutsideVerify(t *testing.T) {
+	t.Parallel()
+
+	boom := errors.New("boom")
+	create := continueVerify(RunPulumi, func(context.Context, *pulumirpc.CreateRequest, pulumirpc.ResourceProviderClient) (*pulumirpc.CreateResponse, error) {
+		return nil, boom
+	})
+	if _, err := create(context.Background(), &pulumirpc.CreateRequest{}, nil); !errors.Is(err, boom) {
+		t.Fatalf("expected the error to be returned, got %v", err)
+	}
+}
+
+func TestUnproxiedCreate(t *testing.T) {
+	t.Parallel()
+
+	pre := func(op apitype.OpType, urn string, custom bool) events.EngineEvent {
+		return events.EngineEvent{EngineEvent: apitype.EngineEvent{ResourcePreEvent: &apitype.ResourcePreEvent{
+			Metadata: apitype.StepEventMetadata{Op: op, URN: urn, New: &apitype.StepEventStateMetadata{Custom: custom}},
+		}}}
+	}
+	cases := []struct {
+		name  string
+		event events.EngineEvent
+		want  bool
+	}{
+		{"aws resource", pre(apitype.OpCreate, "urn:pulumi:dev::proj::aws:s3/bucket:Bucket::b", true), false},
+		{"aws-native resource", pre(apitype.OpCreate, "urn:pulumi:dev::proj::aws-native:s3:Bucket::b", true), false},
+		{"docker-build resource", pre(apitype.OpCreate, "urn:pulumi:dev::proj::docker-build:index:Image::i", true), false},
+		{"provider resource", pre(apitype.OpCreate, "urn:pulumi:dev::proj::pulumi:providers:random::default", true), false},
+		{"component", pre(apitype.OpCreate, "urn:pulumi:dev::proj::cdk:index:Stack::s", false), false},
+		{"unchanged resource", pre(apitype.OpSame, "urn:pulumi:dev::proj::random:index/randomId:RandomId::r", true), false},
+		{"other provider", pre(apitype.OpCreate, "urn:pulumi:dev::proj::random:index/randomId:RandomId::r", true), true},
+		{"other provider replaced", pre(apitype.OpReplace, "urn:pulumi:dev::proj::command:local:Command::c", true), true},
+		{"not a resource event", events.EngineEvent{}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, got := unproxiedCreate(tc.event); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+// ProviderVersions are the plugin versions of the intercepted providers. Empty fields are detected
+// from the program.
+type ProviderVersions struct {
+	AWS         string
+	AWSNative   string
+	DockerBuild string
+}
+
+// providerPackage names a provider plugin and its SDK package in every language.
+type providerPackage struct {
+	plugin   string
+	npm      string
+	pypi     string
+	goModule string
+	nuget    string
+	// fallback is used when the program doesn't pin a version
+	fallback string
+}
+
+var (
+	awsPackage = providerPackage{
+		plugin: aws, npm: "@pulumi/aws", pypi: "pulumi-aws", goModule: "github.com/pulumi/pulumi-aws/sdk", nuget: "Pulumi.Aws",
+		fallback: awsVersion,
+	}
+	awsNativePackage = providerPackage{
+		plugin: awsCCApi, npm: "@pulumi/aws-native", pypi: "pulumi-aws-native", goModule: "github.com/pulumi/pulumi-aws-native/sdk", nuget: "Pulumi.AwsNative",
+		fallback: awsCCApiVersion,
+	}
+	dockerBuildPackage = providerPackage{
+		plugin: docker, npm: "@pulumi/docker-build", pypi: "pulumi-docker-build", goModule: "github.com/pulumi/pulumi-docker-build/sdk", nuget: "Pulumi.DockerBuild",
+		fallback: dockerVersion,
+	}
+)
+
+// detectedVersion is a provider version and where it was found.
+type detectedVersion struct {
+	version string
+	source  string
+}
+
+// lockfileVersions returns the versions of pkg pinned by the language lockfiles in workDir.
+func lockfileVersions(workDir string, pkg providerPackage) ([]detectedVersion, error) {
+	var found []detectedVersion
+	add := func(file, version string) {
+		found = append(found, detectedVersion{version: strings.TrimPrefix(version, "v"), source: file})
+	}
+	read := func(name string) (string, bool, error) {
+		data, err := os.ReadFile(filepath.Join(workDir, name))
+		if os.IsNotExist(err) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+		return string(data), true, nil
+	}
+
+	if data, ok, err := read("package-lock.json"); err != nil {
+		return nil, err
+	} else if ok {
+		var lock struct {
+			Packages map[string]struct {
+				Version string `json:"version"`
+			} `json:"packages"`
+			Dependencies map[string]struct {
+				Version string `json:"version"`
+			} `json:"dependencies"`
+		}
+		if err := json.Unmarshal([]byte(data), &lock); err != nil {
+			return nil, fmt.Errorf("failed to parse package-lock.json: %w", err)
+		}
+		if p, ok := lock.Packages["node_modules/"+pkg.npm]; ok && p.Version != "" {
+			add("package-lock.json", p.Version)
+		} else if d, ok := lock.Dependencies[pkg.npm]; ok && d.Version != "" {
+			add("package-lock.json", d.Version)
+		}
+	}
+
+	if data, ok, err := read("yarn.lock"); err != nil {
+		return nil, err
+	} else if ok {
+		for _, v := range yarnLockVersions(data, pkg.npm) {
+			add("yarn.lock", v)
+		}
+	}
+
+	patterns := []struct {
+		file    string
+		pattern *regexp.Regexp
+	}{
+		// `/@pulumi/aws@6.1.0:`, `'@pulumi/aws@6.1.0':` or `/@pulumi/aws/6.1.0:` package keys
+		{"pnpm-lock.yaml", regexp.MustCompile(`(?m)^\s+['"]?/?` + regexp.QuoteMeta(pkg.npm) + `[@/](\d[^:'"(\s]*)['"]?:`)},
+		{"requirements.txt", regexp.MustCompile(`(?mi)^\s*` + pypiName(pkg.pypi) + `\s*==\s*([^\s;#]+)`)},
+		{"poetry.lock", regexp.MustCompile(`(?mi)^name = "` + pypiName(pkg.pypi) + `"\s*\nversion = "([^"]+)"`)},
+		{"uv.lock", regexp.MustCompile(`(?mi)^name = "` + pypiName(pkg.pypi) + `"\s*\nversion = "([^"]+)"`)},
+		{"go.mod", regexp.MustCompile(`(?m)` + regexp.QuoteMeta(pkg.goModule) + `(?:/v\d+)?\s+(v[^\s]+)`)},
+	}
+	for _, p := range patterns {
+		data, ok, err := read(p.file)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		for _, m := range p.pattern.FindAllStringSubmatch(data, -1) {
+			add(p.file, m[1])
+		}
+	}
+
+	projects, err := filepath.Glob(filepath.Join(workDir, "*.*proj"))
+	if err != nil {
+		return nil, err
+	}
+	nuget := regexp.MustCompile(`(?i)<PackageReference\s+Include="` + regexp.QuoteMeta(pkg.nuget) + `"\s+Version="([^"]+)"`)
+	for _, project := range projects {
+		data, err := os.ReadFile(project)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range nuget.FindAllStringSubmatch(string(data), -1) {
+			add(filepath.Base(project), m[1])
+		}
+	}
+	return found, nil
+}
+
+// pypiName matches a normalized Python package name, which may be written with `-` or `_`.
+func pypiName(name string) string {
+	return strings.ReplaceAll(regexp.QuoteMeta(name), "-", "[-_]")
+}
+
+// yarnLockVersions returns the resolved versions of an npm package in a classic or berry yarn.lock.
+func yarnLockVersions(data, name string) []string {
+	var versions []string
+	inEntry := false
+	for _, line := range strings.Split(data, "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			inEntry = false
+			for _, spec := range strings.Split(strings.TrimSuffix(line, ":"), ",") {
+				spec = strings.Trim(strings.TrimSpace(spec), `"`)
+				if strings.HasPrefix(spec, name+"@") {
+					inEntry = true
+				}
+			}
+			continue
+		}
+		if !inEntry {
+			continue
+		}
+		field := strings.TrimSpace(line)
+		if rest, ok := strings.CutPrefix(field, "version"); ok && (strings.HasPrefix(rest, " ") || strings.HasPrefix(rest, ":")) {
+			versions = append(versions, strings.Trim(strings.TrimSpace(strings.TrimPrefix(rest, ":")), `"`))
+			inEntry = false
+		}
+	}
+	return versions
+}
+
+// stateVersions returns the versions of the provider resources of pkg in a stack deployment.
+func stateVersions(deployment apitype.UntypedDeployment, pkg providerPackage) ([]detectedVersion, error) {
+	if len(deployment.Deployment) == 0 {
+		return nil, nil
+	}
+	var typed apitype.DeploymentV3
+	if err := json.Unmarshal(deployment.Deployment, &typed); err != nil {
+		return nil, err
+	}
+	var found []detectedVersion
+	for _, r := range typed.Resources {
+		if string(r.Type) != "pulumi:providers:"+pkg.plugin {
+			continue
+		}
+		if v, ok := r.Inputs["version"].(string); ok && v != "" {
+			found = append(found, detectedVersion{version: v, source: "stack state"})
+		}
+	}
+	return found, nil
+}
+
+// reconcileVersion picks the version of pkg to run. An override must agree with the lockfiles, which
+// in turn take precedence over the provider versions in the stack state.
+func reconcileVersion(pkg providerPackage, override string, locked, state []detectedVersion) (detectedVersion, error) {
+	override = strings.TrimPrefix(override, "v")
+	lockedVersions := distinctVersions(locked)
+	if len(lockedVersions) > 1 {
+		return detectedVersion{}, fmt.Errorf("the program pins several %s versions (%s); pin a single version", pkg.plugin, describeVersions(locked))
+	}
+	switch {
+	case override != "" && len(lockedVersions) == 1 && lockedVersions[0] != override:
+		return detectedVersion{}, fmt.Errorf("--%s-version %s doesn't match the %s version %s pinned by the program (%s)",
+			pkg.plugin, override, pkg.plugin, lockedVersions[0], describeVersions(locked))
+	case override != "":
+		return detectedVersion{version: override, source: "--" + pkg.plugin + "-version"}, nil
+	case len(lockedVersions) == 1:
+		return locked[0], nil
+	}
+	switch stateVersions := distinctVersions(state); len(stateVersions) {
+	case 0:
+		return detectedVersion{version: pkg.fallback, source: "default"}, nil
+	case 1:
+		return state[0], nil
+	default:
+		return detectedVersion{}, fmt.Errorf("the stack state has %s providers of several versions (%s); pass --%s-version",
+			pkg.plugin, strings.Join(stateVersions, ", "), pkg.plugin)
+	}
+}
+
+func distinctVersions(found []detectedVersion) []string {
+	seen := map[string]bool{}
+	var versions []string
+	for _, f := range found {
+		if !seen[f.version] {
+			seen[f.version] = true
+			versions = append(versions, f.version)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+func describeVersions(found []detectedVersion) string {
+	parts := make([]string, 0, len(found))
+	for _, f := range found {
+		parts = append(parts, fmt.Sprintf("%s in %s", f.version, f.source))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// selectedStackDeployment exports the state of the stack selected in workDir, if there is one.
+func selectedStackDeployment(ctx context.Context, logger *slog.Logger, workDir string, envVars map[string]string) apitype.UntypedDeployment {
+	ws, err := auto.NewLocalWorkspace(ctx, auto.WorkDir(workDir), auto.EnvVars(envVars))
+	if err != nil {
+		logger.Debug("Could not open workspace to read provider versions from state", "error", err)
+		return apitype.UntypedDeployment{}
+	}
+	summary, err := ws.Stack(ctx)
+	if err != nil || summary == nil {
+		return apitype.UntypedDeployment{}
+	}
+	deployment, err := ws.ExportStack(ctx, summary.Name)
+	if err != nil {
+		logger.Debug("Could not export stack to read provider versions from state", "stack", summary.Name, "error", err)
+		return apitype.UntypedDeployment{}
+	}
+	return deployment
+}
+
+// resolveProviderVersions detects the provider versions the program in workDir uses from its
+// lockfiles and the state of the selected stack, and reconciles them with the overrides.
+func resolveProviderVersions(ctx context.Context, logger *slog.Logger, workDir string, envVars map[string]string, overrides ProviderVersions) (ProviderVersions, error) {
+	deployment := selectedStackDeployment(ctx, logger, workDir, envVars)
+	var resolved ProviderVersions
+	for _, p := range []struct {
+		pkg      providerPackage
+		override string
+		out      *string
+	}{
+		{awsPackage, overrides.AWS, &resolved.AWS},
+		{awsNativePackage, overrides.AWSNative, &resolved.AWSNative},
+		{dockerBuildPackage, overrides.DockerBuild, &resolved.DockerBuild},
+	} {
+		locked, err := lockfileVersions(workDir, p.pkg)
+		if err != nil {
+			return resolved, fmt.Errorf("failed to read %s version from lockfiles: %w", p.pkg.plugin, err)
+		}
+		state, err := stateVersions(deployment, p.pkg)
+		if err != nil {
+			logger.Debug("Could not read provider versions from state", "provider", p.pkg.plugin, "error", err)
+		}
+		v, err := reconcileVersion(p.pkg, p.override, locked, state)
+		if err != nil {
+			return resolved, err
+		}
+		logger.Info("Using provider version", "provider", p.pkg.plugin, "version", v.version, "source", v.source)
+		*p.out = v.version
+	}
+	return resolved, nil
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+func TestLockfileVersions(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		"package-lock.json": `{"packages": {"node_modules/@pulumi/aws": {"version": "7.14.0"}, "node_modules/@pulumi/aws-native": {"version": "1.40.0"}}}`,
+		"yarn.lock": `# yarn lockfile v1
+
+"@pulumi/aws-native@^1.38.0":
+  version "1.40.0"
+
+"@pulumi/aws@^7.0.0", "@pulumi/aws@^7.14.0":
+  version "7.14.0"
+`,
+		"pnpm-lock.yaml": `packages:
+
+  '@pulumi/aws-native@1.40.0':
+    resolution: {integrity: sha512-x}
+
+  '@pulumi/aws@7.14.0':
+    resolution: {integrity: sha512-y}
+`,
+		"requirements.txt": "pulumi>=3.0.0\npulumi-aws-native==1.40.0\npulumi_aws==7.14.0 ; python_version >= '3.9'\n",
+		"poetry.lock":      "[[package]]\nname = \"pulumi-aws\"\nversion = \"7.14.0\"\n\n[[package]]\nname = \"pulumi-aws-native\"\nversion = \"1.40.0\"\n",
+		"go.mod":           "require (\n\tgithub.com/pulumi/pulumi-aws-native/sdk v1.40.0\n\tgithub.com/pulumi/pulumi-aws/sdk/v7 v7.14.0\n)\n",
+		"App.csproj":       `<ItemGroup><PackageReference Include="Pulumi.Aws" Version="7.14.0" /><PackageReference Include="Pulumi.AwsNative" Version="1.40.0" /></ItemGroup>`,
+	}
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, pkg := range []providerPackage{awsPackage, awsNativePackage} {
+		found, err := lockfileVersions(dir, pkg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != len(files) {
+			t.Fatalf("%s: expected a version from every lockfile, got %s", pkg.plugin, describeVersions(found))
+		}
+		want := map[string]string{aws: "7.14.0", awsCCApi: "1.40.0"}[pkg.plugin]
+		if versions := distinctVersions(found); !reflect.DeepEqual(versions, []string{want}) {
+			t.Fatalf("%s: unexpected versions %s", pkg.plugin, describeVersions(found))
+		}
+	}
+}
+
+func TestStateVersions(t *testing.T) {
+	t.Parallel()
+
+	deployment, err := json.Marshal(apitype.DeploymentV3{Resources: []apitype.ResourceV3{
+		{Type: "pulumi:providers:aws", Inputs: map[string]any{"version": "7.10.0"}},
+		{Type: "pulumi:providers:aws-native", Inputs: map[string]any{"version": "1.30.0"}},
+		{Type: "aws:s3/bucket:Bucket"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := stateVersions(apitype.UntypedDeployment{Version: 3, Deployment: deployment}, awsPackage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].version != "7.10.0" {
+		t.Fatalf("unexpected versions: %#v", found)
+	}
+}
+
+func TestReconcileVersion(t *testing.T) {
+	t.Parallel()
+
+	locked := []detectedVersion{{version: "7.14.0", source: "package-lock.json"}}
+	state := []detectedVersion{{version: "7.10.0", source: "stack state"}}
+	tests := []struct {
+		name     string
+		override string
+		locked   []detectedVersion
+		state    []detectedVersion
+		want     string
+		wantErr  string
+	}{
+		{name: "default", want: awsVersion},
+		{name: "lockfile wins over state", locked: locked, state: state, want: "7.14.0"},
+		{name: "state", state: state, want: "7.10.0"},
+		{name: "override wins over state", override: "v7.12.0", state: state, want: "7.12.0"},
+		{name: "override agrees with lockfile", override: "7.14.0", locked: locked, want: "7.14.0"},
+		{name: "override conflicts with lockfile", override: "7.12.0", locked: locked, wantErr: "--aws-version 7.12.0 doesn't match the aws version 7.14.0 pinned by the program"},
+		{name: "lockfiles disagree", locked: append([]detectedVersion{{version: "7.13.0", source: "yarn.lock"}}, locked...), wantErr: "pins several aws versions"},
+		{name: "state disagrees", state: append([]detectedVersion{{version: "7.9.0", source: "stack state"}}, state...), wantErr: "pass --aws-version"},
+	}
+	for _, tt := range tests {
+		got, err := reconcileVersion(awsPackage, tt.override, tt.locked, tt.state)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("%s: expected error %q, got %v", tt.name, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil || got.version != tt.want {
+			t.Fatalf("%s: expected %s, got %#v, %v", tt.name, tt.want, got, err)
+		}
+	}
+}